@@ -0,0 +1,144 @@
+package log
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLogFormat 访问日志格式
+type AccessLogFormat string
+
+const (
+	AccessLogJSON     AccessLogFormat = "json"     // 结构化 JSON
+	AccessLogCommon   AccessLogFormat = "common"   // Apache common 格式
+	AccessLogCombined AccessLogFormat = "combined" // Apache combined 格式
+)
+
+func (l *Logger) initAccess() {
+	if l.Opts.AccessFileName == "" {
+		return
+	}
+	ws := l.fileWriter(l.filePath(l.Opts.AccessFileName))
+	if l.Opts.AccessFormat == AccessLogCommon || l.Opts.AccessFormat == AccessLogCombined {
+		l.access = ws
+		return
+	}
+	all := zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+	var core zapcore.Core = zapcore.NewCore(zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig), ws, all)
+	// WithProcessor 添加的 Processor（如 TruncateIP）同样作用于 JSON 格式的访问日志，Apache 格式见 accessRemote
+	if len(l.Opts.Processors) > 0 {
+		core = &processorCore{Core: core, processors: l.Opts.Processors}
+	}
+	l.accessJSON = zap.New(core)
+}
+
+// Access 记录一条访问日志，未启用 WithAccessLog 时以 JSON 字段写入普通日志
+func Access(r *http.Request, status, size int, start time.Time) {
+	fields := accessFields(r, status, size, start)
+	if l != nil && l.access != nil {
+		remote, ok := l.accessRemote(fields)
+		if !ok {
+			return
+		}
+		l.access.Write(apacheLine(r, remote, status, size, start, l.Opts.AccessFormat == AccessLogCombined))
+		return
+	}
+	if l != nil && l.accessJSON != nil {
+		l.accessJSON.Info("access", fields...)
+		return
+	}
+	Info("access", fields...)
+}
+
+func accessFields(r *http.Request, status, size int, start time.Time) []zap.Field {
+	return []zap.Field{
+		zap.String("remote", remoteHost(r)),
+		zap.String("method", r.Method),
+		zap.String("uri", r.RequestURI),
+		zap.String("proto", r.Proto),
+		zap.Int("status", status),
+		zap.Int("size", size),
+		zap.String("referer", r.Referer()),
+		zap.String("user_agent", r.UserAgent()),
+		zap.Duration("duration", time.Since(start)),
+	}
+}
+
+// accessRemote 对 Apache 格式的访问日志同样执行 WithProcessor 添加的 Processor（如 TruncateIP），
+// 返回处理后的 remote 字段；Processor 丢弃日志时返回 false
+func (l *Logger) accessRemote(fields []zap.Field) (string, bool) {
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: now(), Message: "access"}
+	for _, p := range l.Opts.Processors {
+		var keep bool
+		if ent, fields, keep = p(ent, fields); !keep {
+			return "", false
+		}
+	}
+	return orDash(fieldString(fields, "remote")), true
+}
+
+// AccessHandler 为每个请求记录访问日志
+func AccessHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+		Access(r, rec.status, rec.size, start)
+	})
+}
+
+func apacheLine(r *http.Request, remote string, status, size int, start time.Time, combined bool) []byte {
+	user := "-"
+	if r.URL != nil && r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	} else if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.Itoa(size)
+	}
+	buf := make([]byte, 0, 256)
+	buf = append(buf, remote...)
+	buf = append(buf, " - "...)
+	buf = append(buf, user...)
+	buf = append(buf, " ["...)
+	buf = start.AppendFormat(buf, "02/Jan/2006:15:04:05 -0700")
+	buf = append(buf, `] "`...)
+	buf = append(buf, r.Method...)
+	buf = append(buf, ' ')
+	buf = append(buf, r.RequestURI...)
+	buf = append(buf, ' ')
+	buf = append(buf, r.Proto...)
+	buf = append(buf, `" `...)
+	buf = strconv.AppendInt(buf, int64(status), 10)
+	buf = append(buf, ' ')
+	buf = append(buf, bytes...)
+	if combined {
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, orDash(r.Referer()))
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, orDash(r.UserAgent()))
+	}
+	return append(buf, '\n')
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
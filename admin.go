@@ -0,0 +1,92 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Rotate 立即切割普通日志文件与各等级日志文件，切割前先 Sync
+func Rotate() error {
+	if l == nil || l.fileName == "" {
+		return nil
+	}
+	Sync()
+	for _, r := range l.rotators {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdminHandler 返回日志的运维接口，可挂载在任意前缀下（需配合 http.StripPrefix），供 cmd/logctl 使用：
+//
+//	GET    /level                       全局等级与各命名 logger 的等级
+//	PUT    /level?level=debug           设置全局等级，带 logger=name 时设置命名 logger 的等级
+//	DELETE /level?logger=name           取消命名 logger 的等级设置
+//	POST   /rotate                      立即切割日志文件
+//	GET    /stats                       Stats 的 JSON
+//	GET    /stream?level=&regex=        同 StreamHandler
+//
+// 接口可修改日志等级，应只在内部端口上提供或加上鉴权。
+func AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/level", adminLevel)
+	mux.HandleFunc("/rotate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := Rotate(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, Stats())
+	})
+	mux.Handle("/stream", StreamHandler())
+	return mux
+}
+
+// adminLevel 查询或设置日志等级
+func adminLevel(w http.ResponseWriter, r *http.Request) {
+	logger := r.URL.Query().Get("logger")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level := strings.ToLower(r.URL.Query().Get("level"))
+		if _, ok := parseLevel(level); !ok {
+			http.Error(w, "invalid level "+level, http.StatusBadRequest)
+			return
+		}
+		if logger == "" {
+			SetLevel(level)
+		} else {
+			SetLoggerLevel(logger, level)
+		}
+	case http.MethodDelete:
+		if logger == "" {
+			http.Error(w, "logger required", http.StatusBadRequest)
+			return
+		}
+		ResetLoggerLevel(logger)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	global := "info"
+	if l != nil {
+		global = levelName(l.zapConfig.Level.Level())
+	}
+	writeJSON(w, map[string]interface{}{"level": global, "loggers": LoggerLevels()})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,207 @@
+package log
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BackpressureMode 异步队列已满时的处理方式
+type BackpressureMode int
+
+const (
+	BackpressureBlock      BackpressureMode = iota // 阻塞调用方直到队列有空位
+	BackpressureDropLowest                         // 丢弃队列中等级最低的日志，新日志等级不高于它们时丢弃新日志
+	BackpressureDropNewest                         // 丢弃新日志
+)
+
+type asyncItem struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+	flush  chan struct{} // 非空时为 Sync 请求，写出之前的日志后关闭
+}
+
+// asyncQueue 有界的异步写入队列，由单个 goroutine 按顺序写出
+type asyncQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []asyncItem
+	size     int
+	mode     BackpressureMode
+	closed   bool
+	dropped  uint64
+	base     zapcore.Core
+}
+
+func newAsyncQueue(base zapcore.Core, size int, mode BackpressureMode, done <-chan struct{}) *asyncQueue {
+	q := &asyncQueue{items: make([]asyncItem, 0, size), size: size, mode: mode, base: base}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	go q.run()
+	go func() {
+		<-done
+		q.mu.Lock()
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+		q.mu.Unlock()
+	}()
+	return q
+}
+
+// push 将日志放入队列，返回 false 表示 logger 已停止，调用方应直接写出
+func (q *asyncQueue) push(item asyncItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed && item.flush == nil && len(q.items) >= q.size {
+		switch q.mode {
+		case BackpressureDropNewest:
+			atomic.AddUint64(&q.dropped, 1)
+			return true
+		case BackpressureDropLowest:
+			min := -1
+			for i, it := range q.items {
+				if it.flush == nil && it.ent.Level < item.ent.Level && (min < 0 || it.ent.Level < q.items[min].ent.Level) {
+					min = i
+				}
+			}
+			atomic.AddUint64(&q.dropped, 1)
+			if min < 0 {
+				return true
+			}
+			q.items = append(q.items[:min], q.items[min+1:]...)
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return false
+	}
+	q.items = append(q.items, item)
+	q.notEmpty.Signal()
+	return true
+}
+
+func (q *asyncQueue) run() {
+	batch := make([]asyncItem, 0, q.size)
+	for {
+		q.mu.Lock()
+		for len(q.items) == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		batch, q.items = q.items, batch[:0]
+		closed := q.closed
+		q.notFull.Broadcast()
+		q.mu.Unlock()
+
+		for i, item := range batch {
+			if item.flush != nil {
+				q.base.Sync()
+				close(item.flush)
+			} else {
+				item.core.Write(item.ent, item.fields)
+			}
+			batch[i] = asyncItem{}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// asyncCore 将日志放入队列后立即返回，Panic、Fatal 等级的日志会等待队列写出。
+// 字段在放入队列前复制，调用方返回后可继续修改或复用 fields 及其引用的值。
+type asyncCore struct {
+	zapcore.Core
+	q    *asyncQueue
+	snap zapcore.Encoder // 将可变的字段值预先编码为 JSON
+}
+
+func newAsyncCore(core zapcore.Core, q *asyncQueue, cfg zapcore.EncoderConfig) *asyncCore {
+	cfg.TimeKey, cfg.LevelKey, cfg.NameKey, cfg.CallerKey = "", "", "", ""
+	cfg.FunctionKey, cfg.MessageKey, cfg.StacktraceKey = "", "", ""
+	cfg.LineEnding = "\n"
+	return &asyncCore{Core: core, q: q, snap: zapcore.NewJSONEncoder(cfg)}
+}
+
+func (c *asyncCore) With(fields []zapcore.Field) zapcore.Core {
+	return &asyncCore{Core: c.Core.With(fields), q: c.q, snap: c.snap}
+}
+
+// snapshot 复制 fields：[]byte 值复制一份，数组、对象、反射与 Stringer 字段在调用方的 goroutine 上
+// 编码为 JSON，避免队列写出时读到调用方已修改或归还对象池的数据
+func (c *asyncCore) snapshot(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.BinaryType, zapcore.ByteStringType:
+			if b, ok := f.Interface.([]byte); ok {
+				f.Interface = append([]byte(nil), b...)
+			}
+		case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.ReflectType, zapcore.StringerType:
+			f = c.encode(f)
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// encode 将 f 编码为 JSON 后以 json.RawMessage 保存，编码出错时保留原字段
+func (c *asyncCore) encode(f zapcore.Field) zapcore.Field {
+	enc := c.snap.Clone()
+	f.AddTo(enc)
+	buf, err := enc.EncodeEntry(zapcore.Entry{}, nil)
+	if err != nil {
+		return f
+	}
+	defer buf.Free()
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &obj); err != nil || len(obj) != 1 {
+		return f
+	}
+	raw, ok := obj[f.Key]
+	if !ok {
+		return f
+	}
+	return zap.Reflect(f.Key, raw)
+}
+
+func (c *asyncCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *asyncCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.q.push(asyncItem{core: c.Core, ent: ent, fields: c.snapshot(fields)}) {
+		return c.Core.Write(ent, fields)
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		return c.Sync()
+	}
+	return nil
+}
+
+// Sync 等待此前放入队列的日志写出后再 Sync
+func (c *asyncCore) Sync() error {
+	flush := make(chan struct{})
+	if !c.q.push(asyncItem{flush: flush}) {
+		return c.Core.Sync()
+	}
+	<-flush
+	return nil
+}
+
+// Dropped 返回异步队列已满时丢弃的日志条数
+func Dropped() uint64 {
+	if l == nil || l.queue == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&l.queue.dropped)
+}
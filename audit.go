@@ -0,0 +1,59 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// auditRequired 审计日志必须包含的字段
+var auditRequired = []string{"actor", "action", "resource", "outcome"}
+
+func (l *Logger) initAudit() {
+	if l.Opts.AuditFileName == "" {
+		return
+	}
+	fileName := l.filePath(l.Opts.AuditFileName)
+	lj := l.rotateLogger(fileName)
+	lj.Compress = false
+	if l.Opts.AuditMaxAge > 0 {
+		lj.MaxAge = l.Opts.AuditMaxAge
+	}
+	if l.Opts.AuditMaxBackups > 0 {
+		lj.MaxBackups = l.Opts.AuditMaxBackups
+	}
+	rc := newRotationCounter(lj, l.Opts.Clock, nil)
+	l.auxFiles = append(l.auxFiles, rc)
+	ws := l.chainWriter(l.rotateWriter(rc), fileName)
+	all := zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+	var core zapcore.Core = zapcore.NewCore(zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig), ws, all)
+	if l.committer != nil {
+		core = &durableCore{Core: core, gc: l.committer, durable: true}
+	}
+	l.audit = zap.New(core)
+}
+
+// Audit 写入审计日志。审计日志不受日志等级、采样等影响，缺少 actor/action/resource/outcome
+// 字段时仍会写入，并通过 missing_fields 标出缺失的字段。未启用 WithAudit 时写入普通日志。
+func Audit(event string, fields ...zap.Field) {
+	var missing []string
+	for _, key := range auditRequired {
+		found := false
+		for _, f := range fields {
+			if f.Key == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		fields = append(fields[:len(fields):len(fields)], zap.Strings("missing_fields", missing))
+	}
+	if l == nil || l.audit == nil {
+		Info(event, append(fields[:len(fields):len(fields)], zap.Bool("audit", true))...)
+		return
+	}
+	l.audit.Info(event, fields...)
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// bannerFields 返回生效配置的摘要：等级、输出、切割策略与构建信息
+func (l *Logger) bannerFields() []zap.Field {
+	version, revision := buildInfo()
+	console := l.Opts.Development
+	if l.Opts.Console != nil {
+		console = *l.Opts.Console
+	}
+	outputs := zap.Object("outputs", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if l.Opts.File {
+			enc.AddString("file", l.fileName)
+		}
+		enc.AddBool("console", console)
+		if len(l.Opts.Sinks) > 0 {
+			enc.AddInt("sinks", len(l.Opts.Sinks))
+		}
+		if l.Opts.AuditFileName != "" {
+			enc.AddString("audit", l.filePath(l.Opts.AuditFileName))
+		}
+		if l.Opts.AccessFileName != "" {
+			enc.AddString("access", l.filePath(l.Opts.AccessFileName))
+		}
+		if l.Opts.EventFileName != "" {
+			enc.AddString("events", l.filePath(l.Opts.EventFileName))
+		}
+		return nil
+	}))
+	rotation := zap.Object("rotation", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddInt("max_size_mb", l.Opts.MaxSize)
+		enc.AddInt("max_backups", l.Opts.MaxBackups)
+		enc.AddInt("max_age_days", l.Opts.MaxAge)
+		enc.AddBool("compress", l.Opts.Compress)
+		return nil
+	}))
+	return []zap.Field{
+		zap.String("min_level", levelName(l.zapConfig.Level.Level())),
+		outputs,
+		rotation,
+		zap.Int("async_queue", l.Opts.AsyncQueueSize),
+		zap.String("version", version),
+		zap.String("revision", revision),
+		zap.String("go", runtime.Version()),
+	}
+}
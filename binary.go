@@ -0,0 +1,62 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BinaryEncoding zap.Binary 字段的编码方式
+type BinaryEncoding int
+
+const (
+	BinaryBase64 BinaryEncoding = iota // 标准 base64，与 zap 默认一致
+	BinaryHex                          // 小写十六进制，便于对照协议报文
+)
+
+// BinaryPolicy zap.Binary 字段的编码方式与长度上限
+type BinaryPolicy struct {
+	Encoding BinaryEncoding
+	MaxBytes int // 编码前的最大字节数，超出部分省略并以 "...(+N bytes)" 标出，为 0 时不限制
+}
+
+// binaryPolicy 按 policy 将 zap.Binary 字段编码为字符串
+func binaryPolicy(policy BinaryPolicy) Processor {
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		var out []zapcore.Field
+		for i, f := range fields {
+			if f.Type != zapcore.BinaryType {
+				continue
+			}
+			if out == nil {
+				out = append([]zapcore.Field(nil), fields...)
+			}
+			out[i] = zap.String(f.Key, policy.encode(f.Interface.([]byte)))
+		}
+		if out == nil {
+			return ent, fields, true
+		}
+		return ent, out, true
+	}
+}
+
+func (p BinaryPolicy) encode(b []byte) string {
+	elided := 0
+	if p.MaxBytes > 0 && len(b) > p.MaxBytes {
+		elided = len(b) - p.MaxBytes
+		b = b[:p.MaxBytes]
+	}
+	var s string
+	if p.Encoding == BinaryHex {
+		s = hex.EncodeToString(b)
+	} else {
+		s = base64.StdEncoding.EncodeToString(b)
+	}
+	if elided > 0 {
+		s += fmt.Sprintf("...(+%d bytes)", elided)
+	}
+	return s
+}
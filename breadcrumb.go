@@ -0,0 +1,85 @@
+package log
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Breadcrumb 错误发生前的一条日志，字段与 Sentry 的 breadcrumb 对应
+type Breadcrumb struct {
+	Time     time.Time
+	Level    string // trace、debug、info 等
+	Category string // logger 名称
+	Message  string
+	Data     map[string]interface{}
+}
+
+// ErrorReporter 接收 Error 及以上级别的日志与此前 flight recorder 中的日志，用于上报到 Sentry 等错误追踪服务。
+// 在写入日志的 goroutine 中同步调用，上报应尽快返回。
+type ErrorReporter func(e Entry, breadcrumbs []Breadcrumb)
+
+// Breadcrumbs 按时间顺序返回 flight recorder 中最近的日志，未启用 WithFlightRecorder 时返回 nil
+func Breadcrumbs() []Breadcrumb {
+	if l == nil || l.recorder == nil {
+		return nil
+	}
+	return l.breadcrumbs()
+}
+
+func (l *Logger) breadcrumbs() []Breadcrumb {
+	if l.recorder == nil {
+		return nil
+	}
+	lines := l.recorder.snapshot()
+	crumbs := make([]Breadcrumb, 0, len(lines))
+	for _, line := range lines {
+		e, err := decodeEntry(l.zapConfig.EncoderConfig, line)
+		if err != nil {
+			continue
+		}
+		delete(e.Fields, "flight_recorder")
+		crumbs = append(crumbs, Breadcrumb{Time: e.Time, Level: levelName(e.Level), Category: e.LoggerName, Message: e.Message, Data: e.Fields})
+	}
+	return crumbs
+}
+
+// reporterCore 将 Error 及以上级别的日志连同 breadcrumb 交给 ErrorReporter。
+// 位于 recorderCore 外层，在 recorderCore 写出并清空缓冲前取得 breadcrumb。
+type reporterCore struct {
+	zapcore.Core
+	fields []zapcore.Field
+	report ErrorReporter
+	l      *Logger
+}
+
+func (c *reporterCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &reporterCore{Core: c.Core.With(fields), fields: all, report: c.report, l: c.l}
+}
+
+func (c *reporterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *reporterCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < zapcore.ErrorLevel {
+		return c.Core.Write(ent, fields)
+	}
+	crumbs := c.l.breadcrumbs()
+	err := c.Core.Write(ent, fields)
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	c.report(Entry{Entry: ent, Fields: enc.Fields}, crumbs)
+	return err
+}
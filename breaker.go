@@ -0,0 +1,92 @@
+package log
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 远端输出的熔断器打开，批次未发送
+var ErrCircuitOpen = errors.New("log: remote sink circuit open")
+
+// breaker 远端输出的熔断器：连续失败 failures 次后打开，cooldown 后进入半开状态，
+// 半开时只发送一次不重试的探测批次，成功则关闭，失败则重新打开
+type breaker struct {
+	failures int
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	count    int       // 连续失败次数
+	openedAt time.Time // 打开的时间，零值表示关闭
+	halfOpen bool
+}
+
+// allow 返回是否可以发送，probe 为 true 时表示本次为半开状态的探测，不应重试
+func (b *breaker) allow() (ok, probe bool) {
+	if b == nil {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true, false
+	}
+	if b.halfOpen || now().Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.halfOpen = true
+	return true, true
+}
+
+// record 记录一次发送的结果，返回熔断器是否处于打开状态
+func (b *breaker) record(success bool) (open bool) {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpen = false
+	if success {
+		b.count = 0
+		b.openedAt = time.Time{}
+		return false
+	}
+	b.count++
+	if b.count >= b.failures || !b.openedAt.IsZero() {
+		b.openedAt = now()
+	}
+	return !b.openedAt.IsZero()
+}
+
+func (b *breaker) state() string {
+	if b == nil {
+		return "closed"
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.openedAt.IsZero():
+		return "closed"
+	case b.halfOpen || now().Sub(b.openedAt) >= b.cooldown:
+		return "half-open"
+	}
+	return "open"
+}
+
+// SinkBreaker 为远端输出启用熔断器：连续 failures 次发送失败后打开，期间批次不再发送，
+// 直接写入死信（SinkDeadLetter）或保留在预写日志（SinkWAL）中；cooldown 后发送一次探测批次，
+// 成功后恢复。避免远端不可用时每个批次都等待连接超时与重试。
+func SinkBreaker(failures int, cooldown time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		if failures <= 0 {
+			failures = 1
+		}
+		o.breakerFailures = failures
+		o.breakerCooldown = cooldown
+	}
+}
+
+// BreakerState 返回熔断器的状态：closed、open 或 half-open，未启用 SinkBreaker 时为 closed
+func (s *RemoteSink) BreakerState() string {
+	return s.breaker.state()
+}
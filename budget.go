@@ -0,0 +1,136 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Budget 命名 logger 在每个周期内可写入的日志量，超出后低等级的日志被采样或丢弃
+type Budget struct {
+	Bytes    int64         // 每个周期的字节数上限，为 0 时不限制
+	Entries  int           // 每个周期的条数上限，为 0 时不限制
+	Period   time.Duration // 周期，为 0 时为 1 小时
+	MaxLevel zapcore.Level // 受预算限制的最高等级，零值为 Info，更高等级的日志始终写入
+	Sample   int           // 超出预算后每 Sample 条保留 1 条，为 0 时全部丢弃
+}
+
+// BudgetCounters 单个预算的统计
+type BudgetCounters struct {
+	Dropped  uint64 // 超出预算被丢弃的条数
+	Sampled  uint64 // 超出预算后采样保留的条数
+	Exceeded uint64 // 超出预算的周期数
+}
+
+// budgetState 单个预算的当前周期用量与统计
+type budgetState struct {
+	name   string
+	budget Budget
+
+	mu       sync.Mutex
+	start    time.Time
+	bytes    int64
+	entries  int
+	over     int // 本周期超出预算后的条数
+	counters BudgetCounters
+}
+
+// use 记录一条 size 字节的日志，返回是否写入，exceeded 为 true 时表示本周期刚超出预算
+func (s *budgetState) use(t time.Time, size int) (ok, exceeded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Sub(s.start) >= s.budget.Period {
+		s.start, s.bytes, s.entries, s.over = t, 0, 0, 0
+	}
+	b := s.budget
+	if (b.Entries == 0 || s.entries < b.Entries) && (b.Bytes == 0 || s.bytes+int64(size) <= b.Bytes) {
+		s.entries++
+		s.bytes += int64(size)
+		return true, false
+	}
+	s.over++
+	if s.over == 1 {
+		s.counters.Exceeded++
+	}
+	if b.Sample > 0 && s.over%b.Sample == 0 {
+		s.counters.Sampled++
+		return true, s.over == 1
+	}
+	s.counters.Dropped++
+	return false, s.over == 1
+}
+
+// budgetCore 按 logger 名称限制日志量，名称的预算同时作用于其下级，如 "ingest" 包含 "ingest.kafka"
+type budgetCore struct {
+	zapcore.Core
+	budgets map[string]*budgetState
+	enc     zapcore.Encoder // 计算日志大小，仅在设置了 Bytes 时使用
+	marker  zapcore.Core    // 写入超出预算的提示
+}
+
+func (c *budgetCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &budgetCore{Core: c.Core.With(fields), budgets: c.budgets, enc: enc, marker: c.marker}
+}
+
+func (c *budgetCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// state 返回 name 或其最近的上级的预算
+func (c *budgetCore) state(name string) *budgetState {
+	for name != "" {
+		if s, ok := c.budgets[name]; ok {
+			return s
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return nil
+}
+
+func (c *budgetCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	s := c.state(ent.LoggerName)
+	if s == nil || ent.Level > s.budget.MaxLevel {
+		return c.Core.Write(ent, fields)
+	}
+	size := 0
+	if s.budget.Bytes > 0 {
+		if buf, err := c.enc.EncodeEntry(ent, fields); err == nil {
+			size = buf.Len()
+			buf.Free()
+		}
+	}
+	ok, exceeded := s.use(ent.Time, size)
+	if exceeded {
+		c.marker.Write(zapcore.Entry{Level: zapcore.WarnLevel, Time: ent.Time, LoggerName: ent.LoggerName, Message: "[Budget] log budget exceeded"},
+			[]zap.Field{zap.String("budget", s.name), zap.Int64("bytes", s.budget.Bytes), zap.Int("entries", s.budget.Entries), zap.Duration("period", s.budget.Period)})
+	}
+	if !ok {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// budgetCounters 返回各预算的统计，键为 WithBudget 设置的名称
+func (l *Logger) budgetCounters() map[string]BudgetCounters {
+	counters := make(map[string]BudgetCounters, len(l.budgets))
+	for name, s := range l.budgets {
+		s.mu.Lock()
+		counters[name] = s.counters
+		s.mu.Unlock()
+	}
+	return counters
+}
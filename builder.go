@@ -0,0 +1,108 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldBuilder 以链式调用构造字段，由终结方法（Msg、Debug、Info、Warn、Error）写出后归还对象池
+type FieldBuilder struct {
+	fields []zap.Field
+}
+
+var builderPool = sync.Pool{
+	New: func() interface{} {
+		return &FieldBuilder{fields: make([]zap.Field, 0, 8)}
+	},
+}
+
+// F 从对象池取出一个 FieldBuilder，例如 log.F().Str("user", u).Int("count", n).Msg("done")
+func F() *FieldBuilder {
+	return builderPool.Get().(*FieldBuilder)
+}
+
+func (b *FieldBuilder) Str(key, val string) *FieldBuilder {
+	b.fields = append(b.fields, zap.String(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Int(key string, val int) *FieldBuilder {
+	b.fields = append(b.fields, zap.Int(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Int64(key string, val int64) *FieldBuilder {
+	b.fields = append(b.fields, zap.Int64(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Float(key string, val float64) *FieldBuilder {
+	b.fields = append(b.fields, zap.Float64(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Bool(key string, val bool) *FieldBuilder {
+	b.fields = append(b.fields, zap.Bool(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Dur(key string, val time.Duration) *FieldBuilder {
+	b.fields = append(b.fields, zap.Duration(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Time(key string, val time.Time) *FieldBuilder {
+	b.fields = append(b.fields, zap.Time(key, val))
+	return b
+}
+
+func (b *FieldBuilder) Err(err error) *FieldBuilder {
+	b.fields = append(b.fields, zap.Error(err))
+	return b
+}
+
+func (b *FieldBuilder) Any(key string, val interface{}) *FieldBuilder {
+	b.fields = append(b.fields, zap.Any(key, val))
+	return b
+}
+
+// Fields 追加已有的 zap.Field
+func (b *FieldBuilder) Fields(fields ...zap.Field) *FieldBuilder {
+	b.fields = append(b.fields, fields...)
+	return b
+}
+
+// Msg 以 Info 级别写出
+func (b *FieldBuilder) Msg(msg string) {
+	b.write(skipLogger().Check(zapcore.InfoLevel, msg))
+}
+
+func (b *FieldBuilder) Debug(msg string) {
+	b.write(skipLogger().Check(zapcore.DebugLevel, msg))
+}
+
+func (b *FieldBuilder) Info(msg string) {
+	b.write(skipLogger().Check(zapcore.InfoLevel, msg))
+}
+
+func (b *FieldBuilder) Warn(msg string) {
+	b.write(skipLogger().Check(zapcore.WarnLevel, msg))
+}
+
+func (b *FieldBuilder) Error(msg string) {
+	b.write(skipLogger().Check(zapcore.ErrorLevel, msg))
+}
+
+func (b *FieldBuilder) write(ce *zapcore.CheckedEntry) {
+	if ce != nil {
+		ce.Write(b.fields...)
+	}
+	for i := range b.fields {
+		b.fields[i] = zap.Field{}
+	}
+	b.fields = b.fields[:0]
+	builderPool.Put(b)
+}
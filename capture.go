@@ -0,0 +1,138 @@
+package log
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// captureList 需要采集调试日志的用户或租户 ID
+type captureList struct {
+	mu  sync.RWMutex
+	ids map[string]struct{}
+}
+
+var captureIDs = &captureList{ids: map[string]struct{}{}}
+
+func (c *captureList) has(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.ids[id]
+	return ok
+}
+
+func (c *captureList) empty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.ids) == 0
+}
+
+// AddDebugCapture 将 ids 加入采集名单，这些用户或租户的日志从 Debug 起写入 WithDebugCapture 设置的文件
+func AddDebugCapture(ids ...string) {
+	captureIDs.mu.Lock()
+	defer captureIDs.mu.Unlock()
+	for _, id := range ids {
+		captureIDs.ids[id] = struct{}{}
+	}
+}
+
+// RemoveDebugCapture 将 ids 移出采集名单
+func RemoveDebugCapture(ids ...string) {
+	captureIDs.mu.Lock()
+	defer captureIDs.mu.Unlock()
+	for _, id := range ids {
+		delete(captureIDs.ids, id)
+	}
+}
+
+// DebugCaptureIDs 返回当前的采集名单
+func DebugCaptureIDs() []string {
+	captureIDs.mu.RLock()
+	ids := make([]string, 0, len(captureIDs.ids))
+	for id := range captureIDs.ids {
+		ids = append(ids, id)
+	}
+	captureIDs.mu.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// captureCore 将 keys 字段的值在采集名单中的日志另外写入采集文件，主日志仍按原等级过滤
+type captureCore struct {
+	zapcore.Core
+	capture zapcore.Core
+	keys    []string
+	id      string // With 添加的字段中的 ID
+}
+
+func (c *captureCore) With(fields []zapcore.Field) zapcore.Core {
+	id := c.id
+	if id == "" {
+		id = captureID(c.keys, fields)
+	}
+	return &captureCore{Core: c.Core.With(fields), capture: c.capture.With(fields), keys: c.keys, id: id}
+}
+
+func (c *captureCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write processorCore 不向内传递 With 的字段，因此同时在 fields 中查找 ID
+func (c *captureCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	id := c.id
+	if id == "" {
+		id = captureID(c.keys, fields)
+	}
+	var err error
+	if id != "" && ent.Level >= zapcore.DebugLevel && captureIDs.has(id) {
+		err = c.capture.Write(ent, fields)
+	}
+	if werr := c.Core.Write(ent, fields); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func (c *captureCore) Sync() error {
+	err := c.Core.Sync()
+	if serr := c.capture.Sync(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// captureID 返回 fields 中第一个 keys 字段的值，支持字符串与整数
+func captureID(keys []string, fields []zapcore.Field) string {
+	for _, f := range fields {
+		for _, key := range keys {
+			if f.Key != key {
+				continue
+			}
+			switch f.Type {
+			case zapcore.StringType:
+				return f.String
+			case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+				return strconv.FormatInt(f.Integer, 10)
+			case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+				return strconv.FormatUint(uint64(f.Integer), 10)
+			}
+		}
+	}
+	return ""
+}
+
+// captureFile 创建采集文件的 core，所有等级均写入，由 captureCore 决定写入哪些日志
+func (l *Logger) captureFile(enc zapcore.Encoder) zapcore.Core {
+	fileName := l.logFileName(l.Opts.CaptureFileName)
+	rc := newRotationCounter(l.rotateLogger(fileName), l.Opts.Clock, nil)
+	l.auxFiles = append(l.auxFiles, rc)
+	ws := l.chainWriter(l.rotateWriter(rc), fileName)
+	all := zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+	return zapcore.NewCore(enc, l.stats.sink("capture", ws, probeFiles(fileName)), all)
+}
@@ -0,0 +1,11 @@
+//go:build go1.20
+// +build go1.20
+
+package log
+
+import "context"
+
+// contextCause 返回 ctx 结束的原因，即 context.Cause
+func contextCause(ctx context.Context) error {
+	return context.Cause(ctx)
+}
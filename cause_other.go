@@ -0,0 +1,11 @@
+//go:build !go1.20
+// +build !go1.20
+
+package log
+
+import "context"
+
+// contextCause Go 1.20 之前没有 context.Cause，返回 ctx.Err()
+func contextCause(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,125 @@
+// logctl 通过 log.AdminHandler 提供的运维接口查看与修改日志等级、切割日志文件、查看统计及实时查看日志。
+//
+//	logctl [-addr URL] level                    查看全局等级与各命名 logger 的等级
+//	logctl [-addr URL] level debug              设置全局等级
+//	logctl [-addr URL] level db.pool debug      设置命名 logger 的等级
+//	logctl [-addr URL] reset db.pool            取消命名 logger 的等级设置
+//	logctl [-addr URL] rotate                   立即切割日志文件
+//	logctl [-addr URL] stats                    查看日志统计
+//	logctl [-addr URL] tail [-level L] [-regex R]  实时查看日志
+//
+// addr 默认取环境变量 LOGCTL_ADDR，为 AdminHandler 挂载的地址，如 http://127.0.0.1:6060/debug/log。
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", os.Getenv("LOGCTL_ADDR"), "AdminHandler 的地址")
+	flag.Usage = usage
+	flag.Parse()
+	if *addr == "" || flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(strings.TrimRight(*addr, "/"), flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "logctl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: logctl [-addr URL] level [logger] [level] | reset logger | rotate | stats | tail [-level L] [-regex R]")
+	flag.PrintDefaults()
+}
+
+func run(addr, cmd string, args []string) error {
+	switch cmd {
+	case "level":
+		switch len(args) {
+		case 0:
+			return call(http.MethodGet, addr+"/level", nil)
+		case 1:
+			return call(http.MethodPut, addr+"/level", url.Values{"level": {args[0]}})
+		case 2:
+			return call(http.MethodPut, addr+"/level", url.Values{"logger": {args[0]}, "level": {args[1]}})
+		}
+	case "reset":
+		if len(args) == 1 {
+			return call(http.MethodDelete, addr+"/level", url.Values{"logger": {args[0]}})
+		}
+	case "rotate":
+		return call(http.MethodPost, addr+"/rotate", nil)
+	case "stats":
+		return call(http.MethodGet, addr+"/stats", nil)
+	case "tail":
+		fs := flag.NewFlagSet("tail", flag.ExitOnError)
+		level := fs.String("level", "", "最低日志等级")
+		regex := fs.String("regex", "", "按正则过滤日志内容")
+		fs.Parse(args)
+		q := url.Values{}
+		if *level != "" {
+			q.Set("level", *level)
+		}
+		if *regex != "" {
+			q.Set("regex", *regex)
+		}
+		return tail(addr+"/stream", q)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	return fmt.Errorf("invalid arguments for %s", cmd)
+}
+
+// call 发送请求并将响应输出到标准输出
+func call(method, u string, q url.Values) error {
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+// tail 读取 SSE 流，每条日志输出为一行 JSON
+func tail(u string, q url.Values) error {
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if data := strings.TrimPrefix(scanner.Text(), "data: "); data != scanner.Text() {
+			fmt.Println(data)
+		}
+	}
+	return scanner.Err()
+}
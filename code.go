@@ -0,0 +1,63 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CodeInfo 错误码的说明
+type CodeInfo struct {
+	Description string // 错误说明
+	Remediation string // 处理建议
+	DocURL      string // 文档链接
+}
+
+var (
+	codeMu sync.RWMutex
+	codes  = map[string]CodeInfo{}
+)
+
+// RegisterCode 注册错误码的说明
+func RegisterCode(code string, info CodeInfo) {
+	codeMu.Lock()
+	defer codeMu.Unlock()
+	codes[code] = info
+}
+
+// RegisterCodes 批量注册错误码的说明
+func RegisterCodes(catalog map[string]CodeInfo) {
+	codeMu.Lock()
+	defer codeMu.Unlock()
+	for code, info := range catalog {
+		codes[code] = info
+	}
+}
+
+type codeField string
+
+func (c codeField) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("code", string(c))
+	codeMu.RLock()
+	info, ok := codes[string(c)]
+	codeMu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if info.Description != "" {
+		enc.AddString("code_description", info.Description)
+	}
+	if info.Remediation != "" {
+		enc.AddString("code_remediation", info.Remediation)
+	}
+	if info.DocURL != "" {
+		enc.AddString("code_doc", info.DocURL)
+	}
+	return nil
+}
+
+// Code 返回 code 字段，并附带已注册的 code_description、code_remediation 与 code_doc
+func Code(code string) zap.Field {
+	return zap.Inline(codeField(code))
+}
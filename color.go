@@ -0,0 +1,44 @@
+package log
+
+import (
+	"os"
+	"strings"
+)
+
+// ColorMode 控制台颜色模式
+type ColorMode int
+
+const (
+	ColorAuto   ColorMode = iota // 根据 NO_COLOR/FORCE_COLOR 及是否为终端自动判断
+	ColorAlways                  // 总是输出颜色
+	ColorNever                   // 从不输出颜色
+)
+
+// useColor reports whether colored output should be written to f.
+func useColor(mode ColorMode, f *os.File) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if v, ok := os.LookupEnv("FORCE_COLOR"); ok {
+		switch strings.ToLower(v) {
+		case "0", "false", "no", "off":
+			return false
+		}
+		return true
+	}
+	return isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
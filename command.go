@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CommandLogger 将子进程的 stdout 与 stderr 按行以 level 写入日志，附带 cmd、pid 与 stream 字段，
+// 需在 cmd.Start 或 cmd.Run 之前调用。返回的 flush 在 cmd.Wait 之后调用，写入末尾没有换行的内容。
+func CommandLogger(cmd *exec.Cmd, level zapcore.Level) (flush func()) {
+	fields := func(stream string) func() []zap.Field {
+		return func() []zap.Field {
+			pid := 0
+			if cmd.Process != nil {
+				pid = cmd.Process.Pid
+			}
+			return []zap.Field{zap.String("cmd", filepath.Base(cmd.Path)), zap.Int("pid", pid), zap.String("stream", stream)}
+		}
+	}
+	stdout := &lineWriter{level: level, fields: fields("stdout")}
+	stderr := &lineWriter{level: level, fields: fields("stderr")}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+// lineWriter 将写入的内容按行记录为日志
+type lineWriter struct {
+	level  zapcore.Level
+	fields func() []zap.Field
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(bytes.TrimSuffix(w.buf[:i], []byte("\r")))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.log(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) log(line []byte) {
+	current().Check(w.level, string(line)).Write(w.fields()...)
+}
@@ -0,0 +1,136 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Config 返回当前 logger 生效的配置：默认值、各 Option 与 LevelStateFile 中保存的等级均已应用，
+// Level 为运行时 SetLevel 后的全局等级，Console 为实际是否输出到控制台。未调用 NewLogger 时返回默认配置。
+// 返回值中的切片与 map 与 logger 共用，不得修改。
+func Config() Options {
+	if l == nil || l.Opts == nil {
+		return *defaultOptions()
+	}
+	l.RLock()
+	defer l.RUnlock()
+	opts := *l.Opts
+	opts.Config = l.zapConfig
+	opts.Level = l.zapConfig.Level.Level()
+	console := opts.consoleEnabled()
+	opts.Console = &console
+	return opts
+}
+
+// Validate 检查当前 logger 的配置，返回相互冲突或无效的设置，NewLogger 初始化时以 Warn 记录
+func Validate() []error {
+	return Config().Validate()
+}
+
+// consoleEnabled 返回是否输出到控制台，Console 为空时仅开发模式输出
+func (o *Options) consoleEnabled() bool {
+	if o.Console != nil {
+		return *o.Console
+	}
+	return o.Development
+}
+
+// Validate 检查配置，返回相互冲突或无效的设置，没有问题时返回 nil
+func (o Options) Validate() []error {
+	var errs []error
+	report := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("log: "+format, args...))
+	}
+
+	levelFiles := map[string]string{
+		"ErrorFileName": o.ErrorFileName,
+		"WarnFileName":  o.WarnFileName,
+		"InfoFileName":  o.InfoFileName,
+		"DebugFileName": o.DebugFileName,
+	}
+	seen := map[string]string{}
+	if o.File {
+		seen[o.FileName] = "FileName"
+	}
+	for _, key := range []string{"ErrorFileName", "WarnFileName", "InfoFileName", "DebugFileName"} {
+		name := levelFiles[key]
+		if name == "" {
+			continue
+		}
+		if o.Merge {
+			report("Merge is set, %s is ignored: merged logs are not split by level", key)
+		}
+		if prev, ok := seen[name]; ok {
+			report("%s and %s both write to %q", prev, key, name)
+		}
+		seen[name] = key
+	}
+
+	if o.File && o.MaxSize <= 0 {
+		report("MaxSize is %d, files rotate at lumberjack's default of 100MB", o.MaxSize)
+	}
+	if o.MaxBackups < 0 || o.MaxAge < 0 {
+		report("negative retention: MaxBackups %d, MaxAge %d", o.MaxBackups, o.MaxAge)
+	}
+	if !o.File && !o.consoleEnabled() && len(o.Sinks) == 0 {
+		report("file, console and sinks are all disabled, logs are discarded")
+	}
+	if o.ConsoleLevel != nil && !o.consoleEnabled() {
+		report("ConsoleLevel is set but console output is disabled")
+	}
+	if o.ConsoleEncoding != "" && o.ConsoleEncoding != "console" && o.ConsoleEncoding != "json" {
+		report("unknown ConsoleEncoding %q", o.ConsoleEncoding)
+	}
+	if o.Shipper != nil && !o.File {
+		report("Shipper is set but file output is disabled, nothing is shipped")
+	}
+	if (len(o.ReopenSignals) > 0 || o.ReopenCheck > 0) && !o.File {
+		report("reopen is set but file output is disabled")
+	}
+	if o.LowLatency && o.PreallocateSize < 0 {
+		report("negative PreallocateSize %d", o.PreallocateSize)
+	}
+	if o.AsyncQueueSize < 0 {
+		report("negative AsyncQueueSize %d", o.AsyncQueueSize)
+	}
+	if o.CaptureFileName != "" && len(o.CaptureKeys) == 0 {
+		report("CaptureFileName is set without CaptureKeys, nothing is captured")
+	}
+	if (o.ShadowEncoder == nil) != (o.ShadowWriter == nil) {
+		report("shadow output needs both ShadowEncoder and ShadowWriter")
+	}
+	if o.DumpDedupWindow < 0 || o.Heartbeat < 0 || o.RuntimeStats < 0 || o.GroupCommit < 0 {
+		report("negative interval: DumpDedupWindow %v, Heartbeat %v, RuntimeStats %v, GroupCommit %v",
+			o.DumpDedupWindow, o.Heartbeat, o.RuntimeStats, o.GroupCommit)
+	}
+	if o.Shipper != nil && o.EncryptionKey != nil {
+		report("Shipper forwards encrypted log files when EncryptionKey is set")
+	}
+	return errs
+}
+
+// defaultOptions 返回 NewLogger 在应用 Option 前使用的默认配置
+func defaultOptions() *Options {
+	opts := &Options{
+		LogFileDir: "",
+		AppName:    "app",
+		FileName:   ".log",
+		Level:      zapcore.DebugLevel,
+		MaxSize:    100,
+		MaxBackups: 60,
+		MaxAge:     30,
+		Compress:   false,
+		File:       true,
+		Clock:      zapcore.DefaultClock,
+
+		DumpNaming:     "exceptions.15_04_05",
+		DumpNestByDate: true,
+	}
+	if opts.LogFileDir == "" {
+		opts.LogFileDir, _ = filepath.Abs(filepath.Dir(filepath.Join(".")))
+		opts.LogFileDir += sp + "logs" + sp
+	}
+	return opts
+}
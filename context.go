@@ -0,0 +1,51 @@
+package log
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// NewContext 返回携带 logger 的 context
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// BaggageFunc 从 ctx 中读取 key 对应的 baggage 值，不存在时返回空字符串
+type BaggageFunc func(ctx context.Context, key string) string
+
+// FromContext 返回 ctx 中的 logger，没有时返回全局 logger。
+// 启用 WithPprofLabels 时会附带 ctx 中对应的 pprof 标签，便于与 CPU profile 关联；
+// 启用 WithBaggage 时会附带 ctx 中对应的 baggage；启用 WithSpanEvents 时日志可写入 ctx 中的 span。
+func FromContext(ctx context.Context) *zap.Logger {
+	logger, ok := ctx.Value(ctxKey{}).(*zap.Logger)
+	if !ok {
+		logger = current()
+	}
+	if l == nil {
+		return logger
+	}
+	var fields []zap.Field
+	for _, key := range l.Opts.PprofLabels {
+		if v, ok := pprof.Label(ctx, key); ok {
+			fields = append(fields, zap.String(key, v))
+		}
+	}
+	if l.Opts.Baggage != nil {
+		for _, key := range l.Opts.BaggageKeys {
+			if v := l.Opts.Baggage(ctx, key); v != "" {
+				fields = append(fields, zap.String(key, v))
+			}
+		}
+	}
+	if l.Opts.SpanLogger != nil {
+		fields = append(fields, spanField(ctx))
+	}
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldProviderCore 在写入时追加动态字段
+type fieldProviderCore struct {
+	zapcore.Core
+	providers []func() []zap.Field
+}
+
+func (c *fieldProviderCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fieldProviderCore{Core: c.Core.With(fields), providers: c.providers}
+}
+
+func (c *fieldProviderCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fieldProviderCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields = fields[:len(fields):len(fields)]
+	for _, provider := range c.providers {
+		fields = append(fields, provider()...)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// Processor 在编码前处理日志，可修改 Entry 与字段，返回 false 时丢弃该条日志
+type Processor func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool)
+
+// processorCore 在写入前依次执行 Processor。With 添加的字段不会提前编码，
+// 而是在写入时与本条日志的字段一起交给 Processor 处理。
+type processorCore struct {
+	zapcore.Core
+	fields     []zapcore.Field
+	processors []Processor
+}
+
+func (c *processorCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &processorCore{Core: c.Core, fields: all, processors: c.processors}
+}
+
+func (c *processorCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *processorCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	ok := true
+	for _, p := range c.processors {
+		if ent, all, ok = p(ent, all); !ok {
+			return nil
+		}
+	}
+	return c.Core.Write(ent, all)
+}
+
+// multiCore 与 zapcore.NewTee 相同，但 Write 时只写入已开启对应等级的 core，
+// 使外层包装的 core 直接调用 Write 时仍遵循各输出自身的日志等级。
+type multiCore []zapcore.Core
+
+func newMultiCore(cores ...zapcore.Core) zapcore.Core {
+	return multiCore(cores)
+}
+
+func (mc multiCore) Enabled(lvl zapcore.Level) bool {
+	for _, c := range mc {
+		if c.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mc multiCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := make(multiCore, len(mc))
+	for i, c := range mc {
+		clone[i] = c.With(fields)
+	}
+	return clone
+}
+
+func (mc multiCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	for _, c := range mc {
+		ce = c.Check(ent, ce)
+	}
+	return ce
+}
+
+func (mc multiCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var err error
+	for _, c := range mc {
+		if !c.Enabled(ent.Level) {
+			continue
+		}
+		if e := c.Write(ent, fields); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (mc multiCore) Sync() error {
+	var err error
+	for _, c := range mc {
+		if e := c.Sync(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// NewUUID 生成随机（v4）UUID，可作为 WithCorrelationID 的生成器
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// newID 使用 WithCorrelationID 的生成器生成 ID，未设置时使用 NewUUID
+func newID() string {
+	if l != nil && l.Opts.CorrelationID != nil {
+		return l.Opts.CorrelationID()
+	}
+	return NewUUID()
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID 生成按时间排序的 ULID（48 位毫秒时间戳 + 80 位随机数），可作为 WithCorrelationID 的生成器
+func NewULID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(now().UnixNano()/1e6)<<16)
+	rand.Read(b[6:])
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	// 128 位按 5 位一组编码为 26 个字符，首字符只使用最高 3 位
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// KeyFunc 返回 AES 密钥（16/24/32 字节），可对接环境变量或 KMS
+type KeyFunc func() ([]byte, error)
+
+// KeyFromEnv 从环境变量读取 hex 或 base64 编码的密钥
+func KeyFromEnv(name string) KeyFunc {
+	return func() ([]byte, error) {
+		v := os.Getenv(name)
+		if v == "" {
+			return nil, fmt.Errorf("log: encryption key env %s is empty", name)
+		}
+		if key, err := hex.DecodeString(v); err == nil {
+			return key, nil
+		}
+		return base64.StdEncoding.DecodeString(v)
+	}
+}
+
+// encryptWriteSyncer 将每次写入加密为一帧：4 字节大端长度 + nonce + 密文
+type encryptWriteSyncer struct {
+	zapcore.WriteSyncer
+	aead cipher.AEAD
+}
+
+// NewEncryptWriteSyncer 返回以 AES-GCM 加密写入内容的 WriteSyncer
+func NewEncryptWriteSyncer(ws zapcore.WriteSyncer, key []byte) (zapcore.WriteSyncer, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriteSyncer{WriteSyncer: ws, aead: aead}, nil
+}
+
+func (w *encryptWriteSyncer) Write(p []byte) (int, error) {
+	nonceSize := w.aead.NonceSize()
+	frame := make([]byte, 4+nonceSize, 4+nonceSize+len(p)+w.aead.Overhead())
+	if _, err := rand.Read(frame[4 : 4+nonceSize]); err != nil {
+		return 0, err
+	}
+	frame = w.aead.Seal(frame, frame[4:4+nonceSize], p, nil)
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(frame)-4))
+	if _, err := w.WriteSyncer.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type decryptReader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte
+}
+
+// NewDecryptReader 返回解密 NewEncryptWriteSyncer 所写文件的 Reader
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: bufio.NewReader(r), aead: aead}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(d.r, size[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, errors.New("log: truncated encrypted frame")
+			}
+			return 0, err
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(d.r, frame); err != nil {
+			return 0, errors.New("log: truncated encrypted frame")
+		}
+		nonceSize := d.aead.NonceSize()
+		if len(frame) < nonceSize {
+			return 0, errors.New("log: malformed encrypted frame")
+		}
+		plain, err := d.aead.Open(nil, frame[:nonceSize], frame[nonceSize:], nil)
+		if err != nil {
+			return 0, err
+		}
+		d.buf = plain
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
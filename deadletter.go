@@ -0,0 +1,151 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// deadLetter 死信文件中的一行
+type deadLetter struct {
+	Time  time.Time       `json:"time"`
+	Error string          `json:"error"`
+	Entry json.RawMessage `json:"entry"`
+}
+
+// writeDeadLetter 将批次中的每条日志连同错误信息追加到死信文件
+func writeDeadLetter(path string, batch []byte, cause error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	now := time.Now()
+	for _, line := range bytes.Split(batch, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		entry := json.RawMessage(line)
+		if !json.Valid(line) {
+			entry, _ = json.Marshal(string(line))
+		}
+		if err := enc.Encode(deadLetter{Time: now, Error: cause.Error(), Entry: entry}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replayRequest 交给后台 goroutine 的重放请求
+type replayRequest struct {
+	path  string // 已移开的死信文件
+	dest  string // 发送失败时写回未发送日志的死信文件
+	errCh chan error
+}
+
+// Replay 重新发送死信文件 path 中的日志。文件先被原子地改名移开，此后的死信写入新的 path，
+// 发送在后台 goroutine 中进行，不与正常的批次并发。发送失败时未发送的日志写回 path，且不会再次写入死信。
+func (s *RemoteSink) Replay(path string) error {
+	aside := fmt.Sprintf("%s.replay-%d", path, time.Now().UnixNano())
+	if err := os.Rename(path, aside); err != nil {
+		return err
+	}
+	req := replayRequest{path: aside, dest: path, errCh: make(chan error, 1)}
+	select {
+	case s.replays <- req:
+		return <-req.errCh
+	case <-s.stopped:
+		// 后台已停止，放回原处
+		if err := appendDeadLetters(path, aside); err != nil {
+			return err
+		}
+		return ErrSinkClosed
+	}
+}
+
+// replay 在后台 goroutine 中发送移开的死信文件，失败时将未发送的部分写回 dest
+func (s *RemoteSink) replay(req replayRequest) error {
+	data, err := os.ReadFile(req.path)
+	if err != nil {
+		return err
+	}
+	var batch []byte
+	sent, offset := 0, 0
+	flush := func() error {
+		if err := s.sendWithRetry(batch); err != nil {
+			return err
+		}
+		batch, sent = nil, offset
+		return nil
+	}
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		offset += len(line)
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var dl deadLetter
+		if err = json.Unmarshal(line, &dl); err != nil {
+			break
+		}
+		entry := []byte(dl.Entry)
+		var raw string
+		if json.Unmarshal(entry, &raw) == nil {
+			entry = []byte(raw)
+		}
+		batch = append(append(batch, entry...), '\n')
+		if len(batch) >= s.opts.batchBytes {
+			if err = flush(); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil && sent < len(data) {
+		if werr := appendFile(req.dest, data[sent:]); werr != nil {
+			return fmt.Errorf("%v; restore dead letter: %v", err, werr)
+		}
+	}
+	os.Remove(req.path)
+	return err
+}
+
+// appendDeadLetters 将移开的死信文件 aside 追加回 path 并删除 aside
+func appendDeadLetters(path, aside string) error {
+	data, err := os.ReadFile(aside)
+	if err != nil {
+		return err
+	}
+	if err := appendFile(path, data); err != nil {
+		return err
+	}
+	return os.Remove(aside)
+}
+
+// appendFile 将 data 追加到 path
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
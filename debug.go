@@ -0,0 +1,117 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DebugTrigger 判断请求是否需要按最详细的等级记录日志
+type DebugTrigger func(r *http.Request) bool
+
+// DebugHeader 请求头 name 的值等于 token 时触发，token 为空时只要求请求头存在
+func DebugHeader(name, token string) DebugTrigger {
+	return func(r *http.Request) bool {
+		v := r.Header.Get(name)
+		if token == "" {
+			return v != ""
+		}
+		return v == token
+	}
+}
+
+// DebugTraceSampled W3C traceparent 请求头带有 sampled 标志时触发
+func DebugTraceSampled() DebugTrigger {
+	return func(r *http.Request) bool {
+		parts := strings.Split(r.Header.Get("traceparent"), "-")
+		if len(parts) < 4 {
+			return false
+		}
+		flags, err := strconv.ParseUint(parts[3], 16, 8)
+		return err == nil && flags&1 == 1
+	}
+}
+
+// debugMarker 标记按需调试的 logger，levelGateCore 据此放行低于生效等级的日志
+type debugMarker struct{}
+
+// debugField 按需调试的 logger 附带的字段，输出为 "debug_on_demand":true
+func debugField() zap.Field {
+	f := zap.Bool("debug_on_demand", true)
+	f.Interface = debugMarker{}
+	return f
+}
+
+func hasDebugMarker(fields []zapcore.Field) bool {
+	for _, f := range fields {
+		if f.Type == zapcore.BoolType && f.Interface == (debugMarker{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForceDebug 返回携带按需调试 logger 的 context，下游通过 FromContext 记录的日志不受日志等级限制。
+// 需启用 WithDebugOnDemand，否则低于全局等级的日志在进入 logger 前即被丢弃。
+func ForceDebug(ctx context.Context) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(debugField()))
+}
+
+// debugRequested 判断请求是否命中 WithDebugOnDemand 的任一触发条件
+func debugRequested(r *http.Request) bool {
+	if l == nil {
+		return false
+	}
+	for _, trigger := range l.Opts.DebugTriggers {
+		if trigger(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// debugValue DebugField 的求值函数
+type debugValue func() interface{}
+
+// DebugField 返回仅在 logger 的生效等级为 Debug 或更低（含按需调试）时才求值并输出的字段，
+// 可在 Info 等日志上附带代价较高的诊断信息，生产环境不产生开销。fn 在调用方的 goroutine 上、
+// 处理器（脱敏、字段限制等）执行之前求值，启用 WithAsync 时也不会在调用返回后读取调用方的状态。
+//
+//	log.Info("order placed", log.DebugField("cart", func() interface{} { return cart.Dump() }))
+func DebugField(key string, fn func() interface{}) zap.Field {
+	return zap.Field{Key: key, Type: zapcore.SkipType, Interface: debugValue(fn)}
+}
+
+func isDebugField(f zapcore.Field) bool {
+	if f.Type != zapcore.SkipType {
+		return false
+	}
+	_, ok := f.Interface.(debugValue)
+	return ok
+}
+
+func hasDebugFields(fields []zapcore.Field) bool {
+	for _, f := range fields {
+		if isDebugField(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDebugFields verbose 为 true 时将 DebugField 替换为求值后的字段，否则去除
+func resolveDebugFields(fields []zapcore.Field, verbose bool) []zapcore.Field {
+	out := fields[:0]
+	for _, f := range fields {
+		if !isDebugField(f) {
+			out = append(out, f)
+		} else if verbose {
+			out = append(out, zap.Any(f.Key, f.Interface.(debugValue)()))
+		}
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Decoder 逐行解析本包输出的 JSON 日志，可用于回放到其它输出或离线分析。
+// 同时支持生产模式（ts、level、msg）与开发模式（T、L、M）的字段名，无法解析的行被跳过。
+type Decoder struct {
+	scanner *bufio.Scanner
+	cfg     zapcore.EncoderConfig
+	raw     []byte
+}
+
+// NewDecoder 返回从 r 读取日志的 Decoder
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &Decoder{scanner: scanner, cfg: zap.NewProductionEncoderConfig()}
+}
+
+// Next 返回下一条日志，读完时返回 io.EOF
+func (d *Decoder) Next() (Entry, error) {
+	for d.scanner.Scan() {
+		line := d.scanner.Bytes()
+		e, err := decodeEntry(d.cfg, line)
+		if err != nil {
+			continue
+		}
+		d.raw = line
+		return e, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Entry{}, err
+	}
+	return Entry{}, io.EOF
+}
+
+// Raw 返回 Next 最近返回的日志的原始 JSON，在下一次调用 Next 前有效
+func (d *Decoder) Raw() []byte {
+	return d.raw
+}
+
+// DecodeFile 按顺序解析日志文件 path 中的日志，支持 gzip 压缩的归档；启用 WithEncryption 时先解密。
+// fn 返回错误时停止并返回该错误。
+func DecodeFile(path string, fn func(e Entry, raw []byte) error) error {
+	r, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d := NewDecoder(r)
+	if l != nil && l.Logger != nil {
+		d.cfg = l.zapConfig.EncoderConfig
+	}
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(e, d.Raw()); err != nil {
+			return err
+		}
+	}
+}
+
+// openLogFile 打开日志文件，按需解压与解密，返回值关闭时同时关闭 gzip reader 与文件
+func openLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rc := readCloser{f, f}
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		rc = readCloser{gz, closers{gz, f}}
+	}
+	if l != nil && l.Opts.EncryptionKey != nil {
+		key, err := l.Opts.EncryptionKey()
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		if rc.Reader, err = NewDecryptReader(rc.Reader, key); err != nil {
+			rc.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// closers 依次关闭各 Closer，返回第一个错误
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var first error
+	for _, closer := range c {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// developmentKeys 开发模式下日志的字段名
+var developmentKeys = zap.NewDevelopmentEncoderConfig()
+
+// decodeEntry 将一行 JSON 日志解析为 Entry，缺少 cfg 的消息字段时按开发模式的字段名解析
+func decodeEntry(cfg zapcore.EncoderConfig, line []byte) (Entry, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(line, &m); err != nil {
+		return Entry{}, err
+	}
+	if _, ok := m[cfg.MessageKey]; !ok {
+		if _, ok := m[developmentKeys.MessageKey]; ok {
+			cfg = developmentKeys
+		}
+	}
+	var e Entry
+	take := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		v, _ := m[key].(string)
+		delete(m, key)
+		return v
+	}
+	if ts := take(cfg.TimeKey); ts != "" {
+		e.Time, _ = time.ParseInLocation("2006-01-02 15:04:05.000", ts, time.Local)
+	}
+	if lvl := take(cfg.LevelKey); lvl != "" {
+		e.Level, _ = parseLevel(lvl)
+	}
+	e.Message = take(cfg.MessageKey)
+	e.LoggerName = take(cfg.NameKey)
+	if caller := take(cfg.CallerKey); caller != "" {
+		e.Caller = zapcore.EntryCaller{Defined: true, File: caller}
+	}
+	e.Stack = take(cfg.StacktraceKey)
+	e.Fields = m
+	return e, nil
+}
@@ -0,0 +1,526 @@
+package log
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// startTime 进程启动时间
+var startTime = time.Now()
+
+// buildInfo 返回程序版本与 VCS 版本号，WithVersion 设置的版本优先
+func buildInfo() (version, revision string) {
+	if l != nil {
+		version = l.Opts.Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, ""
+	}
+	if version == "" {
+		version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			revision = s.Value
+		}
+	}
+	return version, revision
+}
+
+// dumpCleaner 定期清理过期及超出数量的 exception dump 文件
+func (l *Logger) dumpCleaner() {
+	ticker := l.Opts.Clock.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		cleanDumps(exceptionsDir(), l.Opts.DumpNaming, time.Duration(l.Opts.DumpMaxAge)*24*time.Hour, l.Opts.DumpMaxCount)
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// diagnosticSuffix 诊断 dump 文件名中时间之后的后缀，与崩溃 dump 区分
+const diagnosticSuffix = ".diagnostic"
+
+// isDumpName 判断文件名是否为 newDumpFile 按 naming 生成的 dump 文件名（含诊断 dump），
+// 使 dump 目录与日志目录相同时不会误删、误发日志文件
+func isDumpName(name, naming string) bool {
+	base := strings.TrimSuffix(name, ".log")
+	if base == name {
+		if base = strings.TrimSuffix(name, ".json"); base == name {
+			return false
+		}
+	}
+	base = strings.TrimSuffix(base, diagnosticSuffix)
+	if _, err := time.Parse(naming, base); err == nil {
+		return true
+	}
+	// 同一时间的 dump 带有 _N 后缀
+	i := strings.LastIndexByte(base, '_')
+	if i < 0 {
+		return false
+	}
+	if _, err := strconv.Atoi(base[i+1:]); err != nil {
+		return false
+	}
+	_, err := time.Parse(naming, base[:i])
+	return err == nil
+}
+
+// isDumpDateDir 判断目录名是否为按日期分目录时的 YYYY-MM-DD
+func isDumpDateDir(name string) bool {
+	_, err := time.Parse("2006-01-02", name)
+	return err == nil
+}
+
+// cleanDumps 删除 dir 下早于 maxAge 的 dump 文件，并只保留最新的 maxCount 个，为 0 时不限制。
+// 只处理文件名符合 naming 的 dump 文件与按日期分的空目录。
+func cleanDumps(dir, naming string, maxAge time.Duration, maxCount int) {
+	type dumpFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []dumpFile
+	var dirs []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && isDumpDateDir(info.Name()) {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		if !isDumpName(info.Name(), naming) {
+			return nil
+		}
+		files = append(files, dumpFile{path: path, modTime: info.ModTime()})
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	now := now()
+	for i, f := range files {
+		if (maxAge > 0 && now.Sub(f.modTime) > maxAge) || (maxCount > 0 && i >= maxCount) {
+			os.Remove(f.path)
+		}
+	}
+	// 由深到浅删除空目录
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// allStacks 返回所有 goroutine 的堆栈
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// runtimeSummary 返回 goroutine 数量与内存等运行时信息
+func runtimeSummary() string {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return fmt.Sprintf(`===============================================================================
+GOROUTINES: %d
+CPUS: %d
+HEAP ALLOC: %d
+HEAP SYS: %d
+NUM GC: %d
+===============================================================================`,
+		runtime.NumGoroutine(),
+		runtime.NumCPU(),
+		m.HeapAlloc,
+		m.HeapSys,
+		m.NumGC)
+}
+
+// diagnosticSignals 收到指定信号时写入诊断 dump
+func (l *Logger) diagnosticSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, l.Opts.DiagnosticSignals...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-l.done:
+			return
+		case sig := <-ch:
+			path, err := l.writeDiagnosticDump(sig.String())
+			if err != nil {
+				Error("[Diagnostic] write dump failed", zap.String("signal", sig.String()), zap.Error(err))
+				continue
+			}
+			Info("[Diagnostic] dump written", zap.String("signal", sig.String()), zap.String("dump", path))
+		}
+	}
+}
+
+// writeDiagnosticDump 将所有 goroutine 堆栈、运行时信息及 flight recorder 中的日志写入 dump 文件
+func (l *Logger) writeDiagnosticDump(reason string) (string, error) {
+	f, err := os.OpenFile(newDumpFile(diagnosticSuffix), os.O_RDWR|os.O_APPEND|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	version, revision := buildInfo()
+	hostname, _ := os.Hostname()
+	fmt.Fprintf(f, `===============================================================================
+TIME: %v
+DIAGNOSTIC: %s
+APP: %s
+VERSION: %s
+REVISION: %s
+HOST: %s
+UPTIME: %v
+`, now(), reason, l.Opts.AppName, version, revision, hostname, now().Sub(startTime).Round(time.Second))
+	fmt.Fprintln(f, runtimeSummary())
+	if l.recorder != nil {
+		fmt.Fprintln(f, "FLIGHT RECORDER:")
+		for _, line := range l.recorder.snapshot() {
+			fmt.Fprint(f, strings.TrimRight(string(line), "\n")+"\n")
+		}
+		fmt.Fprintln(f, "===============================================================================")
+	}
+	_, err = f.Write(allStacks())
+	return f.Name(), err
+}
+
+// stackFrame 堆栈中的一帧
+type stackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// parseStack 解析 debug.Stack 的输出，只保留 panic 发生处及其调用方的帧
+func parseStack(stack []byte) []stackFrame {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	var frames []stackFrame
+	for i := 1; i+1 < len(lines); i += 2 {
+		fn := lines[i]
+		if strings.HasPrefix(fn, "created by ") {
+			fn = strings.TrimPrefix(fn, "created by ")
+			if j := strings.Index(fn, " in goroutine "); j >= 0 {
+				fn = fn[:j]
+			}
+		} else if j := strings.LastIndexByte(fn, '('); j > 0 {
+			fn = fn[:j]
+		}
+		loc := strings.TrimSpace(lines[i+1])
+		if j := strings.LastIndex(loc, " +0x"); j >= 0 {
+			loc = loc[:j]
+		}
+		var f stackFrame
+		f.Function, f.File = fn, loc
+		if j := strings.LastIndexByte(loc, ':'); j >= 0 {
+			f.File = loc[:j]
+			f.Line, _ = strconv.Atoi(loc[j+1:])
+		}
+		if fn == "panic" {
+			frames = frames[:0]
+			continue
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// panicSignature 以 panic 值的类型与栈顶 n 帧的函数名标识同一个 panic
+func panicSignature(err interface{}, stack []byte, n int) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%T", err)
+	for i, f := range parseStack(stack) {
+		if i >= n {
+			break
+		}
+		fmt.Fprintf(h, "\n%s", f.Function)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// dumpDedup 在窗口期内对相同的 panic 只写一次 dump，窗口结束时写入重复次数。
+// 状态同时保存在 dump 目录的 .dedup-<signature>.json 中，崩溃循环中重启的进程仍能识别重复的 panic
+var dumpDedup = struct {
+	sync.Mutex
+	seen map[string]*repeatedPanic
+}{seen: map[string]*repeatedPanic{}}
+
+type repeatedPanic struct {
+	Since time.Time `json:"since"`
+	Dump  string    `json:"dump"`
+	Count int       `json:"count"` // 尚未写入汇总日志的重复次数
+
+	file string // 保存状态的文件
+}
+
+// save 保存去重状态，调用方需持有 dumpDedup 的锁
+func (p *repeatedPanic) save() {
+	if b, err := json.Marshal(p); err == nil {
+		os.WriteFile(p.file, b, 0644)
+	}
+}
+
+// dedupPanic 返回 panic 是否在窗口期内已出现过；首次出现时登记 dump，窗口结束时如有重复则写入一条汇总日志
+func dedupPanic(signature string, window time.Duration, dump func() string) (repeated bool, path string) {
+	dumpDedup.Lock()
+	if p, ok := dumpDedup.seen[signature]; ok {
+		p.Count++
+		p.save()
+		dumpDedup.Unlock()
+		return true, p.Dump
+	}
+	file := filepath.Join(exceptionsDir(), ".dedup-"+signature+".json")
+	// 此前的进程在窗口期内已出现过，如崩溃后被重启
+	var prev repeatedPanic
+	if b, err := os.ReadFile(file); err == nil && json.Unmarshal(b, &prev) == nil {
+		if elapsed := now().Sub(prev.Since); elapsed >= 0 && elapsed < window {
+			p := &prev
+			p.file = file
+			p.Count++
+			p.save()
+			dumpDedup.seen[signature] = p
+			dumpDedup.Unlock()
+			endDedup(signature, p, window, window-elapsed)
+			return true, p.Dump
+		}
+	}
+	p := &repeatedPanic{Since: now(), file: file}
+	dumpDedup.seen[signature] = p
+	dumpDedup.Unlock()
+
+	path = dump()
+	dumpDedup.Lock()
+	p.Dump = path
+	os.MkdirAll(filepath.Dir(file), os.ModePerm)
+	p.save()
+	dumpDedup.Unlock()
+	endDedup(signature, p, window, window)
+	return false, path
+}
+
+// endDedup 按 WithClock 的时钟在 d 后结束窗口期，写入尚未汇总的重复次数并删除状态文件
+func endDedup(signature string, p *repeatedPanic, window, d time.Duration) {
+	var clock zapcore.Clock = zapcore.DefaultClock
+	if l != nil && l.Opts.Clock != nil {
+		clock = l.Opts.Clock
+	}
+	ticker := clock.NewTicker(d)
+	go func() {
+		<-ticker.C
+		ticker.Stop()
+		dumpDedup.Lock()
+		delete(dumpDedup.seen, signature)
+		count := p.Count
+		os.Remove(p.file)
+		dumpDedup.Unlock()
+		reportRepeated(signature, p.Dump, count, window)
+	}()
+}
+
+// flushDedup 立即写入各窗口期内尚未汇总的重复次数，用于进程因 panic 退出之前
+func flushDedup() {
+	type pending struct {
+		signature, dump string
+		count           int
+	}
+	var flushed []pending
+	dumpDedup.Lock()
+	for signature, p := range dumpDedup.seen {
+		if p.Count > 0 {
+			flushed = append(flushed, pending{signature, p.Dump, p.Count})
+			p.Count = 0
+			p.save()
+		}
+	}
+	dumpDedup.Unlock()
+	window := time.Duration(0)
+	if l != nil {
+		window = l.Opts.DumpDedupWindow
+	}
+	for _, f := range flushed {
+		reportRepeated(f.signature, f.dump, f.count, window)
+	}
+}
+
+func reportRepeated(signature, dump string, count int, window time.Duration) {
+	if count > 0 {
+		Error(fmt.Sprintf("[CatchException] panic seen %d more times", count),
+			zap.String("signature", signature), zap.Int("repeated", count), zap.Duration("window", window), zap.String("dump", dump))
+	}
+}
+
+// DumpFormat exception dump 的格式
+type DumpFormat int
+
+const (
+	DumpText DumpFormat = iota // 文本
+	DumpJSON                   // JSON
+	DumpBoth                   // 文本与 JSON 各一份
+)
+
+// jsonDump JSON 格式的 exception dump
+type jsonDump struct {
+	Time       time.Time    `json:"time"`
+	Panic      string       `json:"panic"`
+	PanicType  string       `json:"panic_type"`
+	App        string       `json:"app"`
+	Version    string       `json:"version"`
+	Revision   string       `json:"revision"`
+	Host       string       `json:"host"`
+	PID        int          `json:"pid"`
+	Uptime     float64      `json:"uptime_seconds"`
+	Goroutine  int64        `json:"goroutine"`
+	Signature  string       `json:"signature"`
+	Frames     []stackFrame `json:"frames"`
+	Stack      string       `json:"stack"`
+	Goroutines string       `json:"goroutines,omitempty"` // 所有 goroutine 的堆栈，启用 WithDumpAllGoroutines 时输出
+}
+
+// writeJSONDump 将 panic 信息以 JSON 写入 path
+func writeJSONDump(path string, recovered interface{}, stack []byte) error {
+	d := jsonDump{
+		Time:      now(),
+		Panic:     fmt.Sprint(recovered),
+		PanicType: fmt.Sprintf("%T", recovered),
+		PID:       os.Getpid(),
+		Uptime:    now().Sub(startTime).Seconds(),
+		Goroutine: goroutineID(stack),
+		Signature: panicSignature(recovered, stack, 5),
+		Frames:    parseStack(stack),
+		Stack:     string(stack),
+	}
+	d.Version, d.Revision = buildInfo()
+	d.Host, _ = os.Hostname()
+	if l != nil {
+		d.App = l.Opts.AppName
+		if l.Opts.DumpDedupFrames > 0 {
+			d.Signature = panicSignature(recovered, stack, l.Opts.DumpDedupFrames)
+		}
+		if l.Opts.DumpAllGoroutines {
+			d.Goroutines = string(allStacks())
+		}
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// DumpShipper 发送一个 exception dump，path 为 dump 文件路径，data 为文件内容
+type DumpShipper func(path string, data []byte) error
+
+// DumpsToSink 返回将每个 dump 作为一行 JSON 写入 w（如 NewHTTPSink 返回的远端输出）的 DumpShipper，
+// 格式为 {"msg":"crash dump","file","time","dump"}，JSON dump 的 dump 为对象，文本 dump 为字符串
+func DumpsToSink(w io.Writer) DumpShipper {
+	return func(path string, data []byte) error {
+		var dump interface{} = string(data)
+		if strings.HasSuffix(path, ".json") && json.Valid(data) {
+			dump = json.RawMessage(data)
+		}
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		line, err := json.Marshal(map[string]interface{}{
+			"msg":  "crash dump",
+			"file": filepath.Base(path),
+			"time": modTime,
+			"dump": dump,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		if s, ok := w.(interface{ Sync() error }); ok {
+			return s.Sync()
+		}
+		return nil
+	}
+}
+
+// dumpShippedMarker 记录已发送的最新 dump 的修改时间
+const dumpShippedMarker = ".shipped"
+
+// isCrashDump 判断文件名是否为崩溃 dump，诊断 dump 不视为崩溃
+func isCrashDump(name, naming string) bool {
+	return isDumpName(name, naming) && !strings.HasSuffix(name, diagnosticSuffix+".log")
+}
+
+// shipDumps 启动时按修改时间顺序发送上次发送之后产生的崩溃 dump，使导致进程退出的崩溃也能送达。
+// DumpBoth 为同一崩溃写出的文本与 JSON dump 只发送 JSON 的一份
+func (l *Logger) shipDumps() {
+	dir := exceptionsDir()
+	markerPath := filepath.Join(dir, dumpShippedMarker)
+	var last time.Time
+	if data, err := os.ReadFile(markerPath); err == nil {
+		last, _ = time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	}
+
+	type dumpFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []dumpFile
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isCrashDump(info.Name(), l.Opts.DumpNaming) {
+			return nil
+		}
+		if strings.HasSuffix(path, ".log") {
+			if _, err := os.Stat(strings.TrimSuffix(path, ".log") + ".json"); err == nil {
+				return nil
+			}
+		}
+		if info.ModTime().After(last) {
+			files = append(files, dumpFile{path: path, modTime: info.ModTime()})
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	shipped := 0
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if err == nil {
+			err = l.Opts.DumpShipper(f.path, data)
+		}
+		if err != nil {
+			l.Warn("[ShipDumps] ship dump failed", zap.String("dump", f.path), zap.Error(err))
+			break
+		}
+		os.WriteFile(markerPath, []byte(f.modTime.Format(time.RFC3339Nano)), 0644)
+		shipped++
+	}
+	if shipped > 0 {
+		l.Info("[ShipDumps] dumps shipped", zap.Int("count", shipped))
+	}
+}
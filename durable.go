@@ -0,0 +1,145 @@
+package log
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const durableKey = "durable"
+
+// Durable 标记一条日志需要落盘确认：启用 WithGroupCommit 时，记录日志的调用会等到下一次
+// 批量 fsync 完成后才返回。该字段不会被编码输出，未启用 WithGroupCommit 时无效。
+func Durable() zap.Field {
+	return zap.Field{Key: durableKey, Type: zapcore.SkipType}
+}
+
+func isDurable(fields []zapcore.Field) bool {
+	for _, f := range fields {
+		if f.Key == durableKey && f.Type == zapcore.SkipType {
+			return true
+		}
+	}
+	return false
+}
+
+// groupCommitter 合并多条 durable 日志的 fsync，每个周期对日志文件执行一次 fsync 后统一确认
+type groupCommitter struct {
+	mu      sync.Mutex
+	paths   []string
+	waiters []chan error
+	done    <-chan struct{}
+}
+
+func newGroupCommitter(paths []string, interval time.Duration, clock zapcore.Clock, done <-chan struct{}) *groupCommitter {
+	gc := &groupCommitter{paths: paths, done: done}
+	go gc.loop(interval, clock)
+	return gc
+}
+
+// wait 阻塞到下一次 fsync 完成，logger 已停止时直接 fsync
+func (gc *groupCommitter) wait() error {
+	ch := make(chan error, 1)
+	gc.mu.Lock()
+	select {
+	case <-gc.done:
+		gc.mu.Unlock()
+		return gc.fsync()
+	default:
+	}
+	gc.waiters = append(gc.waiters, ch)
+	gc.mu.Unlock()
+	return <-ch
+}
+
+func (gc *groupCommitter) loop(interval time.Duration, clock zapcore.Clock) {
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gc.done:
+			gc.commit()
+			return
+		case <-ticker.C:
+			gc.commit()
+		}
+	}
+}
+
+func (gc *groupCommitter) commit() {
+	gc.mu.Lock()
+	waiters := gc.waiters
+	gc.waiters = nil
+	gc.mu.Unlock()
+	if len(waiters) == 0 {
+		return
+	}
+	err := gc.fsync()
+	for _, ch := range waiters {
+		ch <- err
+	}
+}
+
+// fsync 通过新打开的文件描述符对日志文件执行 fsync，fsync 作用于文件本身，与写入使用的描述符无关
+func (gc *groupCommitter) fsync() error {
+	var err error
+	for _, path := range gc.paths {
+		f, e := os.Open(path)
+		if e == nil {
+			e = f.Sync()
+			f.Close()
+		}
+		if e != nil && !os.IsNotExist(e) && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// durableCore 写入 durable 日志后先 Sync 写出缓冲，再等待批量 fsync
+type durableCore struct {
+	zapcore.Core
+	gc      *groupCommitter
+	durable bool // With 中包含 Durable 或所有日志都需要确认
+}
+
+func (c *durableCore) With(fields []zapcore.Field) zapcore.Core {
+	return &durableCore{Core: c.Core.With(fields), gc: c.gc, durable: c.durable || isDurable(fields)}
+}
+
+func (c *durableCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *durableCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		return err
+	}
+	if !c.durable && !isDurable(fields) {
+		return nil
+	}
+	if err := c.Core.Sync(); err != nil {
+		return err
+	}
+	return c.gc.wait()
+}
+
+func (l *Logger) initGroupCommit() {
+	if l.Opts.GroupCommit <= 0 {
+		return
+	}
+	var paths []string
+	if l.Opts.File {
+		paths = append(paths, l.filePaths()...)
+	}
+	if l.Opts.AuditFileName != "" {
+		paths = append(paths, l.filePath(l.Opts.AuditFileName))
+	}
+	l.committer = newGroupCommitter(paths, l.Opts.GroupCommit, l.Opts.Clock, l.done)
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EventSchema 业务事件的字段约束。Types 的值为 string/number/bool/object/array 之一。
+type EventSchema struct {
+	Required []string          // 必填字段
+	Types    map[string]string // 字段类型
+}
+
+var (
+	eventMu      sync.RWMutex
+	eventSchemas = map[string]EventSchema{}
+)
+
+// RegisterEventSchema 注册业务事件的字段约束，未注册的事件不做校验
+func RegisterEventSchema(name string, schema EventSchema) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventSchemas[name] = schema
+}
+
+func (l *Logger) initEvents() {
+	if l.Opts.EventFileName == "" {
+		return
+	}
+	ws := l.fileWriter(l.filePath(l.Opts.EventFileName))
+	all := zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+	l.events = zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig), ws, all))
+}
+
+// Event 校验 payload 后写入业务事件日志，未启用 WithEvents 时写入普通日志
+func Event(name string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("log: event %s: %v", name, err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return fmt.Errorf("log: event %s: payload must be an object", name)
+	}
+
+	eventMu.RLock()
+	schema, ok := eventSchemas[name]
+	eventMu.RUnlock()
+	if ok {
+		if err := schema.validate(data); err != nil {
+			return fmt.Errorf("log: event %s: %v", name, err)
+		}
+	}
+
+	fields := []zap.Field{zap.String("event", name), zap.Any("payload", data)}
+	if l != nil && l.events != nil {
+		l.events.Info(name, fields...)
+		return nil
+	}
+	Info(name, fields...)
+	return nil
+}
+
+func (s EventSchema) validate(data map[string]interface{}) error {
+	var missing []string
+	for _, key := range s.Required {
+		if _, ok := data[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+	for key, want := range s.Types {
+		v, ok := data[key]
+		if !ok {
+			continue
+		}
+		if got := jsonType(v); got != want {
+			return fmt.Errorf("field %s: expected %s, got %s", key, want, got)
+		}
+	}
+	return nil
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "null"
+}
@@ -0,0 +1,18 @@
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocFlKeepSize 对应 FALLOC_FL_KEEP_SIZE，预留空间但不改变文件大小，lumberjack 按文件大小切割不受影响
+const fallocFlKeepSize = 0x1
+
+func fallocate(path string, offset, length int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return syscall.Fallocate(int(f.Fd()), fallocFlKeepSize, offset, length)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package log
+
+// fallocate 在非 Linux 平台上不预留空间
+func fallocate(path string, offset, length int64) error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	fatalHooksMu sync.RWMutex
+	fatalHooks   []func()
+)
+
+// OnFatal 注册 Fatal 日志写入并 Sync 之后、进程退出之前执行的回调，如上报 trace、释放 leader 锁
+func OnFatal(fn func()) {
+	fatalHooksMu.Lock()
+	defer fatalHooksMu.Unlock()
+	fatalHooks = append(fatalHooks, fn)
+}
+
+// fatalHookCore 在 Fatal 日志写入后 Sync 所有输出并执行 OnFatal 回调
+type fatalHookCore struct {
+	zapcore.Core
+}
+
+func (c *fatalHookCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fatalHookCore{Core: c.Core.With(fields)}
+}
+
+func (c *fatalHookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fatalHookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	err := c.Core.Write(ent, fields)
+	if ent.Level < zapcore.FatalLevel {
+		return err
+	}
+	Sync()
+	fatalHooksMu.RLock()
+	hooks := fatalHooks
+	fatalHooksMu.RUnlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// lazyValue 在编码时才求值，多个输出共享同一次求值结果
+type lazyValue struct {
+	once sync.Once
+	fn   func() interface{}
+	v    interface{}
+}
+
+func (lv *lazyValue) value() interface{} {
+	lv.once.Do(func() {
+		lv.v = lv.fn()
+	})
+	return lv.v
+}
+
+func (lv *lazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(lv.value())
+}
+
+// Lazy 返回延迟求值的字段，fn 仅在日志实际写入时执行
+func Lazy(key string, fn func() interface{}) zap.Field {
+	return zap.Reflect(key, &lazyValue{fn: fn})
+}
+
+// ErrorE 以 Error 级别记录 err，并将错误链展开为 error、error_type、cause、cause_type 与 error_chain 字段
+func ErrorE(msg string, err error, fields ...zap.Field) {
+	if ce := skipLogger().Check(zapcore.ErrorLevel, msg); ce != nil {
+		ce.Write(append(errorChainFields(err), fields...)...)
+	}
+}
+
+func errorChainFields(err error) []zap.Field {
+	if err == nil {
+		return nil
+	}
+	var chain []string
+	cause := err
+	for e := err; e != nil; e = unwrapFirst(e) {
+		chain = append(chain, fmt.Sprintf("%T", e))
+		cause = e
+	}
+	return []zap.Field{
+		zap.String("error", err.Error()),
+		zap.String("error_type", fmt.Sprintf("%T", err)),
+		zap.String("cause", cause.Error()),
+		zap.String("cause_type", fmt.Sprintf("%T", cause)),
+		zap.Strings("error_chain", chain),
+	}
+}
+
+// unwrapFirst 与 errors.Unwrap 相同，对 errors.Join 等 Unwrap() []error 的错误返回第一个错误，
+// 与 errors.Is 的遍历顺序一致
+func unwrapFirst(err error) error {
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		if errs := u.Unwrap(); len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	}
+	return errors.Unwrap(err)
+}
+
+// MultiError 将 errors.Join、hashicorp/go-multierror 或 uber/multierr 组合的错误展开为字符串数组
+func MultiError(key string, err error) zap.Field {
+	var msgs []string
+	for _, e := range flattenErrors(err) {
+		msgs = append(msgs, e.Error())
+	}
+	return zap.Strings(key, msgs)
+}
+
+func flattenErrors(err error) []error {
+	var errs []error
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case interface{ Unwrap() []error }:
+		errs = e.Unwrap()
+	case interface{ WrappedErrors() []error }:
+		errs = e.WrappedErrors()
+	case interface{ Errors() []error }:
+		errs = e.Errors()
+	case interface{ Unwrap() error }:
+		// 如 fmt.Errorf("%w", errors.Join(...))，包装的是组合错误时展开，否则保留包装后的错误
+		if inner := flattenErrors(e.Unwrap()); len(inner) > 1 {
+			return inner
+		}
+		return []error{err}
+	default:
+		return []error{err}
+	}
+	var out []error
+	for _, e := range errs {
+		out = append(out, flattenErrors(e)...)
+	}
+	return out
+}
+
+// ErrorStack 从携带调用栈的错误（pkg/errors、xerrors）中提取最早的调用栈作为 stacktrace 字段，无调用栈时返回空字段
+func ErrorStack(err error) zap.Field {
+	var stack string
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if s := errorStack(e); s != "" {
+			stack = s
+		}
+	}
+	if stack == "" {
+		return zap.Skip()
+	}
+	return zap.String("stacktrace", stack)
+}
+
+// errorStack 按方法名探测，避免依赖 pkg/errors 与 xerrors
+func errorStack(err error) string {
+	v := reflect.ValueOf(err)
+	if m := v.MethodByName("StackTrace"); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+		return strings.TrimPrefix(fmt.Sprintf("%+v", m.Call(nil)[0].Interface()), "\n")
+	}
+	if v.MethodByName("FormatError").IsValid() {
+		// xerrors 的 %+v 输出首行为消息，其后为调用位置
+		detail := fmt.Sprintf("%+v", err)
+		if i := strings.IndexByte(detail, '\n'); i >= 0 {
+			return detail[i+1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+// Package fields 提供常用日志字段的构造函数，避免在调用处手写 zap.String 转换。
+//
+//	log.Info("upload done", fields.Err(err), fields.Since("took", start), fields.HumanBytes("size", n))
+package fields
+
+import (
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Err 返回键为 error 的错误字段，只记录 err.Error()，不附带 zap.Error 的 errorVerbose；err 为 nil 时不输出
+func Err(err error) zap.Field {
+	return NamedErr("error", err)
+}
+
+// NamedErr 与 Err 相同，键为 key
+func NamedErr(key string, err error) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.String(key, err.Error())
+}
+
+// Dur 返回时长字段，编码方式由 EncoderConfig.EncodeDuration 决定
+func Dur(key string, d time.Duration) zap.Field {
+	return zap.Duration(key, d)
+}
+
+// Since 返回从 start 到现在的时长字段
+func Since(key string, start time.Time) zap.Field {
+	return zap.Duration(key, time.Since(start))
+}
+
+// Millis 返回以毫秒为单位的时长数值字段，如 "took_ms":12.5
+func Millis(key string, d time.Duration) zap.Field {
+	return zap.Float64(key, float64(d)/float64(time.Millisecond))
+}
+
+// HumanDur 返回可读的时长字段，如 "1m30s"
+func HumanDur(key string, d time.Duration) zap.Field {
+	return zap.String(key, d.String())
+}
+
+// Bytes 返回字节数字段
+func Bytes(key string, n int64) zap.Field {
+	return zap.Int64(key, n)
+}
+
+// HumanBytes 返回可读的字节数字段，按 1024 进位，如 "1.5 MiB"。编码时才格式化，日志未输出时不产生开销。
+func HumanBytes(key string, n int64) zap.Field {
+	return zap.Field{Key: key, Type: zapcore.StringerType, Interface: byteSize(n)}
+}
+
+// byteSize 按 1024 进位格式化的字节数
+type byteSize int64
+
+func (b byteSize) String() string {
+	const units = "KMGTPE"
+	n := int64(b)
+	if n < 1024 && n > -1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	v := float64(n)
+	i := -1
+	for (v >= 1024 || v <= -1024) && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + " " + units[i:i+1] + "iB"
+}
+
+// Count 返回计数字段
+func Count(key string, n int) zap.Field {
+	return zap.Int(key, n)
+}
+
+// Percent 返回百分比字段，ratio 为 0~1 的比例，保留两位小数，如 "hit_rate":93.75
+func Percent(key string, ratio float64) zap.Field {
+	return zap.Float64(key, float64(int64(ratio*10000+0.5))/100)
+}
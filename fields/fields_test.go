@@ -0,0 +1,54 @@
+package fields
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func encode(fields ...zap.Field) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return enc.Fields
+}
+
+func TestFields(t *testing.T) {
+	m := encode(
+		Err(errors.New("disk full")),
+		NamedErr("cause", nil),
+		Dur("took", 1500*time.Millisecond),
+		Millis("took_ms", 1500*time.Microsecond),
+		HumanDur("wait", 90*time.Second),
+		Bytes("size", 2048),
+		HumanBytes("small", 512),
+		HumanBytes("upload", 3*1024*1024/2),
+		HumanBytes("huge", 5<<40),
+		Count("items", 3),
+		Percent("hit_rate", 0.93746),
+	)
+	want := map[string]interface{}{
+		"error":    "disk full",
+		"took":     1500 * time.Millisecond,
+		"took_ms":  1.5,
+		"wait":     "1m30s",
+		"size":     int64(2048),
+		"small":    "512 B",
+		"upload":   "1.5 MiB",
+		"huge":     "5.0 TiB",
+		"items":    int64(3),
+		"hit_rate": 93.75,
+	}
+	if len(m) != len(want) {
+		t.Fatalf("fields %v", m)
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Fatalf("%s = %v (%T), want %v", k, m[k], m[k], v)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/gocpp/log
 
-go 1.17
+go 1.18
 
 require (
 	github.com/BurntSushi/toml v0.4.1 // indirect
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EntryStream 向日志汇聚服务推送日志的流，对应 proto/log_service.proto 中
+// LogService.Stream 的客户端流。Send 返回后不得再持有 entry。
+// CloseAndRecv 关闭流并返回服务端确认收到的条数（StreamSummary.received）。
+type EntryStream interface {
+	Send(entry []byte) error
+	CloseSend() error
+	CloseAndRecv() (received uint64, err error)
+}
+
+// StreamOpener 建立到日志汇聚服务的流，ctx 结束时流随之中止，Send 须返回错误
+type StreamOpener func(ctx context.Context) (EntryStream, error)
+
+// NewStreamSink 返回通过 open 建立的流逐条推送日志的远端输出，流量控制由流的 Send 阻塞实现，
+// 发送队列已满时 Write 随之阻塞。流出错时关闭并在重试时重新建立，已推送的部分会被重复发送。
+// 设置了 SinkTimeout 的 write 超时时，一批日志未能在超时内发送完毕则中止该流。
+// 设置了 SinkWAL 时每批日志在单独的流中发送，CloseAndRecv 确认全部收到后才删除对应的预写日志分段。
+//
+// 使用 gRPC 时将生成的客户端流适配为 EntryStream：
+//
+//	type grpcStream struct{ logpb.LogService_StreamClient }
+//
+//	func (s grpcStream) Send(entry []byte) error {
+//		return s.LogService_StreamClient.Send(&logpb.Entry{Json: entry})
+//	}
+//
+//	func (s grpcStream) CloseAndRecv() (uint64, error) {
+//		sum, err := s.LogService_StreamClient.CloseAndRecv()
+//		return sum.GetReceived(), err
+//	}
+func NewStreamSink(open StreamOpener, opts ...SinkOption) *RemoteSink {
+	var o sinkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var (
+		mu     sync.Mutex // 超时放弃的发送与 Close 可能与后台发送并发
+		stream EntryStream
+		abort  context.CancelFunc // 中止当前流
+		sent   uint64             // 当前流已发送的条数
+	)
+	reset := func() {
+		abort()
+		stream = nil
+	}
+	s := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stream == nil {
+			// 流的生命周期长于单次发送，不使用发送的 ctx
+			sctx, cancel := context.WithCancel(context.Background())
+			var err error
+			if stream, err = open(sctx); err != nil {
+				cancel()
+				stream = nil
+				return err
+			}
+			abort, sent = cancel, 0
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			t := time.AfterFunc(time.Until(deadline), abort)
+			defer t.Stop()
+		}
+		for len(batch) > 0 {
+			entry := batch
+			if i := bytes.IndexByte(batch, '\n'); i >= 0 {
+				entry, batch = batch[:i], batch[i+1:]
+			} else {
+				batch = nil
+			}
+			if err := stream.Send(entry); err != nil {
+				stream.CloseSend()
+				reset()
+				return err
+			}
+			sent++
+		}
+		if o.walDir == "" {
+			return nil
+		}
+		// 预写日志分段在发送成功后删除，须等服务端确认
+		defer reset()
+		return closeStream(stream, sent)
+	}, append(opts, SinkCompression(""))...)
+	s.closeFn = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stream == nil {
+			return nil
+		}
+		defer reset()
+		return closeStream(stream, sent)
+	}
+	return s
+}
+
+// closeStream 关闭流并核对服务端收到的条数
+func closeStream(stream EntryStream, sent uint64) error {
+	received, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	if received != sent {
+		return fmt.Errorf("stream acknowledged %d of %d entries", received, sent)
+	}
+	return nil
+}
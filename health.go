@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Prober 可由自定义输出实现，供 HealthCheck 探测连通性；未实现时 HealthCheck 调用其 Sync
+type Prober interface {
+	Probe(ctx context.Context) error
+}
+
+// SinkStatus 单个输出的探测结果
+type SinkStatus struct {
+	Name    string        // 输出名称，与 Stats 中的键一致
+	Err     error         // 为 nil 表示健康
+	Latency time.Duration // 探测耗时
+}
+
+// HealthCheck 对每个输出执行轻量探测：日志文件检查能否以追加方式打开，实现 Prober 的输出调用 Probe，
+// 其余输出调用 Sync，控制台不探测。返回各输出的状态及第一个失败的错误，适合作为就绪检查。
+func HealthCheck(ctx context.Context) ([]SinkStatus, error) {
+	if l == nil || l.stats == nil {
+		return nil, nil
+	}
+	statuses := make([]SinkStatus, len(l.stats.sinks))
+	var first error
+	for i, s := range l.stats.sinks {
+		start := time.Now()
+		err := probe(ctx, s.probe)
+		statuses[i] = SinkStatus{Name: s.name, Err: err, Latency: time.Since(start)}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return statuses, first
+}
+
+// probe 执行 fn，ctx 结束时不再等待
+func probe(ctx context.Context, fn func(context.Context) error) error {
+	if fn == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// probeFiles 检查日志文件能否以追加方式打开，不写入任何内容
+func probeFiles(paths ...string) func(context.Context) error {
+	return func(context.Context) error {
+		for _, path := range paths {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return err
+			}
+			f.Close()
+		}
+		return nil
+	}
+}
+
+// probeSink 优先使用 Prober，否则调用 Sync
+func probeSink(ws zapcore.WriteSyncer) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if p, ok := ws.(Prober); ok {
+			return p.Probe(ctx)
+		}
+		return ws.Sync()
+	}
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// latencyBounds 写入延迟的桶上界（纳秒）
+	latencyBounds = []uint64{
+		uint64(50 * time.Microsecond), uint64(100 * time.Microsecond), uint64(250 * time.Microsecond),
+		uint64(500 * time.Microsecond), uint64(time.Millisecond), uint64(2500 * time.Microsecond),
+		uint64(5 * time.Millisecond), uint64(10 * time.Millisecond), uint64(25 * time.Millisecond),
+		uint64(50 * time.Millisecond), uint64(100 * time.Millisecond), uint64(250 * time.Millisecond),
+		uint64(time.Second),
+	}
+	// sizeBounds 日志大小的桶上界（字节）
+	sizeBounds = []uint64{128, 256, 512, 1 << 10, 2 << 10, 4 << 10, 8 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+)
+
+// histogram 无锁的固定桶直方图
+type histogram struct {
+	bounds []uint64
+	counts []uint64 // 最后一个为超过所有上界的计数
+	sum    uint64
+}
+
+func newHistogram(bounds []uint64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v uint64) {
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.sum, v)
+}
+
+// Histogram 直方图快照
+type Histogram struct {
+	Bounds []float64 // 各桶的上界
+	Counts []uint64  // 各桶的计数（非累计），比 Bounds 多一个超出所有上界的桶
+	Count  uint64    // 总次数
+	Sum    float64   // 总和
+}
+
+// snapshot 返回快照，scale 为值的换算系数，如纳秒换算为秒
+func (h *histogram) snapshot(scale float64) Histogram {
+	s := Histogram{Bounds: make([]float64, len(h.bounds)), Counts: make([]uint64, len(h.counts))}
+	for i, b := range h.bounds {
+		s.Bounds[i] = float64(b) * scale
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadUint64(&h.counts[i])
+		s.Count += s.Counts[i]
+	}
+	s.Sum = float64(atomic.LoadUint64(&h.sum)) * scale
+	return s
+}
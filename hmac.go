@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const hmacField = `,"hmac":"`
+
+// hmacWriteSyncer 在每行 JSON 末尾追加 hmac 字段，值为 HMAC(key, 上一行的 hmac || 本行内容)，
+// 形成哈希链，删除或篡改任意一行都会导致后续校验失败。
+type hmacWriteSyncer struct {
+	zapcore.WriteSyncer
+	mu   sync.Mutex
+	key  []byte
+	prev []byte
+}
+
+func newHMACWriteSyncer(ws zapcore.WriteSyncer, key, prev []byte) *hmacWriteSyncer {
+	return &hmacWriteSyncer{WriteSyncer: ws, key: key, prev: prev}
+}
+
+func (w *hmacWriteSyncer) Write(p []byte) (int, error) {
+	idx := bytes.LastIndexByte(p, '}')
+	if idx < 0 {
+		return w.WriteSyncer.Write(p)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	mac := chainMAC(w.key, w.prev, p[:idx+1])
+	buf := make([]byte, 0, len(p)+len(hmacField)+hex.EncodedLen(len(mac))+1)
+	buf = append(buf, p[:idx]...)
+	buf = append(buf, hmacField...)
+	buf = append(buf, hex.EncodeToString(mac)...)
+	buf = append(buf, '"')
+	buf = append(buf, p[idx:]...)
+	if _, err := w.WriteSyncer.Write(buf); err != nil {
+		return 0, err
+	}
+	w.prev = mac
+	return len(p), nil
+}
+
+func chainMAC(key, prev, line []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(prev)
+	h.Write(line)
+	return h.Sum(nil)
+}
+
+// splitHMAC 将带 hmac 字段的一行拆分为原始内容与 hmac
+func splitHMAC(line string) (string, []byte, error) {
+	idx := strings.LastIndex(line, hmacField)
+	if idx < 0 || !strings.HasSuffix(line, `"}`) {
+		return "", nil, fmt.Errorf("missing hmac field")
+	}
+	mac, err := hex.DecodeString(line[idx+len(hmacField) : len(line)-2])
+	if err != nil {
+		return "", nil, err
+	}
+	return line[:idx] + "}", mac, nil
+}
+
+// lastHMAC 返回文件最后一行的 hmac，用于进程重启后延续哈希链
+func lastHMAC(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	const tail = 1 << 20
+	if fi, err := f.Stat(); err == nil && fi.Size() > tail {
+		f.Seek(-tail, io.SeekEnd)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	_, mac, err := splitHMAC(strings.TrimRight(lines[len(lines)-1], "\r"))
+	if err != nil {
+		return nil
+	}
+	return mac
+}
+
+// Verify 按顺序校验日志文件的 hmac 链，paths 需从哈希链的第一个文件开始、按时间由旧到新排列，
+// 支持 gzip 压缩的归档文件。
+func Verify(key []byte, paths ...string) error {
+	var prev []byte
+	for _, path := range paths {
+		if err := verifyFile(key, path, &prev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyFile(key []byte, path string, prev *[]byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line, mac, err := splitHMAC(strings.TrimRight(scanner.Text(), "\r"))
+		if err != nil {
+			return fmt.Errorf("log: %s:%d: %v", path, n, err)
+		}
+		if !hmac.Equal(mac, chainMAC(key, *prev, []byte(line))) {
+			return fmt.Errorf("log: %s:%d: hmac mismatch", path, n)
+		}
+		*prev = mac
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// responseRecorder 记录响应状态码与写入字节数
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Recovery 捕获 handler 中的 panic，经 HandlePanic 处理后返回 500，并在响应头 X-Incident-ID 中返回事件 ID
+func Recovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				w.Header().Set("X-Incident-ID", HandlePanic(err, r))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandlePanic 处理请求中 recover 得到的 panic：写入 dump 与飞行记录，日志中附带请求的 method、path、
+// request_id 及新生成的 incident_id，并返回 incident_id。供 gin、echo 等框架的恢复中间件使用，如 gin：
+//
+//	func(c *gin.Context) {
+//		defer func() {
+//			if err := recover(); err != nil {
+//				c.Header("X-Incident-ID", log.HandlePanic(err, c.Request))
+//				c.AbortWithStatus(http.StatusInternalServerError)
+//			}
+//		}()
+//		c.Next()
+//	}
+//
+// echo 中使用 c.Request() 与 c.Response().Header()。
+func HandlePanic(recovered interface{}, r *http.Request) string {
+	id := newID()
+	handlePanic(recovered, debug.Stack(),
+		zap.String("incident_id", id),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+	return id
+}
+
+// ClaimsFunc 从请求中提取用户信息（如 JWT claims）作为日志字段
+type ClaimsFunc func(r *http.Request) []zap.Field
+
+// RequestLogger 为每个请求创建附带 request_id、method、route 及 WithClaims 提取字段的 logger，
+// 并注入请求的 context，下游通过 FromContext(r.Context()) 记录的日志自动携带这些字段。
+// 请求未携带 X-Request-ID 时使用 WithCorrelationID 的生成器补全，并写入响应头。
+// 命中 WithDebugOnDemand 的条件时，该请求的日志不受全局等级限制。
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" && l != nil && l.Opts.CorrelationID != nil {
+			id = l.Opts.CorrelationID()
+			r.Header.Set("X-Request-ID", id)
+		}
+		if id != "" {
+			w.Header().Set("X-Request-ID", id)
+		}
+		fields := []zap.Field{
+			zap.String("request_id", id),
+			zap.String("method", r.Method),
+			zap.String("route", r.URL.Path),
+		}
+		if l != nil && l.Opts.Claims != nil {
+			fields = append(fields, l.Opts.Claims(r)...)
+		}
+		if debugRequested(r) {
+			fields = append(fields, debugField())
+		}
+		logger := current().With(fields...)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), logger)))
+	})
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TruncateIP 返回将字段 keys（不区分大小写）中的 IP 地址低位清零的 Processor，IPv4 保留前 v4Bits 位，
+// IPv6 保留前 v6Bits 位，如 TruncateIP(24, 48, "remote") 将 203.0.113.7 记为 203.0.113.0。
+// 带端口的地址保留端口，无法解析为 IP 的值保持不变。v4Bits 不在 0~32 或 v6Bits 不在 0~128 时 panic。
+func TruncateIP(v4Bits, v6Bits int, keys ...string) Processor {
+	if v4Bits < 0 || v4Bits > 32 || v6Bits < 0 || v6Bits > 128 {
+		panic(fmt.Sprintf("log: TruncateIP bits out of range: v4Bits %d, v6Bits %d", v4Bits, v6Bits))
+	}
+	return ipProcessor(keys, func(ip net.IP) string {
+		if v4 := ip.To4(); v4 != nil {
+			return v4.Mask(net.CIDRMask(v4Bits, 32)).String()
+		}
+		return ip.Mask(net.CIDRMask(v6Bits, 128)).String()
+	})
+}
+
+// HashIP 返回将字段 keys（不区分大小写）中的 IP 地址替换为以 salt 计算的 HMAC-SHA256 前 16 位十六进制的 Processor，
+// 同一地址的化名相同，可用于统计而无法还原。
+func HashIP(salt []byte, keys ...string) Processor {
+	return ipProcessor(keys, func(ip net.IP) string {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write(ip.To16())
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	})
+}
+
+func ipProcessor(keys []string, anonymize func(net.IP) string) Processor {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = struct{}{}
+	}
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		var out []zapcore.Field
+		for i, f := range fields {
+			if f.Type != zapcore.StringType {
+				continue
+			}
+			if _, ok := set[strings.ToLower(f.Key)]; !ok {
+				continue
+			}
+			v, ok := anonymizeAddr(f.String, anonymize)
+			if !ok {
+				continue
+			}
+			if out == nil {
+				out = append([]zapcore.Field(nil), fields...)
+			}
+			out[i] = zap.String(f.Key, v)
+		}
+		if out == nil {
+			return ent, fields, true
+		}
+		return ent, out, true
+	}
+}
+
+// anonymizeAddr 处理 IP 或 host:port 形式的地址
+func anonymizeAddr(addr string, anonymize func(net.IP) string) (string, bool) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return anonymize(ip), true
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false
+	}
+	return net.JoinHostPort(anonymize(ip), port), true
+}
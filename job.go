@@ -0,0 +1,47 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Job 执行一次定时任务等作业 fn，记录开始与结束（含 run_id、duration、outcome），
+// fn 收到的 ctx 中带有附加了 job 与 run_id 字段的 logger。panic 经 CatchException 处理，
+// 失败时另外写入 job.failed 业务事件。返回 fn 的错误，panic 时返回描述 panic 的错误。
+func Job(name string, fn func(ctx context.Context) error) (err error) {
+	runID := newID()
+	logger := current().With(zap.String("job", name), zap.String("run_id", runID))
+	logger.Info("[job] start")
+	start := now()
+
+	panicked := true
+	func() {
+		defer CatchException()
+		err = fn(NewContext(context.Background(), logger))
+		panicked = false
+	}()
+	if panicked {
+		err = fmt.Errorf("log: job %s panicked", name)
+	}
+
+	duration := now().Sub(start)
+	if err == nil {
+		logger.Info("[job] finish", zap.Duration("duration", duration), zap.String("outcome", "success"))
+		return nil
+	}
+	outcome := "failure"
+	if panicked {
+		outcome = "panic"
+	}
+	logger.Error("[job] finish", zap.Duration("duration", duration), zap.String("outcome", outcome), zap.Error(err))
+	Event("job.failed", map[string]interface{}{
+		"job":         name,
+		"run_id":      runID,
+		"outcome":     outcome,
+		"error":       err.Error(),
+		"duration_ms": duration.Milliseconds(),
+	})
+	return err
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// KafkaLogger 把 Kafka 客户端自身的诊断日志写入本包，日志带有 component=kafka 字段。
+// 满足 sarama.StdLogger，可直接赋值给 sarama.Logger。
+type KafkaLogger struct {
+	level zapcore.Level
+}
+
+// NewKafkaLogger 返回以 level 记录 sarama 日志的 KafkaLogger
+func NewKafkaLogger(level zapcore.Level) *KafkaLogger {
+	return &KafkaLogger{level: level}
+}
+
+func (k *KafkaLogger) logger() *zap.Logger {
+	return current().WithOptions(zap.AddCallerSkip(1)).With(zap.String("component", "kafka"))
+}
+
+func (k *KafkaLogger) Print(v ...interface{}) {
+	k.logger().Check(k.level, strings.TrimSuffix(fmt.Sprint(v...), "\n")).Write()
+}
+
+func (k *KafkaLogger) Printf(format string, v ...interface{}) {
+	k.logger().Check(k.level, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n")).Write()
+}
+
+func (k *KafkaLogger) Println(v ...interface{}) {
+	k.logger().Check(k.level, strings.TrimSuffix(fmt.Sprintln(v...), "\n")).Write()
+}
+
+// KgoLog 按 franz-go 的日志等级（1 error，2 warn，3 info，4 debug）记录 msg 与键值对。
+// franz-go 的 kgo.Logger 使用自定义的等级类型，需要一层适配：
+//
+//	type kgoLogger struct{ *log.KafkaLogger }
+//
+//	func (k kgoLogger) Level() kgo.LogLevel { return kgo.LogLevelInfo }
+//	func (k kgoLogger) Log(level kgo.LogLevel, msg string, keyvals ...interface{}) {
+//		k.KgoLog(int(level), msg, keyvals...)
+//	}
+func (k *KafkaLogger) KgoLog(level int, msg string, keyvals ...interface{}) {
+	s := k.logger().Sugar()
+	switch level {
+	case 1:
+		s.Errorw(msg, keyvals...)
+	case 2:
+		s.Warnw(msg, keyvals...)
+	case 3:
+		s.Infow(msg, keyvals...)
+	case 4:
+		s.Debugw(msg, keyvals...)
+	}
+}
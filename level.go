@@ -0,0 +1,131 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TraceLevel 低于 Debug 的等级，用于报文转储等极其详细的日志
+const TraceLevel = zapcore.DebugLevel - 1
+
+// Trace 以 TraceLevel 记录日志
+func Trace(msg string, fields ...zap.Field) {
+	if ce := skipLogger().Check(TraceLevel, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// Notice 记录值得关注但并非警告的日志。zap 的等级在 Info 与 Warn 之间没有空位，
+// 因此以 Info 等级写入并附带 "notice": true 字段
+func Notice(msg string, fields ...zap.Field) {
+	if ce := skipLogger().Check(zapcore.InfoLevel, msg); ce != nil {
+		ce.Write(append(fields[:len(fields):len(fields)], zap.Bool("notice", true))...)
+	}
+}
+
+// parseLevel 解析包括 trace 在内的等级名称
+func parseLevel(text string) (zapcore.Level, bool) {
+	if strings.EqualFold(text, "trace") {
+		return TraceLevel, true
+	}
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText([]byte(text))
+	return lvl, err == nil
+}
+
+// levelName 返回等级的小写名称
+func levelName(lvl zapcore.Level) string {
+	if lvl == TraceLevel {
+		return "trace"
+	}
+	return lvl.String()
+}
+
+func lowercaseLevelEncoder(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(levelName(lvl))
+}
+
+func capitalLevelEncoder(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if lvl == TraceLevel {
+		enc.AppendString("TRACE")
+		return
+	}
+	zapcore.CapitalLevelEncoder(lvl, enc)
+}
+
+func capitalColorLevelEncoder(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if lvl == TraceLevel {
+		enc.AppendString("\x1b[90mTRACE\x1b[0m")
+		return
+	}
+	zapcore.CapitalColorLevelEncoder(lvl, enc)
+}
+
+// defaultLevelColors 与 zapcore.CapitalColorLevelEncoder 一致的默认颜色
+var defaultLevelColors = map[zapcore.Level]string{
+	TraceLevel:          "\x1b[90m",
+	zapcore.DebugLevel:  "\x1b[35m",
+	zapcore.InfoLevel:   "\x1b[34m",
+	zapcore.WarnLevel:   "\x1b[33m",
+	zapcore.ErrorLevel:  "\x1b[31m",
+	zapcore.DPanicLevel: "\x1b[31m",
+	zapcore.PanicLevel:  "\x1b[31m",
+	zapcore.FatalLevel:  "\x1b[31m",
+}
+
+// consoleLevelEncoder 返回控制台的等级编码器，WithLevelEncoder 优先，其次按 WithLevelLabels、WithLevelColors 定制
+func (l *Logger) consoleLevelEncoder(color bool) zapcore.LevelEncoder {
+	if l.Opts.LevelEncoder != nil {
+		return l.Opts.LevelEncoder
+	}
+	if len(l.Opts.LevelLabels) == 0 && len(l.Opts.LevelColors) == 0 {
+		return levelEncoder(color)
+	}
+	labels, colors := l.Opts.LevelLabels, l.Opts.LevelColors
+	return func(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		label, ok := labels[lvl]
+		if !ok {
+			label = strings.ToUpper(lvl.String())
+			if lvl == TraceLevel {
+				label = "TRACE"
+			}
+		}
+		if !color {
+			enc.AppendString(label)
+			return
+		}
+		c, ok := colors[lvl]
+		if !ok {
+			c = defaultLevelColors[lvl]
+		}
+		enc.AppendString(c + label + "\x1b[0m")
+	}
+}
+
+// loadLevelState 读取 WithLevelStateFile 保存的等级
+func (l *Logger) loadLevelState() (zapcore.Level, bool) {
+	if l.Opts.LevelStateFile == "" {
+		return 0, false
+	}
+	b, err := os.ReadFile(l.filePath(l.Opts.LevelStateFile))
+	if err != nil {
+		return 0, false
+	}
+	return parseLevel(strings.TrimSpace(string(b)))
+}
+
+// saveLevelState 将当前等级写入 WithLevelStateFile 指定的文件
+func (l *Logger) saveLevelState() error {
+	if l.Opts.LevelStateFile == "" {
+		return nil
+	}
+	path := l.filePath(l.Opts.LevelStateFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(levelName(l.Opts.Level)+"\n"), 0644)
+}
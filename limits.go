@@ -0,0 +1,357 @@
+package log
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldLimits 编码字段值时的限制，为 0 的项不限制
+type FieldLimits struct {
+	MaxString   int // 字符串的最大字节数，超出部分以 "..." 代替
+	MaxElements int // slice、array 与 map 的最大元素数，超出时追加 "+N more"
+	MaxDepth    int // zap.Any 值的最大嵌套层数，更深的值记为 "[max depth]"
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// fieldLimits 按 limits 截断字符串字段与 zap.Any 的值，zap.Any 的值按 JSON 的规则遍历，
+// 不会先完整编码整个值
+func fieldLimits(limits FieldLimits) Processor {
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		var out []zapcore.Field
+		for i, f := range fields {
+			nf, changed := limits.field(f)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]zapcore.Field(nil), fields...)
+			}
+			out[i] = nf
+		}
+		if out == nil {
+			return ent, fields, true
+		}
+		return ent, out, true
+	}
+}
+
+// field 返回按限制截断后的字段，值在限制之内时原样返回且不重建
+func (lim FieldLimits) field(f zapcore.Field) (zapcore.Field, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		if lim.MaxString > 0 && len(f.String) > lim.MaxString {
+			return zap.String(f.Key, lim.str(f.String)), true
+		}
+	case zapcore.ReflectType:
+		if f.Interface != nil {
+			v := reflect.ValueOf(f.Interface)
+			if !lim.within(v, 0) {
+				return zap.Reflect(f.Key, lim.value(v, 0)), true
+			}
+		}
+	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+		// zap.Strings、zap.Ints、zap.Object 等先编码到内存中检查，超出限制时输出截断后的值
+		enc := zapcore.NewMapObjectEncoder()
+		var err error
+		if f.Type == zapcore.ArrayMarshalerType {
+			err = enc.AddArray(f.Key, f.Interface.(zapcore.ArrayMarshaler))
+		} else {
+			err = enc.AddObject(f.Key, f.Interface.(zapcore.ObjectMarshaler))
+		}
+		v, ok := enc.Fields[f.Key]
+		if err != nil || !ok {
+			return f, false
+		}
+		if elems, ok := v.([]interface{}); ok {
+			if lim.within(reflect.ValueOf(elems), 0) {
+				return f, false
+			}
+			return zap.Array(f.Key, limitedArray(lim.value(reflect.ValueOf(elems), 0).([]interface{}))), true
+		}
+		// 对象的键相当于结构体的字段，不受 MaxElements 限制
+		m := v.(map[string]interface{})
+		within := true
+		for _, fv := range m {
+			if within = lim.within(reflect.ValueOf(fv), 1); !within {
+				break
+			}
+		}
+		if within {
+			return f, false
+		}
+		obj := make(limitedObject, len(m))
+		for k, fv := range m {
+			obj[k] = lim.value(reflect.ValueOf(fv), 1)
+		}
+		return zap.Object(f.Key, obj), true
+	}
+	return f, false
+}
+
+// within 返回 v 是否在限制之内，即 value 不会改变它
+func (lim FieldLimits) within(v reflect.Value, depth int) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+			return true
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return lim.MaxString <= 0 || v.Len() <= lim.MaxString
+	case reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return true
+		}
+	case reflect.Struct, reflect.Array:
+	default:
+		return true
+	}
+	if lim.MaxDepth > 0 && depth >= lim.MaxDepth {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		within := true
+		lim.eachField(v, func(name string, fv reflect.Value) bool {
+			within = lim.within(fv, depth+1)
+			return within
+		})
+		return within
+	case reflect.Map:
+		if lim.MaxElements > 0 && v.Len() > lim.MaxElements {
+			return false
+		}
+		for it := v.MapRange(); it.Next(); {
+			if !lim.within(it.Value(), depth+1) {
+				return false
+			}
+		}
+		return true
+	default:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+		if lim.MaxElements > 0 && v.Len() > lim.MaxElements {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if !lim.within(v.Index(i), depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (lim FieldLimits) str(s string) string {
+	if lim.MaxString <= 0 || len(s) <= lim.MaxString {
+		return s
+	}
+	return truncateUTF8(s, lim.MaxString) + "..."
+}
+
+func (lim FieldLimits) more(n int) string {
+	return fmt.Sprintf("+%d more", n)
+}
+
+// value 返回按限制截断后的值，由基本类型、map[string]interface{} 与 []interface{} 组成
+func (lim FieldLimits) value(v reflect.Value, depth int) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return lim.str(v.String())
+	case reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		fallthrough
+	case reflect.Struct, reflect.Array:
+		if lim.MaxDepth > 0 && depth >= lim.MaxDepth {
+			return "[max depth]"
+		}
+	default:
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		lim.eachField(v, func(name string, fv reflect.Value) bool {
+			m[name] = lim.value(fv, depth+1)
+			return true
+		})
+		return m
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+		}
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+		m := make(map[string]interface{}, len(keys))
+		for n, i := range order {
+			if lim.MaxElements > 0 && n >= lim.MaxElements {
+				m["..."] = lim.more(len(keys) - n)
+				break
+			}
+			m[names[i]] = lim.value(v.MapIndex(keys[i]), depth+1)
+		}
+		return m
+	default:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		n := v.Len()
+		if lim.MaxElements > 0 && n > lim.MaxElements {
+			n = lim.MaxElements
+		}
+		s := make([]interface{}, 0, n+1)
+		for i := 0; i < n; i++ {
+			s = append(s, lim.value(v.Index(i), depth+1))
+		}
+		if n < v.Len() {
+			s = append(s, lim.more(v.Len()-n))
+		}
+		return s
+	}
+}
+
+// eachField 按 encoding/json 的规则遍历导出字段，未命名的内嵌结构体字段展开，fn 返回 false 时停止
+func (lim FieldLimits) eachField(v reflect.Value, fn func(name string, fv reflect.Value) bool) bool {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				ft, fv = ft.Elem(), fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !lim.eachField(fv, fn) {
+					return false
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(tag, ",omitempty") && fv.IsZero() {
+			continue
+		}
+		if !fn(name, fv) {
+			return false
+		}
+	}
+	return true
+}
+
+// limitedArray 截断后的 zap.Array 的值，time.Time、time.Duration 仍按编码器的设置编码
+type limitedArray []interface{}
+
+func (a limitedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, v := range a {
+		var err error
+		switch v := v.(type) {
+		case string:
+			enc.AppendString(v)
+		case time.Time:
+			enc.AppendTime(v)
+		case time.Duration:
+			enc.AppendDuration(v)
+		case []interface{}:
+			err = enc.AppendArray(limitedArray(v))
+		case map[string]interface{}:
+			err = enc.AppendObject(limitedObject(v))
+		default:
+			err = enc.AppendReflected(v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitedObject 截断后的 zap.Object 的值，按键排序输出
+type limitedObject map[string]interface{}
+
+func (o limitedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var err error
+		switch v := o[k].(type) {
+		case string:
+			enc.AddString(k, v)
+		case time.Time:
+			enc.AddTime(k, v)
+		case time.Duration:
+			enc.AddDuration(k, v)
+		case []byte:
+			enc.AddBinary(k, v)
+		case []interface{}:
+			err = enc.AddArray(k, limitedArray(v))
+		case map[string]interface{}:
+			err = enc.AddObject(k, limitedObject(v))
+		default:
+			err = enc.AddReflected(k, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package log
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,21 +20,119 @@ import (
 )
 
 type Options struct {
-	LogFileDir    string // 文件保存地方
-	AppName       string // 日志文件前缀
-	FileName      string
-	ErrorFileName string
-	WarnFileName  string
-	InfoFileName  string
-	DebugFileName string
-	Level         zapcore.Level // 日志等级
-	MaxSize       int           // 日志文件小大（M）
-	MaxBackups    int           // 最多存在多少个切片文件
-	MaxAge        int           // 保存的最大天数
-	Compress      bool          // 是否压缩归档
-	Development   bool          // 是否是开发模式
+	LogFileDir      string // 文件保存地方
+	AppName         string // 日志文件前缀
+	FileName        string
+	ErrorFileName   string
+	WarnFileName    string
+	InfoFileName    string
+	DebugFileName   string
+	Level           zapcore.Level  // 日志等级
+	MaxSize         int            // 日志文件小大（M）
+	MaxBackups      int            // 最多存在多少个切片文件
+	MaxAge          int            // 保存的最大天数
+	Compress        bool           // 是否压缩归档
+	Development     bool           // 是否是开发模式
+	Color           ColorMode      // 控制台颜色模式
+	ConsoleEncoding string         // 控制台输出格式（console/json）
+	ConsoleLevel    *zapcore.Level // 控制台日志等级，为空时与 Level 一致
+	Console         *bool          // 是否输出到控制台，为空时仅开发模式输出
+	File            bool           // 是否输出到文件
+	SplitStream     bool           // 控制台 Warn 及以上输出到 stderr，其余输出到 stdout
 	zap.Config
-	Merge bool // 是否合并日志
+	Merge               bool                              // 是否合并日志，为 true 时不写入 ErrorFileName 等单独的等级日志文件
+	FieldProviders      []func() []zap.Field              // 写入时动态计算的字段
+	InternalErrorOutput string                            // zap 内部错误输出文件，相对路径基于 LogFileDir
+	Hooks               []func(zapcore.Entry) error       // 每条日志写入后的回调
+	Processors          []Processor                       // 编码前的日志处理链
+	RedactKeys          []string                          // 需要脱敏的字段名
+	Scrubbers           []Scrubber                        // 消息与字符串字段的正则脱敏规则
+	Redactors           []Redactor                        // 自定义脱敏规则
+	EncryptionKey       KeyFunc                           // 日志文件加密密钥，为空时不加密
+	HMACKey             []byte                            // 日志哈希链密钥，为空时不启用
+	AuditFileName       string                            // 审计日志文件，为空时不启用
+	AuditMaxAge         int                               // 审计日志保存的最大天数，为 0 时与 MaxAge 一致
+	AuditMaxBackups     int                               // 审计日志最多保留的切片文件数，为 0 时与 MaxBackups 一致
+	AccessFileName      string                            // 访问日志文件，为空时不启用
+	AccessFormat        AccessLogFormat                   // 访问日志格式
+	EventFileName       string                            // 业务事件日志文件，为空时写入普通日志
+	FlightRecorderSize  int                               // 环形缓冲保存的最近 Debug/Info 日志条数，为 0 时不启用
+	StreamHistory       int                               // StreamHandler 连接时推送的最近日志条数
+	PanicAction         PanicAction                       // CatchException 处理完 panic 后的行为
+	PanicExitCode       int                               // PanicAction 为 PanicExit 时的退出码
+	DumpMaxAge          int                               // exception dump 保存的最大天数，为 0 时不限制
+	DumpMaxCount        int                               // exception dump 最多保留的文件数，为 0 时不限制
+	DumpDir             string                            // exception dump 根目录，为空时为程序所在目录下的 exceptions
+	DumpNaming          string                            // exception dump 文件名格式，使用 time.Format 的布局
+	DumpNestByDate      bool                              // exception dump 是否按日期分目录
+	DumpAllGoroutines   bool                              // exception dump 是否包含所有 goroutine 的堆栈
+	DumpDedupWindow     time.Duration                     // 相同 panic 只写一次 dump 的窗口期，为 0 时不去重
+	DumpDedupFrames     int                               // 判断 panic 是否相同时比较的栈顶帧数
+	DumpFormat          DumpFormat                        // exception dump 的格式
+	DumpShipper         DumpShipper                       // 启动时发送尚未发送的 exception dump，为空时不发送
+	Version             string                            // 程序版本，为空时使用构建信息中的版本
+	DiagnosticSignals   []os.Signal                       // 触发诊断 dump 的信号，如 SIGQUIT、SIGUSR2
+	PprofLabels         []string                          // FromContext 时附带的 pprof 标签
+	Clock               zapcore.Clock                     // 日志时间及后台任务使用的时钟
+	Sinks               []zapcore.WriteSyncer             // 额外的输出，与文件使用相同的格式与等级
+	Claims              ClaimsFunc                        // RequestLogger 从请求中提取的用户信息字段
+	CorrelationID       func() string                     // 请求未携带 X-Request-ID 时生成关联 ID，为空时不生成
+	Baggage             BaggageFunc                       // FromContext 时读取 baggage 的函数
+	BaggageKeys         []string                          // FromContext 时附带的 baggage 键
+	TenantDir           string                            // 租户日志目录，相对路径基于 LogFileDir，为空时不启用
+	TenantMaxSize       int                               // 租户日志文件的最大尺寸（MB），为 0 时与 MaxSize 一致
+	TenantMaxBackups    int                               // 租户日志最多保留的切片文件数，为 0 时与 MaxBackups 一致
+	Shards              int                               // 日志文件分片数，大于 1 时轮流写入 app-0.log..app-N.log
+	LowLatency          bool                              // 普通日志文件是否按块缓冲写入并预留磁盘空间
+	PreallocateSize     int                               // LowLatency 时每次预留的磁盘空间（MB）
+	GroupCommit         time.Duration                     // durable 日志批量 fsync 的周期，为 0 时不启用
+	SyncPolicy          SyncPolicy                        // 自动 Sync 的时机，默认只在显式调用 Sync 时写出
+	AsyncQueueSize      int                               // 异步写入队列长度，为 0 时同步写入
+	Backpressure        BackpressureMode                  // 异步队列已满时的处理方式
+	OnFatal             zapcore.CheckWriteAction          // Fatal 日志写入后的行为，默认退出进程
+	DPanicPanics        *bool                             // DPanic 日志是否 panic，为空时不 panic
+	LevelEncoder        zapcore.LevelEncoder              // 控制台的等级编码器，为空时使用大写等级名
+	LevelLabels         map[zapcore.Level]string          // 控制台的等级名称，如小写或本地化名称
+	LevelColors         map[zapcore.Level]string          // 控制台的等级颜色（ANSI 转义序列）
+	LineEnding          string                            // 每条日志的行尾，为空时为 "\n"
+	MessageTemplate     string                            // 消息模板，如 "[{app}:{env}] {msg}"
+	RequiredFields      []string                          // 每条日志必须包含的字段
+	RequiredMode        RequiredMode                      // 缺少必填字段时的处理方式
+	Banner              bool                              // 初始化成功的日志是否附带生效配置的摘要
+	Heartbeat           time.Duration                     // 心跳日志的间隔，为 0 时不启用
+	Shipper             io.Writer                         // 转发日志文件内容的远端输出，如 NewHTTPSink
+	ShipInterval        time.Duration                     // 检查日志文件新内容的间隔
+	RPCPayloadLimit     int                               // RPC 请求与响应记录的最大字节数，为负数时不记录
+	CaptureStdio        bool                              // 是否将进程的 fd 1/2 重定向到日志
+	MaxEntrySize        int                               // 单条日志编码后的最大字节数，为 0 时不限制
+	FieldLimits         FieldLimits                       // 字段值的长度、元素数与嵌套层数限制
+	BinaryPolicy        *BinaryPolicy                     // zap.Binary 字段的编码方式，为空时使用 zap 默认的 base64
+	PrettyJSON          bool                              // 开发模式下控制台是否输出缩进的多行 JSON
+	InlineStack         bool                              // 控制台是否将堆栈与错误链字段保持为单行转义文本
+	LevelRetention      map[zapcore.Level]Retention       // 各等级日志文件的保留策略，未设置时与 MaxAge、MaxBackups 一致
+	ReopenSignals       []os.Signal                       // 收到时重新打开日志文件的信号
+	ReopenCheck         time.Duration                     // 检查日志文件是否被移走的间隔，为 0 时不检查
+	PerProcessFiles     bool                              // 普通日志与等级日志的文件名是否包含进程 ID，供多个进程共用 LogFileDir 时使用
+	LevelStateFile      string                            // 保存 SetLevel 设置的等级的文件，相对路径基于 LogFileDir
+	DebugOnDemand       bool                              // 是否允许按请求启用最详细等级的日志
+	DebugTriggers       []DebugTrigger                    // RequestLogger 判断请求是否需要按需调试的条件
+	CaptureFileName     string                            // 采集名单中用户或租户的调试日志文件
+	CaptureKeys         []string                          // 用于匹配采集名单的字段，如 user_id、tenant_id
+	StormGuard          *StormGuard                       // 日志风暴保护，为空时不启用
+	Budgets             map[string]Budget                 // 各命名 logger 的日志量预算
+	ShadowEncoder       zapcore.Encoder                   // 影子输出的编码器，为空时不启用影子输出
+	ShadowWriter        zapcore.WriteSyncer               // 影子输出
+	ShadowCompare       func(primary, shadow []byte) bool // 比较主输出与影子输出是否一致
+	RuntimeStats        time.Duration                     // 运行时统计日志的间隔，为 0 时不启用
+	ErrorReporter       ErrorReporter                     // 接收 Error 及以上级别的日志与 breadcrumb
+	SpanLevel           zapcore.Level                     // 写入 span 事件的最低等级
+	SpanLogger          SpanLogger                        // 将日志写入 span 事件，为空时不启用
+}
+
+// Retention 日志文件归档的保留策略
+type Retention struct {
+	MaxAge     int // 保存的最大天数
+	MaxBackups int // 最多存在多少个切片文件
 }
 
 type Option func(options *Options)
@@ -38,11 +140,15 @@ type LogOutputFunc func(msg string, fields ...zap.Field)
 type LogFormatFunc func(msg string, args ...interface{})
 
 var (
-	l  *Logger
-	sp = string(filepath.Separator)
+	l   *Logger
+	std *zap.Logger // 未调用 NewLogger 时使用的控制台 logger
 
-	fileWs    zapcore.WriteSyncer       // 文件输出
-	consoleWs = zapcore.Lock(os.Stdout) // 控制台输出
+	stdSkip *zap.Logger // 跳过一层调用栈的 std，供包级函数使用
+	sp      = string(filepath.Separator)
+
+	fileWs       zapcore.WriteSyncer       // 文件输出
+	consoleWs    = zapcore.Lock(os.Stdout) // 控制台输出
+	consoleErrWs = zapcore.Lock(os.Stderr) // 控制台错误输出
 
 	Debug func(msg string, fields ...zap.Field)
 	Info  func(msg string, fields ...zap.Field)
@@ -65,7 +171,7 @@ func init() {
 			MessageKey:     "M",
 			StacktraceKey:  "S",
 			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalColorLevelEncoder,
+			EncodeLevel:    levelEncoder(useColor(ColorAuto, os.Stdout)),
 			EncodeTime:     timeEncoder,
 			EncodeDuration: zapcore.StringDurationEncoder,
 			EncodeCaller:   zapcore.ShortCallerEncoder,
@@ -73,43 +179,76 @@ func init() {
 		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout)),
 		zap.DebugLevel,
 	)
-	logger := zap.New(core)
-	Debug = logger.Debug
-	Info = logger.Info
-	Warn = logger.Warn
-	Error = logger.Error
+	std = zap.New(core)
+	stdSkip = std.WithOptions(zap.AddCallerSkip(1))
+	Debug = std.Debug
+	Info = std.Info
+	Warn = std.Warn
+	Error = std.Error
+}
+
+// now 返回当前时间，使用 WithClock 设置的时钟
+func now() time.Time {
+	if l != nil && l.Opts.Clock != nil {
+		return l.Opts.Clock.Now()
+	}
+	return time.Now()
+}
+
+// skipLogger 返回跳过一层调用栈的全局 logger，使包级函数记录正确的调用位置
+func skipLogger() *zap.Logger {
+	if l != nil && l.skip != nil {
+		return l.skip
+	}
+	return stdSkip
+}
+
+// current 返回当前的全局 logger
+func current() *zap.Logger {
+	if l != nil && l.Logger != nil {
+		return l.Logger
+	}
+	return std
 }
 
 type Logger struct {
 	*zap.Logger
 	sync.RWMutex
-	Opts      *Options `json:"opts"`
-	zapConfig zap.Config
-	inited    bool
+	Opts       *Options `json:"opts"`
+	zapConfig  zap.Config
+	inited     bool
+	audit      *zap.Logger
+	access     zapcore.WriteSyncer
+	accessJSON *zap.Logger
+	events     *zap.Logger
+	recorder   *flightRecorder
+	fileName   string
+	done       chan struct{}
+	stopOnce   sync.Once
+	skip       *zap.Logger
+	tenants    map[string]*tenantLogger
+	tenantsMu  sync.Mutex
+	committer  *groupCommitter
+	queue      *asyncQueue
+	stats      *logStats
+	rotators   []*rotationCounter // 普通日志文件的轮转，供 Purge 与重新打开文件使用
+	auxFiles   []*rotationCounter // 审计、访问、事件等其它日志文件，供重新打开文件使用
+	budgets    map[string]*budgetState
+	shadow     *shadowStats
 }
 
 func NewLogger(opt ...Option) *zap.Logger {
-	l = &Logger{}
+	if l != nil {
+		l.stop()
+	}
+	l = &Logger{done: make(chan struct{})}
 	l.Lock()
 	defer l.Unlock()
 	if l.inited {
 		l.Info("[NewLogger] logger Inited")
 		return nil
 	}
-	l.Opts = &Options{
-		LogFileDir: "",
-		AppName:    "app",
-		FileName:   ".log",
-		Level:      zapcore.DebugLevel,
-		MaxSize:    100,
-		MaxBackups: 60,
-		MaxAge:     30,
-		Compress:   false,
-	}
-	if l.Opts.LogFileDir == "" {
-		l.Opts.LogFileDir, _ = filepath.Abs(filepath.Dir(filepath.Join(".")))
-		l.Opts.LogFileDir += sp + "logs" + sp
-	}
+	l.Opts = defaultOptions()
 	if l.Opts.Development {
 		l.zapConfig = zap.NewDevelopmentConfig()
 		l.zapConfig.EncoderConfig.EncodeTime = timeEncoder
@@ -117,33 +256,100 @@ func NewLogger(opt ...Option) *zap.Logger {
 		l.zapConfig = zap.NewProductionConfig()
 		l.zapConfig.EncoderConfig.EncodeTime = timeEncoder
 	}
+	l.zapConfig.EncoderConfig.EncodeLevel = lowercaseLevelEncoder
 	if l.Opts.OutputPaths == nil || len(l.Opts.OutputPaths) == 0 {
 		l.zapConfig.OutputPaths = []string{"stdout"}
 	}
 	if l.Opts.ErrorOutputPaths == nil || len(l.Opts.ErrorOutputPaths) == 0 {
-		l.zapConfig.OutputPaths = []string{"stderr"}
+		l.zapConfig.ErrorOutputPaths = []string{"stderr"}
 	}
 	for _, fn := range opt {
 		fn(l.Opts)
 	}
 	l.zapConfig.DisableStacktrace = true
+	if l.Opts.LineEnding != "" {
+		l.zapConfig.EncoderConfig.LineEnding = l.Opts.LineEnding
+	}
+	if l.Opts.DPanicPanics != nil {
+		l.zapConfig.Development = *l.Opts.DPanicPanics
+	}
+	if lvl, ok := l.loadLevelState(); ok {
+		l.Opts.Level = lvl
+	}
 	l.zapConfig.Level.SetLevel(l.Opts.Level)
 	l.init()
 	l.inited = true
-	l.Info("[NewLogger] success")
+	for _, err := range l.Opts.Validate() {
+		l.Warn("[NewLogger] invalid config", zap.Error(err))
+	}
+	if l.Opts.Banner {
+		l.Info("[NewLogger] success", l.bannerFields()...)
+	} else {
+		l.Info("[NewLogger] success")
+	}
+	if l.Opts.DumpMaxAge > 0 || l.Opts.DumpMaxCount > 0 {
+		go l.dumpCleaner()
+	}
+	if l.Opts.DumpShipper != nil {
+		go l.shipDumps()
+	}
+	if l.Opts.Heartbeat > 0 {
+		go l.heartbeat()
+	}
+	if l.Opts.RuntimeStats > 0 {
+		go l.runtimeStats()
+	}
+	if len(l.Opts.DiagnosticSignals) > 0 {
+		go l.diagnosticSignals()
+	}
+	if len(l.Opts.ReopenSignals) > 0 || l.Opts.ReopenCheck > 0 {
+		go l.reopenLoop()
+	}
+	if l.Opts.Shipper != nil && l.Opts.File {
+		go l.shipLoop()
+	}
 
 	Info = l.Logger.Info
 	Debug = l.Logger.Debug
 	Warn = l.Logger.Warn
 	Error = l.Logger.Error
+	l.skip = l.Logger.WithOptions(zap.AddCallerSkip(1))
 
 	return l.Logger
 }
 
+// stop 停止后台任务
+func (l *Logger) stop() {
+	l.stopOnce.Do(func() {
+		close(l.done)
+		l.closeTenants()
+		restoreStdio(true)
+	})
+}
+
 func (l *Logger) init() {
+	l.stats = &logStats{}
+	if l.Opts.CaptureStdio {
+		captureStdio()
+	}
 	l.setSyncers()
+	l.initGroupCommit()
+	l.initAudit()
+	l.initAccess()
+	l.initEvents()
 	var err error
-	l.Logger, err = l.zapConfig.Build(l.cores())
+	opts := []zap.Option{l.cores()}
+	if l.Opts.InternalErrorOutput != "" {
+		opts = append(opts, zap.ErrorOutput(l.fileWriter(l.filePath(l.Opts.InternalErrorOutput))))
+	}
+	if len(l.Opts.Hooks) > 0 {
+		opts = append(opts, zap.Hooks(l.Opts.Hooks...))
+	}
+	if l.Opts.OnFatal != zapcore.WriteThenNoop {
+		opts = append(opts, zap.OnFatal(l.Opts.OnFatal))
+	}
+	opts = append(opts, zap.WithClock(l.Opts.Clock))
+	l.Logger, err = l.zapConfig.Build(opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -151,21 +357,165 @@ func (l *Logger) init() {
 }
 
 func (l *Logger) setSyncers() {
-	f := func(fN string) zapcore.WriteSyncer {
-		fileName := l.Opts.LogFileDir + sp + l.Opts.AppName + "-" + fN
-		if len(fN) == len(".log") {
-			fileName = l.Opts.LogFileDir + sp + l.Opts.AppName + fN
+	if !l.Opts.File {
+		fileWs = nil
+		return
+	}
+	l.fileName = l.logFileName(l.Opts.FileName)
+	if l.Opts.Shards > 1 {
+		fileWs = l.shardedWriter(l.fileName)
+		return
+	}
+	fileWs = l.mainWriter(l.fileName)
+}
+
+// filePaths 返回 logger 写入的全部日志文件的路径：普通日志文件（各分片）与等级日志文件，供 Purge、GroupCommit 与 Shipper 使用
+func (l *Logger) filePaths() []string {
+	return append(l.mainFilePaths(), l.levelFilePaths()...)
+}
+
+// mainFilePaths 返回普通日志文件（分片时为各分片）的路径
+func (l *Logger) mainFilePaths() []string {
+	if l.Opts.Shards <= 1 {
+		return []string{l.fileName}
+	}
+	paths := make([]string, l.Opts.Shards)
+	for i := range paths {
+		paths[i] = shardFileName(l.fileName, i)
+	}
+	return paths
+}
+
+// levelFilePaths 返回 ErrorFileName 等单独的等级日志文件的路径
+func (l *Logger) levelFilePaths() []string {
+	if l.Opts.Merge {
+		return nil
+	}
+	var paths []string
+	for _, name := range []string{l.Opts.ErrorFileName, l.Opts.WarnFileName, l.Opts.InfoFileName, l.Opts.DebugFileName} {
+		if name != "" {
+			paths = append(paths, l.logFileName(name))
+		}
+	}
+	return paths
+}
+
+// mainWriter 返回普通日志文件的 WriteSyncer
+func (l *Logger) mainWriter(fileName string) zapcore.WriteSyncer {
+	rc := newRotationCounter(l.rotateLogger(fileName), l.Opts.Clock, &l.stats.rotations)
+	l.rotators = append(l.rotators, rc)
+	ws := l.rotateWriter(rc)
+	if l.Opts.LowLatency {
+		ws = l.lowLatencyWriter(ws, fileName)
+	}
+	return l.chainWriter(ws, fileName)
+}
+
+// levelCores 为设置了 ErrorFileName 等的等级创建单独的日志文件，ErrorFileName 包含 Error 及以上等级。
+// 设置了 Merge 时日志只写入普通日志文件
+func (l *Logger) levelCores(enc zapcore.Encoder, priority zapcore.LevelEnabler) []zapcore.Core {
+	if l.Opts.Merge {
+		return nil
+	}
+	var cores []zapcore.Core
+	for _, lf := range []struct {
+		level zapcore.Level
+		name  string
+	}{
+		{zapcore.ErrorLevel, l.Opts.ErrorFileName},
+		{zapcore.WarnLevel, l.Opts.WarnFileName},
+		{zapcore.InfoLevel, l.Opts.InfoFileName},
+		{zapcore.DebugLevel, l.Opts.DebugFileName},
+	} {
+		if lf.name == "" {
+			continue
 		}
-		return zapcore.AddSync(&lumberjack.Logger{
-			Filename:   fileName,
-			MaxSize:    l.Opts.MaxSize,
-			MaxBackups: l.Opts.MaxBackups,
-			MaxAge:     l.Opts.MaxAge,
-			Compress:   l.Opts.Compress,
-			LocalTime:  true,
+		level := lf.level
+		enabler := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			if !priority.Enabled(lvl) {
+				return false
+			}
+			if level == zapcore.ErrorLevel {
+				return lvl >= level
+			}
+			return lvl == level
 		})
+		fileName := l.logFileName(lf.name)
+		lj := l.rotateLogger(fileName)
+		if r, ok := l.Opts.LevelRetention[level]; ok {
+			lj.MaxAge, lj.MaxBackups = r.MaxAge, r.MaxBackups
+		}
+		rc := newRotationCounter(lj, l.Opts.Clock, &l.stats.rotations)
+		l.rotators = append(l.rotators, rc)
+		ws := l.chainWriter(l.rotateWriter(rc), fileName)
+		cores = append(cores, zapcore.NewCore(enc, l.stats.sink("file-"+level.String(), ws, probeFiles(fileName)), enabler))
+	}
+	return cores
+}
+
+func (l *Logger) logFileName(fN string) string {
+	fileName := l.Opts.LogFileDir + sp + l.Opts.AppName + "-" + fN
+	if len(fN) == len(".log") {
+		fileName = l.Opts.LogFileDir + sp + l.Opts.AppName + fN
 	}
-	fileWs = f(l.Opts.FileName)
+	if l.Opts.PerProcessFiles {
+		ext := filepath.Ext(fileName)
+		fileName = strings.TrimSuffix(fileName, ext) + "-" + strconv.Itoa(os.Getpid()) + ext
+	}
+	return fileName
+}
+
+// chainWriter 在启用 HMACKey 时为 ws 增加哈希链，并从 fileName 的最后一行延续
+func (l *Logger) chainWriter(ws zapcore.WriteSyncer, fileName string) zapcore.WriteSyncer {
+	if len(l.Opts.HMACKey) == 0 {
+		return ws
+	}
+	var prev []byte
+	if l.Opts.EncryptionKey == nil {
+		prev = lastHMAC(fileName)
+	}
+	return newHMACWriteSyncer(ws, l.Opts.HMACKey, prev)
+}
+
+// filePath resolves name relative to LogFileDir unless it is absolute.
+func (l *Logger) filePath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return l.Opts.LogFileDir + sp + name
+}
+
+func (l *Logger) fileWriter(fileName string) zapcore.WriteSyncer {
+	rc := newRotationCounter(l.rotateLogger(fileName), l.Opts.Clock, nil)
+	l.auxFiles = append(l.auxFiles, rc)
+	return l.rotateWriter(rc)
+}
+
+func (l *Logger) rotateLogger(fileName string) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   fileName,
+		MaxSize:    l.Opts.MaxSize,
+		MaxBackups: l.Opts.MaxBackups,
+		MaxAge:     l.Opts.MaxAge,
+		Compress:   l.Opts.Compress,
+		LocalTime:  true,
+	}
+}
+
+func (l *Logger) rotateWriter(lj io.Writer) zapcore.WriteSyncer {
+	ws := zapcore.AddSync(lj)
+	if l.Opts.EncryptionKey == nil {
+		return ws
+	}
+	key, err := l.Opts.EncryptionKey()
+	if err != nil {
+		panic(err)
+	}
+	ws, err = NewEncryptWriteSyncer(ws, key)
+	if err != nil {
+		panic(err)
+	}
+	return ws
 }
 
 func WithMaxSize(MaxSize int) Option {
@@ -200,6 +550,8 @@ func WithAppName(AppName string) Option {
 
 func strToLevel(str string) (level zapcore.Level) {
 	switch str {
+	case "trace":
+		level = TraceLevel
 	case "debug":
 		level = zap.DebugLevel
 	case "info":
@@ -223,12 +575,20 @@ func WithLevel(name string) Option {
 	}
 }
 
+func WithConsoleLevel(name string) Option {
+	Level := strToLevel(strings.ToLower(name))
+	return func(option *Options) {
+		option.ConsoleLevel = &Level
+	}
+}
+
 func WithFileName(FileName string) Option {
 	return func(option *Options) {
 		option.FileName = FileName
 	}
 }
 
+// WithErrorFileName Error 及以上等级的日志另外写入单独的文件，Warn、Info、Debug 同理但只包含该等级
 func WithErrorFileName(ErrorFileName string) Option {
 	return func(option *Options) {
 		option.ErrorFileName = ErrorFileName
@@ -264,27 +624,739 @@ func WithDevelopment(Development bool) Option {
 	}
 }
 
+func WithFieldProvider(provider func() []zap.Field) Option {
+	return func(option *Options) {
+		option.FieldProviders = append(option.FieldProviders, provider)
+	}
+}
+
+func WithConsole(Console bool) Option {
+	return func(option *Options) {
+		option.Console = &Console
+	}
+}
+
+func WithFile(File bool) Option {
+	return func(option *Options) {
+		option.File = File
+	}
+}
+
+func WithSplitStream(SplitStream bool) Option {
+	return func(option *Options) {
+		option.SplitStream = SplitStream
+	}
+}
+
+func WithInternalErrorOutput(path string) Option {
+	return func(option *Options) {
+		option.InternalErrorOutput = path
+	}
+}
+
+func WithHook(hook func(zapcore.Entry) error) Option {
+	return func(option *Options) {
+		option.Hooks = append(option.Hooks, hook)
+	}
+}
+
+func WithProcessor(processors ...Processor) Option {
+	return func(option *Options) {
+		option.Processors = append(option.Processors, processors...)
+	}
+}
+
+func WithRedactKeys(keys ...string) Option {
+	return func(option *Options) {
+		option.RedactKeys = append(option.RedactKeys, keys...)
+	}
+}
+
+func WithScrubbers(scrubbers ...Scrubber) Option {
+	return func(option *Options) {
+		option.Scrubbers = append(option.Scrubbers, scrubbers...)
+	}
+}
+
+func WithRedactors(redactors ...Redactor) Option {
+	return func(option *Options) {
+		option.Redactors = append(option.Redactors, redactors...)
+	}
+}
+
+func WithEncryption(key KeyFunc) Option {
+	return func(option *Options) {
+		option.EncryptionKey = key
+	}
+}
+
+func WithHMACChain(key []byte) Option {
+	return func(option *Options) {
+		option.HMACKey = key
+	}
+}
+
+func WithAudit(AuditFileName string) Option {
+	return func(option *Options) {
+		option.AuditFileName = AuditFileName
+	}
+}
+
+func WithAuditRetention(MaxAge, MaxBackups int) Option {
+	return func(option *Options) {
+		option.AuditMaxAge = MaxAge
+		option.AuditMaxBackups = MaxBackups
+	}
+}
+
+func WithAccessLog(AccessFileName string, AccessFormat AccessLogFormat) Option {
+	return func(option *Options) {
+		option.AccessFileName = AccessFileName
+		option.AccessFormat = AccessFormat
+	}
+}
+
+func WithEvents(EventFileName string) Option {
+	return func(option *Options) {
+		option.EventFileName = EventFileName
+	}
+}
+
+func WithFlightRecorder(size int) Option {
+	return func(option *Options) {
+		option.FlightRecorderSize = size
+	}
+}
+
+func WithStreamHistory(size int) Option {
+	return func(option *Options) {
+		option.StreamHistory = size
+	}
+}
+
+func WithPanicAction(action PanicAction, exitCode int) Option {
+	return func(option *Options) {
+		option.PanicAction = action
+		option.PanicExitCode = exitCode
+	}
+}
+
+func WithDumpRetention(MaxAge, MaxCount int) Option {
+	return func(option *Options) {
+		option.DumpMaxAge = MaxAge
+		option.DumpMaxCount = MaxCount
+	}
+}
+
+func WithDumpDir(DumpDir string) Option {
+	return func(option *Options) {
+		option.DumpDir = DumpDir
+	}
+}
+
+func WithDumpNaming(layout string, nestByDate bool) Option {
+	return func(option *Options) {
+		option.DumpNaming = layout
+		option.DumpNestByDate = nestByDate
+	}
+}
+
+// WithDumpDedup 在 window 内对栈顶 frames 帧（为 0 时为 5）相同的 panic 只写一次 dump 与完整日志，
+// 其余只计数，窗口结束时写入一条 "[CatchException] panic seen N more times"，避免崩溃循环产生大量 dump 文件。
+// 去重状态保存在 dump 目录中，进程重启后仍然有效；PanicExit、PanicRepanic 退出前先写入已累计的次数。
+func WithDumpDedup(window time.Duration, frames int) Option {
+	return func(option *Options) {
+		if frames <= 0 {
+			frames = 5
+		}
+		option.DumpDedupWindow = window
+		option.DumpDedupFrames = frames
+	}
+}
+
+// WithDumpFormat 设置 exception dump 的格式：DumpText（默认）、DumpJSON 或同时写两种的 DumpBoth。
+// JSON dump 与文本 dump 同名、扩展名为 .json，包含 panic 值、解析后的堆栈帧与程序信息，便于索引与聚合。
+func WithDumpFormat(format DumpFormat) Option {
+	return func(option *Options) {
+		option.DumpFormat = format
+	}
+}
+
+// WithShipDumps 启动时将上次发送之后产生的 exception dump 交给 ship 发送，如 DumpsToSink(sink)，
+// 使导致进程退出的崩溃也能送达日志平台或 Sentry。已发送的位置记录在 dump 目录下的 .shipped 文件中，
+// 发送失败时停止，下次启动从失败处继续。
+func WithShipDumps(ship DumpShipper) Option {
+	return func(option *Options) {
+		option.DumpShipper = ship
+	}
+}
+
+func WithDumpAllGoroutines(DumpAllGoroutines bool) Option {
+	return func(option *Options) {
+		option.DumpAllGoroutines = DumpAllGoroutines
+	}
+}
+
+func WithVersion(Version string) Option {
+	return func(option *Options) {
+		option.Version = Version
+	}
+}
+
+func WithDiagnosticSignal(signals ...os.Signal) Option {
+	return func(option *Options) {
+		option.DiagnosticSignals = append(option.DiagnosticSignals, signals...)
+	}
+}
+
+func WithPprofLabels(keys ...string) Option {
+	return func(option *Options) {
+		option.PprofLabels = append(option.PprofLabels, keys...)
+	}
+}
+
+// WithBaggage 在 FromContext 时将 ctx 中指定的 baggage（如 tenant、experiment、region）附加为字段，
+// 例如 OpenTelemetry：
+//
+//	log.WithBaggage(func(ctx context.Context, key string) string {
+//		return baggage.FromContext(ctx).Member(key).Value()
+//	}, "tenant", "experiment", "region")
+func WithBaggage(fn BaggageFunc, keys ...string) Option {
+	return func(option *Options) {
+		option.Baggage = fn
+		option.BaggageKeys = append(option.BaggageKeys, keys...)
+	}
+}
+
+// WithTenants 启用租户日志，每个租户写入 dir 下独立的文件，占用空间上限约为 maxSize*(maxBackups+1) MB
+func WithTenants(dir string, maxSize, maxBackups int) Option {
+	return func(option *Options) {
+		option.TenantDir = dir
+		option.TenantMaxSize = maxSize
+		option.TenantMaxBackups = maxBackups
+	}
+}
+
+// WithShards 将日志轮流写入 n 个分片文件，分散单个文件写入锁的竞争，适用于极高吞吐的场景
+func WithShards(n int) Option {
+	return func(option *Options) {
+		option.Shards = n
+	}
+}
+
+// WithLowLatencyWriter 普通日志文件按块缓冲写入（最长缓冲 100ms，Sync 时立即写出），
+// 并每次预留 preallocateMB 的磁盘空间，减少文件系统元数据更新造成的尾延迟
+func WithLowLatencyWriter(preallocateMB int) Option {
+	return func(option *Options) {
+		option.LowLatency = true
+		option.PreallocateSize = preallocateMB
+	}
+}
+
+// WithGroupCommit 每隔 interval 对日志文件批量 fsync 一次，标记 Durable 的日志及审计日志在 fsync 完成后才返回
+func WithGroupCommit(interval time.Duration) Option {
+	return func(option *Options) {
+		option.GroupCommit = interval
+	}
+}
+
+// WithSyncPolicy 设置自动 Sync 的时机，如 SyncEveryEntries(1000)、SyncEveryInterval(time.Second)、SyncOnError()
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(option *Options) {
+		option.SyncPolicy = policy
+	}
+}
+
+// WithAsync 日志放入长度为 queueSize 的队列后由后台 goroutine 写出，队列已满时按 mode 处理，丢弃的条数见 Dropped
+func WithAsync(queueSize int, mode BackpressureMode) Option {
+	return func(option *Options) {
+		option.AsyncQueueSize = queueSize
+		option.Backpressure = mode
+	}
+}
+
+// WithOnFatal 设置 Fatal 日志写入后的行为，如 zapcore.WriteThenGoexit、zapcore.WriteThenPanic，默认 os.Exit(1)
+func WithOnFatal(action zapcore.CheckWriteAction) Option {
+	return func(option *Options) {
+		option.OnFatal = action
+	}
+}
+
+// WithDPanic 设置 DPanic 日志写入后是否 panic，不受 Development 影响，如预发环境开启、生产环境只记录为 DPanic 等级。
+// 未设置时 DPanic 日志不 panic
+func WithDPanic(panics bool) Option {
+	return func(option *Options) {
+		option.DPanicPanics = &panics
+	}
+}
+
+// WithLevelEncoder 设置控制台的等级编码器，如 zapcore.LowercaseColorLevelEncoder
+func WithLevelEncoder(enc zapcore.LevelEncoder) Option {
+	return func(option *Options) {
+		option.LevelEncoder = enc
+	}
+}
+
+// WithLevelLabels 设置控制台的等级名称，未设置的等级使用大写等级名
+func WithLevelLabels(labels map[zapcore.Level]string) Option {
+	return func(option *Options) {
+		option.LevelLabels = labels
+	}
+}
+
+// WithLevelColors 设置控制台的等级颜色，如 zapcore.WarnLevel: "\x1b[33;40m" 为黑底黄字，未设置的等级使用默认颜色
+func WithLevelColors(colors map[zapcore.Level]string) Option {
+	return func(option *Options) {
+		option.LevelColors = colors
+	}
+}
+
+// WithLineEnding 设置每条日志的行尾，如供 Windows 程序读取时使用 "\r\n"
+func WithLineEnding(ending string) Option {
+	return func(option *Options) {
+		option.LineEnding = ending
+	}
+}
+
+// WithMessageTemplate 在写入时按模板改写消息，支持 {msg}、{app}、{level}、{logger}，
+// 其余 {key} 取同名字段的值，便于依赖固定前缀的 grep 工具在迁移期间继续使用
+func WithMessageTemplate(tmpl string) Option {
+	return func(option *Options) {
+		option.MessageTemplate = tmpl
+	}
+}
+
+// WithRequiredFields 要求每条日志包含 keys（如 service、env、request_id），缺少时按 mode 标记或丢弃
+func WithRequiredFields(mode RequiredMode, keys ...string) Option {
+	return func(option *Options) {
+		option.RequiredMode = mode
+		option.RequiredFields = append(option.RequiredFields, keys...)
+	}
+}
+
+// WithBanner 在初始化成功的日志中附带生效的等级、输出、切割策略与构建信息，便于确认运行中实例的配置
+func WithBanner(Banner bool) Option {
+	return func(option *Options) {
+		option.Banner = Banner
+	}
+}
+
+// WithHeartbeat 每隔 interval 写入一条 [heartbeat] 日志，包含各等级写入条数、丢弃条数与各输出的健康状况
+func WithHeartbeat(interval time.Duration) Option {
+	return func(option *Options) {
+		option.Heartbeat = interval
+	}
+}
+
+// WithShipper 每隔 interval 读取普通日志文件新写入的内容转发到 w，读取位置跨轮转保持并保存在
+// <文件名>.offset 中，可替代 filebeat 等外部采集程序。interval 为 0 时为 1 秒。
+func WithShipper(w io.Writer, interval time.Duration) Option {
+	return func(option *Options) {
+		option.Shipper = w
+		option.ShipInterval = interval
+	}
+}
+
+// WithRPCPayloadLimit 设置 Debug 等级下记录的 RPC 请求与响应的最大字节数，默认 4096，为负数时不记录
+func WithRPCPayloadLimit(limit int) Option {
+	return func(option *Options) {
+		option.RPCPayloadLimit = limit
+	}
+}
+
+// WithCaptureStdio 将进程的 stdout/stderr（fd 1/2）重定向到日志，cgo 或第三方库直接写入的内容
+// 也会按行写入日志文件，stdout 为 Info 等级，stderr 为 Warn 等级，同时仍原样输出到原来的 stdout/stderr。
+// 重新调用 NewLogger 或 panic 后退出前恢复原来的 fd。仅支持 unix 系统。
+func WithCaptureStdio(CaptureStdio bool) Option {
+	return func(option *Options) {
+		option.CaptureStdio = CaptureStdio
+	}
+}
+
+// WithMaxEntrySize 限制单条日志编码后的大小，超出时从最大的消息或字段开始截断（每项至少保留 64 字节），
+// 并添加 truncated=true，避免超长日志破坏下游解析或超出 Kafka 等消息大小限制
+func WithMaxEntrySize(bytes int) Option {
+	return func(option *Options) {
+		option.MaxEntrySize = bytes
+	}
+}
+
+// WithFieldLimits 限制字段值的编码：字符串长度、slice 与 map 的元素数及 zap.Any 值的嵌套层数，
+// 避免误记录的大结构体拖慢写入
+func WithFieldLimits(limits FieldLimits) Option {
+	return func(option *Options) {
+		option.FieldLimits = limits
+	}
+}
+
+// WithBinaryPolicy 设置 zap.Binary 字段的编码方式（hex 或 base64）与长度上限，便于阅读协议调试日志
+func WithBinaryPolicy(policy BinaryPolicy) Option {
+	return func(option *Options) {
+		option.BinaryPolicy = &policy
+	}
+}
+
+// WithPrettyJSON 开发模式下控制台输出缩进的多行 JSON，便于查看嵌套字段，文件仍为单行 JSON
+func WithPrettyJSON() Option {
+	return func(option *Options) {
+		option.PrettyJSON = true
+	}
+}
+
+// WithMultilineStack 设置控制台是否将 stacktrace、stack 字段及带堆栈的错误链另起多行缩进显示，默认开启
+func WithMultilineStack(enabled bool) Option {
+	return func(option *Options) {
+		option.InlineStack = !enabled
+	}
+}
+
+// WithLevelRetention 设置等级 level 的单独日志文件（见 WithErrorFileName 等）的保留天数与切片数，
+// 如错误日志保留 180 天而调试日志保留 3 天
+func WithLevelRetention(level string, maxAge, maxBackups int) Option {
+	return func(option *Options) {
+		if option.LevelRetention == nil {
+			option.LevelRetention = map[zapcore.Level]Retention{}
+		}
+		option.LevelRetention[strToLevel(strings.ToLower(level))] = Retention{MaxAge: maxAge, MaxBackups: maxBackups}
+	}
+}
+
+// WithReopen 配合外部 logrotate 使用：收到 signals（如 SIGUSR1）时，或每隔 check 发现日志文件被移走
+// （inode 变化）时重新打开日志文件，避免继续写入已被移走或删除的文件。check 为 0 时不检查。
+func WithReopen(check time.Duration, signals ...os.Signal) Option {
+	return func(option *Options) {
+		option.ReopenSignals = signals
+		option.ReopenCheck = check
+	}
+}
+
+// WithPerProcessFiles 在普通日志、各等级日志及 WithDebugCapture 采集文件的文件名中加入进程 ID（如 app-1234.log、
+// app-capture-1234.log），多个进程（prefork 服务、CLI 与守护进程）共用 LogFileDir 时各自写入与切割，互不干扰。
+//
+// 审计、访问、事件、租户与 InternalErrorOutput 的文件名不加进程 ID，多个进程共用时仍会同时切割同一文件，
+// 需为各进程配置不同的文件名。切割后的保留（MaxAge、MaxBackups）只作用于本进程 ID 的文件，
+// 已退出进程留下的文件不会被清理；Search、Purge 与 Shipper 也只处理本进程的文件。
+func WithPerProcessFiles(PerProcessFiles bool) Option {
+	return func(option *Options) {
+		option.PerProcessFiles = PerProcessFiles
+	}
+}
+
+// WithLevelStateFile 将 SetLevel 设置的等级保存到 path，下次 NewLogger 时恢复，使调试等级在滚动重启后依然生效。
+// 相对路径基于 LogFileDir。
+func WithLevelStateFile(path string) Option {
+	return func(option *Options) {
+		option.LevelStateFile = path
+	}
+}
+
+// WithDebugOnDemand 启用按需调试：命中任一 trigger 的请求（如 DebugHeader、DebugTraceSampled），
+// 其 RequestLogger 注入的 logger 记录的日志不受全局等级限制，并附带 debug_on_demand 字段；
+// 非 HTTP 场景可通过 ForceDebug 启用。只有附带标记的 logger 放宽等级，其余 logger 低于生效等级的日志仍在调用处直接丢弃。
+func WithDebugOnDemand(triggers ...DebugTrigger) Option {
+	return func(option *Options) {
+		option.DebugOnDemand = true
+		option.DebugTriggers = triggers
+	}
+}
+
+// WithDebugCapture 将 keys 字段（如 user_id、tenant_id）的值在采集名单中的日志从 Debug 起另外写入
+// fileName，名单通过 AddDebugCapture、RemoveDebugCapture 在运行时修改，不影响全局等级与主日志文件。
+func WithDebugCapture(fileName string, keys ...string) Option {
+	return func(option *Options) {
+		option.CaptureFileName = fileName
+		option.CaptureKeys = keys
+	}
+}
+
+// WithStormGuard 启用日志风暴保护：持续超过 guard.Limit 时临时提高生效等级或采样，
+// 并写入 "log storm detected"、"log storm suppressed"（含丢弃条数）与 "log storm ended" 标记
+func WithStormGuard(guard StormGuard) Option {
+	return func(option *Options) {
+		if guard.Interval <= 0 {
+			guard.Interval = time.Second
+		}
+		if guard.Sustain <= 0 {
+			guard.Sustain = 1
+		}
+		option.StormGuard = &guard
+	}
+}
+
+// WithBudget 为命名 logger name 及其下级设置日志量预算，如 "ingest" 每小时 50MB。
+// 超出后本周期内不高于 budget.MaxLevel 的日志被采样或丢弃，并写入一条 "[Budget] log budget exceeded"，
+// 丢弃与采样的条数见 Stats().Budgets。
+func WithBudget(name string, budget Budget) Option {
+	return func(option *Options) {
+		if budget.Period <= 0 {
+			budget.Period = time.Hour
+		}
+		if option.Budgets == nil {
+			option.Budgets = map[string]Budget{}
+		}
+		option.Budgets[name] = budget
+	}
+}
+
+// WithShadow 启用影子输出，用于在生产环境验证日志格式迁移：写入文件的每条日志同时以 enc 编码写入 ws，
+// 并用 compare 与主输出比较（为空时使用 JSONEqual），写入与不一致的条数见 Stats().Shadow。
+// 影子输出的错误不影响主输出。
+func WithShadow(enc zapcore.Encoder, ws zapcore.WriteSyncer, compare func(primary, shadow []byte) bool) Option {
+	return func(option *Options) {
+		if compare == nil {
+			compare = JSONEqual
+		}
+		option.ShadowEncoder = enc
+		option.ShadowWriter = ws
+		option.ShadowCompare = compare
+	}
+}
+
+// WithRuntimeStats 每隔 interval 写入一条 [runtime] 日志，包含堆大小、期间的 GC 次数与最大停顿、
+// goroutine 数及打开的文件描述符数（仅 Linux 等提供 /proc 的系统）
+func WithRuntimeStats(interval time.Duration) Option {
+	return func(option *Options) {
+		option.RuntimeStats = interval
+	}
+}
+
+// WithErrorReporter 将 Error 及以上级别的日志（含 CatchException 捕获的 panic）连同 flight recorder
+// 中此前的日志交给 report，启用 WithFlightRecorder 时每个上报的事件都带有出错前的上下文。如接入 Sentry：
+//
+//	log.WithErrorReporter(func(e log.Entry, crumbs []log.Breadcrumb) {
+//		hub := sentry.CurrentHub().Clone()
+//		for _, b := range crumbs {
+//			hub.AddBreadcrumb(&sentry.Breadcrumb{Timestamp: b.Time, Level: sentry.Level(b.Level),
+//				Category: b.Category, Message: b.Message, Data: b.Data}, nil)
+//		}
+//		hub.CaptureMessage(e.Message)
+//	})
+func WithErrorReporter(report ErrorReporter) Option {
+	return func(option *Options) {
+		option.ErrorReporter = report
+	}
+}
+
+// WithSpanEvents 将通过 FromContext(ctx) 记录、不低于 level 或带有 SpanEvent() 字段的日志交给 fn，
+// 由 fn 写入 ctx 中当前 span 的事件，使关键日志显示在链路追踪视图中。如对接 OpenTelemetry：
+//
+//	log.WithSpanEvents(zapcore.WarnLevel, func(ctx context.Context, e log.Entry) {
+//		span := trace.SpanFromContext(ctx)
+//		if !span.IsRecording() {
+//			return
+//		}
+//		attrs := []attribute.KeyValue{attribute.String("log.severity", e.Level.String())}
+//		for k, v := range e.Fields {
+//			attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+//		}
+//		span.AddEvent(e.Message, trace.WithAttributes(attrs...))
+//	})
+//
+// OpenTracing 可使用 opentracing.SpanFromContext(ctx).LogFields。
+func WithSpanEvents(level zapcore.Level, fn SpanLogger) Option {
+	return func(option *Options) {
+		option.SpanLevel = level
+		option.SpanLogger = fn
+	}
+}
+
+// WithClock 设置日志时间、文件切割及后台任务使用的时钟，为 nil 时使用 zapcore.DefaultClock
+func WithClock(clock zapcore.Clock) Option {
+	return func(option *Options) {
+		if clock == nil {
+			clock = zapcore.DefaultClock
+		}
+		option.Clock = clock
+	}
+}
+
+func WithSink(ws zapcore.WriteSyncer) Option {
+	return func(option *Options) {
+		option.Sinks = append(option.Sinks, ws)
+	}
+}
+
+func WithClaims(fn ClaimsFunc) Option {
+	return func(option *Options) {
+		option.Claims = fn
+	}
+}
+
+// WithCorrelationID 设置 RequestLogger 在请求未携带 X-Request-ID 时使用的 ID 生成器，如 NewUUID、NewULID
+func WithCorrelationID(generator func() string) Option {
+	return func(option *Options) {
+		option.CorrelationID = generator
+	}
+}
+
+func WithColor(mode ColorMode) Option {
+	return func(option *Options) {
+		option.Color = mode
+	}
+}
+
+func WithConsoleEncoding(encoding string) Option {
+	return func(option *Options) {
+		option.ConsoleEncoding = strings.ToLower(encoding)
+	}
+}
+
+func levelEncoder(color bool) zapcore.LevelEncoder {
+	if color {
+		return capitalColorLevelEncoder
+	}
+	return capitalLevelEncoder
+}
+
 func (l *Logger) cores() zap.Option {
 	fileEncoder := zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig)
 
 	encoderConfig := zap.NewDevelopmentEncoderConfig()
 	encoderConfig.EncodeTime = timeEncoder
-	encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	encoderConfig.LineEnding = l.zapConfig.EncoderConfig.LineEnding
+	encoderConfig.EncodeLevel = l.consoleLevelEncoder(useColor(l.Opts.Color, os.Stdout))
 	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
+	if !l.Opts.InlineStack {
+		consoleEncoder = multilineStackEncoder{consoleEncoder, encoderConfig.LineEnding}
+	}
+	if l.Opts.ConsoleEncoding == "json" {
+		consoleEncoder = zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig)
+	}
+	if l.Opts.PrettyJSON && l.Opts.Development {
+		consoleEncoder = prettyEncoder{zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig)}
+	}
 
+	// 命名 logger 的等级可能低于全局等级，按名称的过滤由 levelGateCore 完成
 	filePriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= l.zapConfig.Level.Level()
+		return lvl >= l.minLevel()
 	})
 
-	cores := []zapcore.Core{zapcore.NewCore(fileEncoder, fileWs, filePriority)}
-	if l.Opts.Development {
-		cores = append(cores, []zapcore.Core{zapcore.NewCore(consoleEncoder, consoleWs, filePriority)}...)
+	consolePriority := zapcore.LevelEnabler(filePriority)
+	if l.Opts.ConsoleLevel != nil {
+		consolePriority = *l.Opts.ConsoleLevel
+	}
+
+	console := l.Opts.Development
+	if l.Opts.Console != nil {
+		console = *l.Opts.Console
+	}
+
+	var cores []zapcore.Core
+	if l.Opts.File {
+		cores = append(cores, zapcore.NewCore(fileEncoder, l.stats.sink("file", fileWs, probeFiles(l.mainFilePaths()...)), filePriority))
+		cores = append(cores, l.levelCores(fileEncoder, filePriority)...)
+	}
+	for i, ws := range l.Opts.Sinks {
+		cores = append(cores, zapcore.NewCore(fileEncoder, l.stats.sink("sink-"+strconv.Itoa(i), ws, probeSink(ws)), filePriority))
+	}
+	if console && l.Opts.SplitStream {
+		outPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			return lvl < zapcore.WarnLevel && consolePriority.Enabled(lvl)
+		})
+		errPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			return lvl >= zapcore.WarnLevel && consolePriority.Enabled(lvl)
+		})
+		cores = append(cores, []zapcore.Core{
+			zapcore.NewCore(consoleEncoder, l.stats.sink("stdout", consoleWs, nil), outPriority),
+			zapcore.NewCore(consoleEncoder, l.stats.sink("stderr", consoleErrWs, nil), errPriority),
+		}...)
+	} else if console {
+		cores = append(cores, []zapcore.Core{zapcore.NewCore(consoleEncoder, l.stats.sink("console", consoleWs, nil), consolePriority)}...)
+	}
+	var core zapcore.Core = &statsCore{Core: newMultiCore(cores...), stats: l.stats}
+	if l.Opts.ShadowEncoder != nil && l.Opts.ShadowWriter != nil {
+		l.shadow = &shadowStats{}
+		core = &shadowCore{Core: core, primary: fileEncoder.Clone(), shadow: l.Opts.ShadowEncoder, ws: l.stats.sink("shadow", l.Opts.ShadowWriter, nil),
+			compare: l.Opts.ShadowCompare, stats: l.shadow}
+	}
+	if l.Opts.StormGuard != nil {
+		storm := &stormState{guard: *l.Opts.StormGuard, core: core}
+		core = &stormCore{Core: core, state: storm}
+		go l.stormLoop(storm)
+	}
+	if len(l.Opts.Budgets) > 0 {
+		l.budgets = make(map[string]*budgetState, len(l.Opts.Budgets))
+		for name, b := range l.Opts.Budgets {
+			l.budgets[name] = &budgetState{name: name, budget: b}
+		}
+		core = &budgetCore{Core: core, budgets: l.budgets, enc: fileEncoder.Clone(), marker: core}
+	}
+	if l.Opts.AsyncQueueSize > 0 {
+		l.queue = newAsyncQueue(core, l.Opts.AsyncQueueSize, l.Opts.Backpressure, l.done)
+		core = newAsyncCore(core, l.queue, l.zapConfig.EncoderConfig)
+	}
+	if l.committer != nil {
+		core = &durableCore{Core: core, gc: l.committer}
+	}
+	if p := l.Opts.SyncPolicy; p.Entries > 0 || p.OnError {
+		core = &syncPolicyCore{Core: core, policy: p, count: new(uint64)}
+	}
+	if l.Opts.SyncPolicy.Interval > 0 {
+		go l.syncLoop(core)
+	}
+	core = &outputGateCore{Core: core, global: l.zapConfig.Level}
+	if l.Opts.FlightRecorderSize > 0 {
+		l.recorder = newFlightRecorder(l.Opts.FlightRecorderSize)
+		core = newRecorderCore(core, l.recorder, fileEncoder, fileWs)
+	}
+	if l.Opts.ErrorReporter != nil {
+		core = &reporterCore{Core: core, report: l.Opts.ErrorReporter, l: l}
+	}
+	subscribers.setHistory(l.Opts.StreamHistory)
+	core = &hubCore{Core: core, hub: subscribers}
+	if l.Opts.SpanLogger != nil {
+		core = &spanCore{Core: core, level: l.Opts.SpanLevel, fn: l.Opts.SpanLogger}
+	}
+	if l.Opts.File && l.Opts.CaptureFileName != "" {
+		core = &captureCore{Core: core, capture: l.captureFile(fileEncoder), keys: l.Opts.CaptureKeys}
 	}
+	if processors := l.processors(); len(processors) > 0 {
+		core = &processorCore{Core: core, processors: processors}
+	}
+	providers := append([]func() []zap.Field{mdcFields}, l.Opts.FieldProviders...)
+	core = &fieldProviderCore{Core: core, providers: providers}
+	core = &fatalHookCore{Core: core}
+	core = &levelGateCore{Core: core, global: l.zapConfig.Level, side: l.sideLevel}
 	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-		return zapcore.NewTee(cores...)
+		return core
 	})
 }
 
+func (l *Logger) processors() []Processor {
+	processors := append([]Processor(nil), l.Opts.Processors...)
+	if len(l.Opts.RedactKeys) > 0 {
+		processors = append(processors, redactKeys(l.Opts.RedactKeys))
+	}
+	redactors := make([]Redactor, 0, len(l.Opts.Scrubbers)+len(l.Opts.Redactors))
+	for _, s := range l.Opts.Scrubbers {
+		redactors = append(redactors, s)
+	}
+	redactors = append(redactors, l.Opts.Redactors...)
+	if len(redactors) > 0 {
+		processors = append(processors, redact(redactors))
+	}
+	if len(l.Opts.RequiredFields) > 0 {
+		mode := l.Opts.RequiredMode
+		if l.Opts.Development {
+			mode = RequiredFlag
+		}
+		processors = append(processors, requiredFields(l.Opts.RequiredFields, mode))
+	}
+	if l.Opts.MessageTemplate != "" {
+		processors = append(processors, messageTemplate(l.Opts.MessageTemplate, l.Opts.AppName))
+	}
+	if l.Opts.BinaryPolicy != nil {
+		processors = append(processors, binaryPolicy(*l.Opts.BinaryPolicy))
+	}
+	if l.Opts.FieldLimits != (FieldLimits{}) {
+		processors = append(processors, fieldLimits(l.Opts.FieldLimits))
+	}
+	if l.Opts.MaxEntrySize > 0 {
+		processors = append(processors, maxEntrySize(l.Opts.MaxEntrySize, l.zapConfig.EncoderConfig))
+	}
+	return processors
+}
+
 func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("2006-01-02 15:04:05.000"))
 }
@@ -293,6 +1365,21 @@ func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 // 	enc.AppendInt64(t.UnixNano() / 1e6)
 // }
 
+// Enabled 返回全局 logger 是否会把 level 级别的日志写入主输出，按全局等级判断，
+// 不因 flight recorder、订阅者等旁路或命名 logger 的等级而放宽
+func Enabled(level zapcore.Level) bool {
+	logger := current()
+	if g, ok := logger.Core().(*levelGateCore); ok {
+		return g.Core.Enabled(level) && (g.debug || level >= g.level(""))
+	}
+	return logger.Core().Enabled(level)
+}
+
+// Check 与 zap.Logger.Check 相同，level 未开启时返回 nil，可避免构造不会输出的字段
+func Check(level zapcore.Level, msg string) *zapcore.CheckedEntry {
+	return skipLogger().Check(level, msg)
+}
+
 func SetLevel(name string) {
 	if l == nil {
 		return
@@ -300,6 +1387,9 @@ func SetLevel(name string) {
 
 	l.Opts.Level = strToLevel(strings.ToLower(name))
 	l.zapConfig.Level.SetLevel(l.Opts.Level)
+	if err := l.saveLevelState(); err != nil {
+		l.Warn("[SetLevel] persist level failed", zap.Error(err))
+	}
 	l.Info("[SetLevel] success", zap.String("level", name))
 }
 
@@ -308,65 +1398,306 @@ func Sync() {
 		return
 	}
 	l.Logger.Sync()
+	if l.audit != nil {
+		l.audit.Sync()
+	}
+	if l.access != nil {
+		l.access.Sync()
+	}
+	if l.accessJSON != nil {
+		l.accessJSON.Sync()
+	}
+	if l.events != nil {
+		l.events.Sync()
+	}
+}
+
+// SyncContext 与 Sync 相同，但 ctx 结束时不再等待并返回 ctx.Err()，未完成的 Sync 在后台继续，
+// 避免退出流程因无响应的输出而挂起
+func SyncContext(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		Sync()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // ------------------------------------------------
 // ------------------------------------------------
 
+// PanicAction CatchException 处理完 panic 后的行为
+type PanicAction int
+
+const (
+	PanicSwallow PanicAction = iota // 吞掉 panic，继续执行
+	PanicRepanic                    // 重新抛出 panic
+	PanicExit                       // 以 PanicExitCode 退出进程
+)
+
 // catch exception for no panic
 func CatchException() {
 	if err := recover(); err != nil {
-		logfile, err2 := os.OpenFile(newDumpFile(), os.O_RDWR|os.O_APPEND|os.O_CREATE, os.ModePerm)
-		if err2 != nil {
-			fmt.Println(err2)
+		// 在 OnPanic 回调之前读取，回调返回后不再访问全局 logger，回调中可以替换 logger
+		lg := l
+		handlePanic(err, debug.Stack())
+		if lg != nil {
+			afterPanic(err, lg.Opts.PanicAction, lg.Opts.PanicExitCode)
+		}
+	}
+}
+
+// CatchExceptionRepanic 写入 dump 后重新抛出 panic
+func CatchExceptionRepanic() {
+	if err := recover(); err != nil {
+		handlePanic(err, debug.Stack())
+		afterPanic(err, PanicRepanic, 0)
+	}
+}
+
+// CatchExceptionExit 写入 dump 后以 code 退出进程
+func CatchExceptionExit(code int) {
+	if err := recover(); err != nil {
+		handlePanic(err, debug.Stack())
+		afterPanic(err, PanicExit, code)
+	}
+}
+
+// PanicError Recover 将 panic 转换成的错误
+type PanicError struct {
+	Value interface{} // panic 的值
+	Stack []byte      // panic 时的堆栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap panic 的值为 error 时返回该 error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover 与 CatchException 相同地写入日志与 dump，并将 panic 转换为 *PanicError 赋给 *errp，
+// 覆盖函数原本的返回值，不执行 WithPanicAction 的动作。需以 defer 直接调用：
+//
+//	func work() (err error) {
+//		defer log.Recover(&err)
+//		...
+//	}
+func Recover(errp *error) {
+	if r := recover(); r != nil {
+		stack := debug.Stack()
+		handlePanic(r, stack)
+		if errp != nil {
+			*errp = &PanicError{Value: r, Stack: stack}
+		}
+	}
+}
+
+func afterPanic(err interface{}, action PanicAction, code int) {
+	switch action {
+	case PanicRepanic:
+		flushDedup()
+		Sync()
+		// 未被恢复的 panic 由 runtime 直接写入 fd 2，须到达原来的 stderr
+		restoreStdio(false)
+		panic(err)
+	case PanicExit:
+		flushDedup()
+		Sync()
+		restoreStdio(false)
+		os.Exit(code)
+	}
+}
+
+// Go 启动一个已安装 CatchException 的 goroutine，避免 panic 导致进程退出
+func Go(fn func()) {
+	go func() {
+		defer CatchException()
+		fn()
+	}()
+}
+
+// GoCtx 与 Go 相同，并将 ctx 传给 fn
+func GoCtx(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer CatchException()
+		fn(ctx)
+	}()
+}
+
+// OnPanic 注册 CatchException 捕获到 panic 后执行的回调
+func OnPanic(fn func(recovered interface{}, stack []byte)) {
+	panicHooksMu.Lock()
+	defer panicHooksMu.Unlock()
+	panicHooks = append(panicHooks, fn)
+}
+
+var (
+	panicHooksMu sync.RWMutex
+	panicHooks   []func(recovered interface{}, stack []byte)
+)
+
+func handlePanic(err interface{}, stack []byte, fields ...zap.Field) {
+	var dump string
+	if l != nil && l.Opts.DumpDedupWindow > 0 {
+		signature := panicSignature(err, stack, l.Opts.DumpDedupFrames)
+		var repeated bool
+		repeated, dump = dedupPanic(signature, l.Opts.DumpDedupWindow, func() string { return writeDump(err, stack) })
+		fields = append(fields[:len(fields):len(fields)], zap.String("signature", signature))
+		if repeated {
+			runPanicHooks(err, stack)
 			return
 		}
+	} else {
+		dump = writeDump(err, stack)
+	}
+
+	Error("[CatchException] panic recovered", append([]zap.Field{
+		zap.Any("panic", err),
+		zap.Int64("goroutine", goroutineID(stack)),
+		zap.String("stack", string(stack)),
+		zap.String("dump", dump),
+	}, fields...)...)
+	runPanicHooks(err, stack)
+}
+
+func runPanicHooks(err interface{}, stack []byte) {
+	panicHooksMu.RLock()
+	hooks := panicHooks
+	panicHooksMu.RUnlock()
+	for _, hook := range hooks {
+		hook(err, stack)
+	}
+}
 
-		defer logfile.Close()
-		logger := log.New(logfile, "", log.Ldate|log.Lmicroseconds|log.Lshortfile)
-		logger.SetFlags(0)
+// writeDump 按 WithDumpFormat 将 panic 信息写入文本与/或 JSON dump 文件，返回文本 dump 的路径，
+// 只写 JSON 时返回 JSON dump 的路径
+func writeDump(err interface{}, stack []byte) string {
+	path := newDumpFile("")
+	format := DumpText
+	if l != nil {
+		format = l.Opts.DumpFormat
+	}
+	if format == DumpJSON || format == DumpBoth {
+		jsonPath := strings.TrimSuffix(path, ".log") + ".json"
+		if err2 := writeJSONDump(jsonPath, err, stack); err2 != nil {
+			fmt.Println(err2)
+		}
+		if format == DumpJSON {
+			return jsonPath
+		}
+	}
+	return writeTextDump(path, err, stack)
+}
 
-		strLog := fmt.Sprintf(`
+// writeTextDump 将 panic 信息以文本写入 path
+func writeTextDump(path string, err interface{}, stack []byte) string {
+	logfile, err2 := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, os.ModePerm)
+	if err2 != nil {
+		fmt.Println(err2)
+		return ""
+	}
+
+	defer logfile.Close()
+	logger := log.New(logfile, "", log.Ldate|log.Lmicroseconds|log.Lshortfile)
+	logger.SetFlags(0)
+
+	appName := ""
+	if l != nil {
+		appName = l.Opts.AppName
+	}
+	version, revision := buildInfo()
+	hostname, _ := os.Hostname()
+	strLog := fmt.Sprintf(`
 ===============================================================================
 TIME: %v
 EXCEPTION: %#v
+APP: %s
+VERSION: %s
+REVISION: %s
+HOST: %s
+UPTIME: %v
 ===============================================================================		
 %s`,
-			time.Now(),
-			err,
-			string(debug.Stack()))
+		now(),
+		err,
+		appName,
+		version,
+		revision,
+		hostname,
+		now().Sub(startTime).Round(time.Second),
+		string(stack))
+	if l != nil && l.Opts.DumpAllGoroutines {
+		strLog += "\n" + runtimeSummary() + "\n" + string(allStacks())
+	}
 
-		logger.Println(strLog)
-		fmt.Println(strLog)
+	logger.Println(strLog)
+	fmt.Println(strLog)
+	return logfile.Name()
+}
+
+// goroutineID 从 "goroutine 123 [running]:" 形式的堆栈首行解析 goroutine ID
+func goroutineID(stack []byte) int64 {
+	line := stack
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
 	}
+	fields := bytes.Fields(line)
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(fields[1]), 10, 64)
+	return id
 }
 
-// generate dumpfile
-func newDumpFile() string {
+// exceptionsDir 返回 exception dump 的根目录
+func exceptionsDir() string {
+	if l != nil && l.Opts.DumpDir != "" {
+		return strings.TrimRight(l.Opts.DumpDir, "/")
+	}
+	binDir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+	return strings.TrimRight(binDir, "/") + "/exceptions"
+}
+
+// generate dumpfile，同名的 JSON dump（.json）存在时也视为已占用。
+// suffix 附加在时间之后、扩展名之前，用于区分诊断 dump 等非崩溃的 dump
+func newDumpFile(suffix string) string {
 	var isFileExist = func(fn string) bool {
-		finfo, err := os.Stat(fn)
-		if err != nil {
-			return false
-		}
-		if finfo.IsDir() {
-			return false
+		for _, name := range []string{fn, strings.TrimSuffix(fn, ".log") + ".json"} {
+			if finfo, err := os.Stat(name); err == nil && !finfo.IsDir() {
+				return true
+			}
 		}
-		return true
+		return false
 	}
 
-	binDir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-	now := time.Now()
-	filename := fmt.Sprintf("exceptions.%02d_%02d_%02d", now.Hour(), now.Minute(), now.Second())
-	dir := fmt.Sprintf("%s/exceptions/%04d-%02d-%02d/", strings.TrimRight(binDir, "/"), now.Year(), int(now.Month()), now.Day())
+	now := now()
+	naming, nest := "exceptions.15_04_05", true
+	if l != nil {
+		naming, nest = l.Opts.DumpNaming, l.Opts.DumpNestByDate
+	}
+	filename := now.Format(naming)
+	dir := exceptionsDir() + "/"
+	if nest {
+		dir = fmt.Sprintf("%s/%04d-%02d-%02d/", exceptionsDir(), now.Year(), int(now.Month()), now.Day())
+	}
 	os.MkdirAll(dir, os.ModePerm)
-	fn := fmt.Sprintf("%s%s.log", dir, filename)
+	fn := fmt.Sprintf("%s%s%s.log", dir, filename, suffix)
 	if !isFileExist(fn) {
 		return fn
 	}
 
 	n := 1
 	for {
-		fn = fmt.Sprintf("%s%s_%d.log", dir, filename, n)
+		fn = fmt.Sprintf("%s%s_%d%s.log", dir, filename, n, suffix)
 		if !isFileExist(fn) {
 			break
 		}
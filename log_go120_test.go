@@ -0,0 +1,29 @@
+//go:build go1.20
+// +build go1.20
+
+package log
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchContextCause(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	ctx, cancel := context.WithCancelCause(context.Background())
+	WatchContext(ctx, "export aborted")
+	cancel(errors.New("client went away"))
+
+	time.Sleep(50 * time.Millisecond)
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"msg":"export aborted","reason":"canceled","cause":"client went away"`) {
+		t.Fatalf("missing cause in %s", data)
+	}
+}
@@ -1,11 +1,38 @@
 package log
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestInitZapV2Logger(t *testing.T) {
@@ -18,3 +45,3656 @@ func TestInitZapV2Logger(t *testing.T) {
 		lg.Error(fmt.Sprint("err log ", 4), zap.String("level", `{"a":"7","b":"8"}`))
 	}
 }
+
+// swapConsole 把控制台输出替换为临时文件，测试结束时恢复
+func swapConsole(t *testing.T) (stdout, stderr *os.File) {
+	dir := t.TempDir()
+	stdout, _ = os.Create(filepath.Join(dir, "stdout"))
+	stderr, _ = os.Create(filepath.Join(dir, "stderr"))
+	out, errOut := consoleWs, consoleErrWs
+	consoleWs, consoleErrWs = stdout, stderr
+	t.Cleanup(func() { consoleWs, consoleErrWs = out, errOut })
+	return stdout, stderr
+}
+
+func TestConsoleEncodingJSON(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _ := swapConsole(t)
+	lg := NewLogger(WithLogFileDir(dir), WithConsole(true), WithConsoleEncoding("JSON"))
+	lg.Info("to both", zap.String("k", "v"))
+	lg.Sync()
+
+	console, _ := os.ReadFile(stdout.Name())
+	file, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(console), `"msg":"to both","k":"v"`) || string(console) != string(file) {
+		t.Fatalf("console %q differs from file %q", console, file)
+	}
+}
+
+func TestConsoleLevel(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _ := swapConsole(t)
+	lg := NewLogger(WithLogFileDir(dir), WithLevel("debug"), WithConsole(true), WithConsoleLevel("warn"))
+	lg.Debug("debug entry")
+	lg.Warn("warn entry")
+	lg.Sync()
+
+	console, _ := os.ReadFile(stdout.Name())
+	file, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if strings.Contains(string(console), "debug entry") || !strings.Contains(string(console), "warn entry") {
+		t.Fatalf("console level not applied: %s", console)
+	}
+	if !strings.Contains(string(file), "debug entry") || !strings.Contains(string(file), "warn entry") {
+		t.Fatalf("file level changed by console level: %s", file)
+	}
+}
+
+func TestConsoleAndFileToggles(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		opts          []Option
+		console, file bool
+	}{
+		{"production default", nil, false, true},
+		{"development default", []Option{WithDevelopment(true)}, true, true},
+		{"console in production", []Option{WithConsole(true)}, true, true},
+		{"no console in development", []Option{WithDevelopment(true), WithConsole(false)}, false, true},
+		{"console only", []Option{WithConsole(true), WithFile(false)}, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			stdout, _ := swapConsole(t)
+			lg := NewLogger(append([]Option{WithLogFileDir(dir)}, tc.opts...)...)
+			lg.Info("toggled")
+			lg.Sync()
+
+			console, _ := os.ReadFile(stdout.Name())
+			if got := strings.Contains(string(console), "toggled"); got != tc.console {
+				t.Fatalf("console output %v, want %v", got, tc.console)
+			}
+			_, err := os.Stat(filepath.Join(dir, "app.log"))
+			if got := err == nil; got != tc.file {
+				t.Fatalf("file output %v, want %v", got, tc.file)
+			}
+		})
+	}
+}
+
+func TestSplitStream(t *testing.T) {
+	stdout, stderr := swapConsole(t)
+	lg := NewLogger(WithLogFileDir(t.TempDir()), WithConsole(true), WithSplitStream(true), WithConsoleLevel("debug"))
+	lg.Debug("debug entry")
+	lg.Info("info entry")
+	lg.Warn("warn entry")
+	lg.Error("error entry")
+	lg.Sync()
+
+	out, _ := os.ReadFile(stdout.Name())
+	errOut, _ := os.ReadFile(stderr.Name())
+	if !strings.Contains(string(out), "debug entry") || !strings.Contains(string(out), "info entry") ||
+		strings.Contains(string(out), "warn entry") || strings.Contains(string(out), "error entry") {
+		t.Fatalf("unexpected stdout: %s", out)
+	}
+	if !strings.Contains(string(errOut), "warn entry") || !strings.Contains(string(errOut), "error entry") ||
+		strings.Contains(string(errOut), "info entry") {
+		t.Fatalf("unexpected stderr: %s", errOut)
+	}
+}
+
+// failingSink 写入总是失败的输出
+type failingSink struct{}
+
+func (failingSink) Write([]byte) (int, error) { return 0, errors.New("sink unavailable") }
+func (failingSink) Sync() error               { return nil }
+
+func TestInternalErrorOutput(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithSink(failingSink{}), WithInternalErrorOutput("zap-errors.log"))
+	lg.Info("lost")
+	lg.Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "zap-errors.log"))
+	if !strings.Contains(string(data), "write error: sink unavailable") {
+		t.Fatalf("internal error not written: %q", data)
+	}
+	if out, _ := os.ReadFile(filepath.Join(dir, "app.log")); strings.Contains(string(out), "sink unavailable") {
+		t.Fatalf("internal error written to the log file: %s", out)
+	}
+}
+
+func TestWithHook(t *testing.T) {
+	var warns int32
+	var seen []string
+	var mu sync.Mutex
+	lg := NewLogger(WithLogFileDir(t.TempDir()),
+		WithHook(func(e zapcore.Entry) error {
+			if e.Level >= zapcore.WarnLevel {
+				atomic.AddInt32(&warns, 1)
+			}
+			return nil
+		}),
+		WithHook(func(e zapcore.Entry) error {
+			mu.Lock()
+			seen = append(seen, e.Message)
+			mu.Unlock()
+			return nil
+		}))
+	// 忽略 NewLogger 自身写入的日志
+	mu.Lock()
+	seen = nil
+	mu.Unlock()
+	atomic.StoreInt32(&warns, 0)
+	lg.Info("a")
+	lg.Warn("b")
+	lg.Error("c")
+
+	if atomic.LoadInt32(&warns) != 2 {
+		t.Fatalf("hook counted %d warnings, want 2", warns)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(seen, ",") != "a,b,c" {
+		t.Fatalf("hooks saw %v", seen)
+	}
+}
+
+func TestFieldProvider(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	lg := NewLogger(WithLogFileDir(dir), WithFieldProvider(func() []zap.Field {
+		n++
+		return []zap.Field{zap.Int("epoch", n)}
+	}))
+	lg.Info("first")
+	lg.Info("second")
+	lg.Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"second","epoch":3`) {
+		t.Fatalf("unexpected log content: %s", data)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if useColor(ColorAuto, f) {
+		t.Error("regular file should not be colored")
+	}
+	if !useColor(ColorAlways, f) {
+		t.Error("ColorAlways should force color")
+	}
+	t.Setenv("FORCE_COLOR", "1")
+	if !useColor(ColorAuto, f) {
+		t.Error("FORCE_COLOR should enable color")
+	}
+	t.Setenv("NO_COLOR", "")
+	if useColor(ColorAuto, f) {
+		t.Error("NO_COLOR should disable color")
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithProcessor(func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		if ent.Message == "drop" {
+			return ent, fields, false
+		}
+		ent.Message = strings.ToUpper(ent.Message)
+		return ent, append(fields, zap.Bool("processed", true)), true
+	}))
+	lg.With(zap.String("user", "u1")).Info("keep")
+	lg.Info("drop")
+	lg.Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"KEEP","user":"u1","processed":true`) {
+		t.Fatalf("entry not processed: %s", data)
+	}
+	if strings.Contains(string(data), "DROP") {
+		t.Fatalf("entry not dropped: %s", data)
+	}
+}
+
+func TestWrappedCoreRespectsConsoleLevel(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.CreateTemp(dir, "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(ws zapcore.WriteSyncer) { consoleWs = ws }(consoleWs)
+	consoleWs = out
+
+	keep := func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		return ent, fields, true
+	}
+	lg := NewLogger(WithLogFileDir(dir), WithConsole(true), WithConsoleLevel("warn"), WithProcessor(keep))
+	lg.Info("file only")
+	lg.Warn("both")
+	lg.Sync()
+
+	data, _ := os.ReadFile(out.Name())
+	if strings.Contains(string(data), "file only") || !strings.Contains(string(data), "both") {
+		t.Fatalf("console level not respected: %s", data)
+	}
+}
+
+func TestRedactKeys(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithRedactKeys("password", "Token"))
+	lg.Info("login",
+		zap.String("password", "secret"),
+		zap.Any("req", map[string]interface{}{"user": "u1", "auth": map[string]string{"token": "abc"}}),
+	)
+	lg.Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "abc") {
+		t.Fatalf("value not redacted: %s", data)
+	}
+	if !strings.Contains(string(data), `"token":"[REDACTED]"`) {
+		t.Fatalf("nested key not redacted: %s", data)
+	}
+}
+
+func TestScrubbers(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithScrubbers(ScrubEmail, ScrubCreditCard, ScrubBearerToken))
+	lg.Info("mail sent to foo@example.com", zap.String("card", "4111 1111 1111 1111"), zap.String("auth", "Bearer eyJhbGciOi.abc"))
+	lg.Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"foo@example.com", "4111", "eyJhbGciOi"} {
+		if strings.Contains(string(data), leak) {
+			t.Fatalf("%q not scrubbed: %s", leak, data)
+		}
+	}
+}
+
+func TestRedactors(t *testing.T) {
+	cases := []struct {
+		r        Redactor
+		in, want string
+	}{
+		{MaskPhone, "call 138 0013 8000", "call ****8000"},
+		{MaskIBAN, "iban DE89370400440532013000", "iban ****3000"},
+		{ScrubJWT, "jwt eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig", "jwt [JWT]"},
+		{MaskPartial(regexp.MustCompile(`\d{16}`), 4), "card 4111111111111111", "card ****1111"},
+	}
+	for _, c := range cases {
+		if got := c.r.Redact(c.in); got != c.want {
+			t.Errorf("Redact(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LOG_KEY", "000102030405060708090a0b0c0d0e0f")
+	lg := NewLogger(WithLogFileDir(dir), WithEncryption(KeyFromEnv("LOG_KEY")))
+	lg.Info("top secret")
+	lg.Sync()
+
+	f, err := os.Open(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	key, _ := KeyFromEnv("LOG_KEY")()
+	r, err := NewDecryptReader(f, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"top secret"`) {
+		t.Fatalf("unexpected plaintext: %s", data)
+	}
+}
+
+func TestHMACChain(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("audit-key")
+	lg := NewLogger(WithLogFileDir(dir), WithHMACChain(key))
+	lg.Info("one")
+	lg.Info("two")
+	lg.Sync()
+
+	// 重启后继续哈希链
+	lg = NewLogger(WithLogFileDir(dir), WithHMACChain(key))
+	lg.Info("three")
+	lg.Sync()
+
+	path := filepath.Join(dir, "app.log")
+	if err := Verify(key, path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), "two", "2", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(key, path); err == nil {
+		t.Fatal("tampered file passed verification")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("error"), WithAudit("audit.log"))
+	Audit("user.delete", zap.String("actor", "admin"), zap.String("action", "delete"), zap.String("resource", "user/1"))
+	Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"user.delete"`) || !strings.Contains(string(data), `"missing_fields":["outcome"]`) {
+		t.Fatalf("unexpected audit log: %s", data)
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithAccessLog("access.log", AccessLogCombined))
+	h := AccessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+	req := httptest.NewRequest("GET", "/brew?x=1", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "access.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"GET /brew?x=1 HTTP/1.1" 418 5 "-" "curl/8.0"`) {
+		t.Fatalf("unexpected access log: %s", data)
+	}
+}
+
+func TestEvent(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithEvents("events.log"))
+	RegisterEventSchema("order.paid", EventSchema{
+		Required: []string{"order_id", "amount"},
+		Types:    map[string]string{"order_id": "string", "amount": "number"},
+	})
+
+	if err := Event("order.paid", map[string]interface{}{"order_id": "o1"}); err == nil {
+		t.Error("missing field not reported")
+	}
+	if err := Event("order.paid", map[string]interface{}{"order_id": 1, "amount": 9.9}); err == nil {
+		t.Error("wrong type not reported")
+	}
+	if err := Event("order.paid", map[string]interface{}{"order_id": "o1", "amount": 9.9}); err != nil {
+		t.Fatal(err)
+	}
+	Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "events.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "\n") != 1 || !strings.Contains(string(data), `"order_id":"o1"`) {
+		t.Fatalf("unexpected events log: %s", data)
+	}
+}
+
+func TestFlightRecorder(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithLevel("warn"), WithFlightRecorder(2))
+	lg.Debug("step 1")
+	lg.Debug("step 2")
+	lg.Info("step 3")
+	lg.Warn("not recorded")
+	lg.Error("failed")
+	lg.Sync()
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	if strings.Contains(s, "step 1") {
+		t.Fatalf("ring buffer overflow not handled: %s", s)
+	}
+	if i, j := strings.Index(s, "step 2"), strings.Index(s, "step 3"); i < 0 || j < i || j > strings.Index(s, "failed") {
+		t.Fatalf("recorded entries not flushed before error: %s", s)
+	}
+	if !strings.Contains(s, `"flight_recorder":true`) {
+		t.Fatalf("flushed entries not marked: %s", s)
+	}
+}
+
+func TestErrorReporterBreadcrumbs(t *testing.T) {
+	var reported []Entry
+	var crumbs [][]Breadcrumb
+	lg := NewLogger(WithLogFileDir(t.TempDir()), WithLevel("warn"), WithFlightRecorder(5), WithErrorReporter(func(e Entry, b []Breadcrumb) {
+		reported = append(reported, e)
+		crumbs = append(crumbs, b)
+	}))
+	lg.Named("cart").Debug("loaded cart", zap.Int("items", 3))
+	lg.Info("charging card")
+	lg.Warn("slow gateway")
+	lg.With(zap.String("order", "o-1")).Error("charge failed", zap.String("code", "declined"))
+	lg.Error("second failure")
+
+	if len(reported) != 2 || reported[0].Message != "charge failed" || reported[0].Fields["order"] != "o-1" || reported[0].Fields["code"] != "declined" {
+		t.Fatalf("reported %+v", reported)
+	}
+	first := crumbs[0]
+	if len(first) != 3 || first[0].Message != "[NewLogger] success" {
+		t.Fatalf("breadcrumbs %+v", first)
+	}
+	first = first[1:]
+	if first[0].Message != "loaded cart" || first[0].Category != "cart" || first[0].Level != "debug" || first[0].Data["items"] != float64(3) {
+		t.Fatalf("breadcrumbs %+v", first)
+	}
+	if _, ok := first[0].Data["flight_recorder"]; ok || first[1].Message != "charging card" {
+		t.Fatalf("breadcrumbs %+v", first)
+	}
+	// 第一条 Error 写出后缓冲已清空
+	if len(crumbs[1]) != 0 || len(Breadcrumbs()) != 0 {
+		t.Fatalf("stale breadcrumbs %+v", crumbs[1])
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	lg := NewLogger(WithLogFileDir(t.TempDir()), WithLevel("error"))
+	ch, cancel := Subscribe(zapcore.InfoLevel)
+	lg.With(zap.String("req", "r1")).Info("hello", zap.Int("n", 1))
+	lg.Debug("ignored")
+
+	select {
+	case e := <-ch:
+		if e.Message != "hello" || e.Fields["req"] != "r1" || e.Fields["n"] != int64(1) {
+			t.Fatalf("unexpected entry: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no entry received")
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel not closed after cancel")
+	}
+
+	// 各订阅者的 Fields 互不影响
+	ch1, cancel1 := Subscribe(zapcore.InfoLevel)
+	defer cancel1()
+	ch2, cancel2 := Subscribe(zapcore.InfoLevel)
+	defer cancel2()
+	lg.Info("shared", zap.String("k", "v"))
+	e1 := <-ch1
+	e1.Fields["k"] = "changed"
+	if e2 := <-ch2; e2.Fields["k"] != "v" {
+		t.Fatalf("fields shared between subscribers: %v", e2.Fields)
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	lg := NewLogger(WithLogFileDir(t.TempDir()), WithStreamHistory(10))
+	lg.Info("before connect")
+
+	srv := httptest.NewServer(StreamHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?level=info&regex=connect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		lg.Info("skipped")
+		lg.Info("after connect")
+	}()
+	var got []string
+	buf := make([]byte, 4096)
+	for len(got) < 2 {
+		n, err := resp.Body.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if strings.HasPrefix(line, "data: ") {
+				got = append(got, line)
+			}
+		}
+	}
+	if !strings.Contains(got[0], "before connect") || !strings.Contains(got[1], "after connect") {
+		t.Fatalf("unexpected events: %v", got)
+	}
+
+	bad, err := http.Get(srv.URL + "?level=verbose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown level answered %d", bad.StatusCode)
+	}
+
+	// Trace 日志的事件名与 level 字段
+	NewLogger(WithLogFileDir(t.TempDir()), WithLevel("trace"), WithStreamHistory(10))
+	Trace("traced")
+	rec := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	StreamHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?level=trace", nil).WithContext(ctx))
+	if body := rec.Body.String(); !strings.Contains(body, "event: trace\n") || !strings.Contains(body, `"level":"trace"`) {
+		t.Fatalf("unexpected trace event: %s", body)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	// 模拟 lumberjack 的 gzip 归档文件
+	// 归档时间需在 MaxAge 内，否则打开日志文件时被清理
+	backup, err := os.Create(filepath.Join(dir, "app-"+time.Now().Add(-time.Hour).Format(backupTimeFormat)+".log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(backup)
+	gz.Write([]byte(`{"level":"info","ts":"2020-01-01 00:00:00.000","msg":"paid","order":"X"}` + "\n"))
+	gz.Close()
+	backup.Close()
+
+	lg := NewLogger(WithLogFileDir(dir))
+	lg.Info("shipped", zap.String("order", "X"))
+	lg.Warn("other", zap.String("order", "Y"))
+	lg.Sync()
+
+	var got []string
+	err = Search(Query{Fields: map[string]interface{}{"order": "X"}}, func(e Entry) bool {
+		got = append(got, e.Message)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "paid,shipped" {
+		t.Fatalf("unexpected result: %v", got)
+	}
+
+	got = nil
+	Search(Query{Level: "warn", Since: time.Now().Add(-time.Hour)}, func(e Entry) bool {
+		got = append(got, e.Message)
+		return true
+	})
+	if strings.Join(got, ",") != "other" {
+		t.Fatalf("unexpected result: %v", got)
+	}
+}
+
+func TestCatchExceptionLogsEntry(t *testing.T) {
+	ch, cancel := Subscribe(zapcore.ErrorLevel)
+	defer cancel()
+	NewLogger(WithLogFileDir(t.TempDir()))
+	func() {
+		defer CatchException()
+		panic("boom")
+	}()
+
+	select {
+	case e := <-ch:
+		if e.Fields["panic"] != "boom" || e.Fields["goroutine"].(int64) == 0 || !strings.Contains(e.Fields["stack"].(string), "TestCatchExceptionLogsEntry") {
+			t.Fatalf("unexpected entry: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic not logged")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	sentinel := errors.New("bad state")
+	work := func(v interface{}) (err error) {
+		defer Recover(&err)
+		if v != nil {
+			panic(v)
+		}
+		return nil
+	}
+	if err := work(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err := work(sentinel)
+	var pe *PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, sentinel) || err.Error() != "panic: bad state" || !bytes.Contains(pe.Stack, []byte("TestRecover")) {
+		t.Fatalf("recovered %v", err)
+	}
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), "bad state") {
+		t.Fatalf("panic not logged: %s", data)
+	}
+}
+
+func TestDumpDedup(t *testing.T) {
+	dir := t.TempDir()
+	clock := newTickClock(time.Minute)
+	NewLogger(WithLogFileDir(dir), WithDumpDir(filepath.Join(dir, "exceptions")), WithDumpDedup(time.Minute, 0), WithClock(clock))
+	crash := func(v string) {
+		defer CatchException()
+		panic(v)
+	}
+	for i := 0; i < 3; i++ {
+		crash(fmt.Sprint("loop ", i))
+	}
+	func() {
+		defer CatchException()
+		panic("elsewhere")
+	}()
+	// 窗口期按注入的时钟结束
+	clock.ticks <- time.Now()
+	clock.ticks <- time.Now()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		Sync()
+		if data, _ := os.ReadFile(filepath.Join(dir, "app.log")); strings.Contains(string(data), "more times") {
+			break
+		}
+	}
+
+	var dumps []string
+	filepath.Walk(filepath.Join(dir, "exceptions"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			dumps = append(dumps, path)
+		}
+		return nil
+	})
+	if len(dumps) != 2 {
+		t.Fatalf("dumps %v", dumps)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if n := strings.Count(string(data), "panic recovered"); n != 2 {
+		t.Fatalf("%d full entries in %s", n, data)
+	}
+	if !strings.Contains(string(data), `"msg":"[CatchException] panic seen 2 more times","signature":`) {
+		t.Fatalf("missing repeat marker in %s", data)
+	}
+}
+
+func TestDumpDedupAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	opts := []Option{WithLogFileDir(dir), WithDumpDir(filepath.Join(dir, "exceptions")), WithDumpDedup(time.Hour, 0)}
+	crash := func() {
+		defer CatchException()
+		panic("crash loop")
+	}
+	NewLogger(opts...)
+	crash()
+	// 模拟进程重启：内存中的去重状态丢失
+	dumpDedup.Lock()
+	dumpDedup.seen = map[string]*repeatedPanic{}
+	dumpDedup.Unlock()
+	NewLogger(opts...)
+	crash()
+	crash()
+
+	var dumps []string
+	filepath.Walk(filepath.Join(dir, "exceptions"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && isDumpName(info.Name(), "exceptions.15_04_05") {
+			dumps = append(dumps, path)
+		}
+		return nil
+	})
+	if len(dumps) != 1 {
+		t.Fatalf("dumps %v", dumps)
+	}
+
+	// 因 panic 退出前写入已累计的次数
+	func() {
+		defer func() { recover() }()
+		afterPanic("crash loop", PanicRepanic, 1)
+	}()
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if n := strings.Count(string(data), "panic recovered"); n != 1 {
+		t.Fatalf("%d full entries in %s", n, data)
+	}
+	if !strings.Contains(string(data), `"msg":"[CatchException] panic seen 2 more times"`) {
+		t.Fatalf("repeat count not flushed: %s", data)
+	}
+	dumpDedup.Lock()
+	dumpDedup.seen = map[string]*repeatedPanic{}
+	dumpDedup.Unlock()
+}
+
+func TestJSONDump(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithDumpDir(dir), WithDumpNaming("crash", false), WithDumpFormat(DumpBoth))
+	func() {
+		defer CatchException()
+		panic(errors.New("nil cart"))
+	}()
+	if _, err := os.Stat(filepath.Join(dir, "crash.log")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "crash.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d struct {
+		Panic     string `json:"panic"`
+		PanicType string `json:"panic_type"`
+		Frames    []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"frames"`
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Panic != "nil cart" || d.PanicType != "*errors.errorString" || len(d.Frames) == 0 ||
+		d.Frames[0].Function != "github.com/gocpp/log.TestJSONDump.func1" || !strings.HasSuffix(d.Frames[0].File, "log_test.go") || d.Frames[0].Line == 0 {
+		t.Fatalf("dump %+v", d)
+	}
+
+	NewLogger(WithLogFileDir(dir), WithDumpDir(dir), WithDumpNaming("crash", false), WithDumpFormat(DumpJSON))
+	ch, cancel := Subscribe(zapcore.ErrorLevel)
+	defer cancel()
+	func() {
+		defer CatchException()
+		panic("again")
+	}()
+	e := <-ch
+	if e.Fields["dump"] != filepath.Join(dir, "crash_1.json") {
+		t.Fatalf("dump field %v", e.Fields["dump"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "crash_1.log")); err == nil {
+		t.Fatal("text dump written in json mode")
+	}
+}
+
+func TestShipDumps(t *testing.T) {
+	dir := t.TempDir()
+	dumps := dir
+	os.MkdirAll(filepath.Join(dumps, "2024-01-02"), 0755)
+	old := time.Now().Add(-2 * time.Hour)
+	os.WriteFile(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_00.log"), []byte("EXCEPTION: boom"), 0644)
+	os.Chtimes(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_00.log"), old, old)
+	os.WriteFile(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_01.json"), []byte(`{"panic":"nil map"}`), 0644)
+	os.Chtimes(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_01.json"), old.Add(time.Minute), old.Add(time.Minute))
+	os.WriteFile(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_02.diagnostic.log"), []byte("DIAGNOSTIC: SIGUSR2"), 0644)
+	os.Chtimes(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_02.diagnostic.log"), old.Add(2*time.Minute), old.Add(2*time.Minute))
+
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	ship := DumpsToSink(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}))
+	shipped := func(n int) []map[string]interface{} {
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if buf.Len() == 0 {
+				lines = nil
+			}
+			mu.Unlock()
+			if len(lines) >= n || time.Now().After(deadline) {
+				var out []map[string]interface{}
+				for _, line := range lines {
+					var m map[string]interface{}
+					json.Unmarshal([]byte(line), &m)
+					out = append(out, m)
+				}
+				return out
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	NewLogger(WithLogFileDir(dir), WithDumpDir(dumps), WithShipDumps(ship))
+	got := shipped(2)
+	if len(got) != 2 || got[0]["file"] != "exceptions.10_00_00.log" || got[0]["dump"] != "EXCEPTION: boom" || got[1]["dump"].(map[string]interface{})["panic"] != "nil map" {
+		t.Fatalf("shipped %v", got)
+	}
+
+	os.WriteFile(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_03.log"), []byte("EXCEPTION: again"), 0644)
+	NewLogger(WithLogFileDir(dir), WithDumpDir(dumps), WithShipDumps(ship))
+	time.Sleep(50 * time.Millisecond)
+	if got := shipped(4); len(got) != 3 || got[2]["file"] != "exceptions.10_00_03.log" {
+		t.Fatalf("shipped %v", got)
+	}
+
+	// DumpBoth 写出的两份 dump 只发送 JSON
+	os.WriteFile(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_04.json"), []byte(`{"panic":"both"}`), 0644)
+	os.WriteFile(filepath.Join(dumps, "2024-01-02", "exceptions.10_00_04.log"), []byte("EXCEPTION: both"), 0644)
+	NewLogger(WithLogFileDir(dir), WithDumpDir(dumps), WithShipDumps(ship))
+	time.Sleep(50 * time.Millisecond)
+	if got := shipped(5); len(got) != 4 || got[3]["file"] != "exceptions.10_00_04.json" {
+		t.Fatalf("shipped %v", got)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestOnPanic(t *testing.T) {
+	var got interface{}
+	OnPanic(func(recovered interface{}, stack []byte) {
+		got = recovered
+	})
+	func() {
+		defer CatchException()
+		panic("hooked")
+	}()
+	if got != "hooked" {
+		t.Fatalf("hook not called, got %v", got)
+	}
+}
+
+func TestGo(t *testing.T) {
+	done := make(chan struct{})
+	OnPanic(func(recovered interface{}, stack []byte) {
+		if recovered == "in goroutine" {
+			close(done)
+		}
+	})
+	Go(func() { panic("in goroutine") })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("panic in goroutine not recovered")
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	ch, cancel := Subscribe(zapcore.ErrorLevel)
+	defer cancel()
+	NewLogger(WithLogFileDir(t.TempDir()))
+
+	h := Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+	req := httptest.NewRequest("POST", "/orders", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	select {
+	case e := <-ch:
+		if e.Fields["path"] != "/orders" || e.Fields["request_id"] != "req-1" {
+			t.Fatalf("unexpected entry: %+v", e)
+		}
+		if id := rec.Header().Get("X-Incident-ID"); id == "" || e.Fields["incident_id"] != id {
+			t.Fatalf("incident id %q, entry %+v", id, e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic not logged")
+	}
+}
+
+func TestCatchExceptionRepanic(t *testing.T) {
+	defer func() {
+		if err := recover(); err != "again" {
+			t.Fatalf("recovered %v, want re-raised panic", err)
+		}
+	}()
+	defer CatchExceptionRepanic()
+	panic("again")
+}
+
+func TestCleanDumps(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-72 * time.Hour)
+	for i, name := range []string{"2020-01-01/exceptions.10_00_00.log", "2020-01-02/exceptions.10_00_00.json", "2020-01-03/exceptions.10_00_00.log", "2020-01-03/exceptions.10_00_00_1.log"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		os.WriteFile(path, nil, 0644)
+		if i == 0 {
+			os.Chtimes(path, old, old)
+		} else {
+			mt := time.Now().Add(time.Duration(i) * time.Minute)
+			os.Chtimes(path, mt, mt)
+		}
+	}
+
+	for _, name := range []string{"app.log", "app-error.log", "notes/readme.json"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.ModePerm)
+		os.WriteFile(filepath.Join(dir, name), nil, 0644)
+		os.Chtimes(filepath.Join(dir, name), old, old)
+	}
+
+	cleanDumps(dir, "exceptions.15_04_05", 48*time.Hour, 2)
+
+	for name, exists := range map[string]bool{
+		"2020-01-01": false, "2020-01-02": false,
+		"2020-01-03/exceptions.10_00_00.log": true, "2020-01-03/exceptions.10_00_00_1.log": true,
+		"app.log": true, "app-error.log": true, "notes/readme.json": true,
+	} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != exists {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, exists)
+		}
+	}
+}
+
+func TestDumpDir(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithDumpDir(filepath.Join(dir, "crash")), WithDumpNaming("crash-150405", false))
+	func() {
+		defer CatchException()
+		panic("dumped")
+	}()
+	matches, _ := filepath.Glob(filepath.Join(dir, "crash", "crash-*.log"))
+	if len(matches) != 1 {
+		t.Fatalf("dump files = %v", matches)
+	}
+}
+
+func TestFromContextPprofLabels(t *testing.T) {
+	NewLogger(WithLogFileDir(t.TempDir()), WithPprofLabels("route"))
+	ch, cancel := Subscribe(zapcore.InfoLevel)
+	defer cancel()
+
+	pprof.Do(context.Background(), pprof.Labels("route", "/orders", "other", "x"), func(ctx context.Context) {
+		FromContext(ctx).Info("handled")
+	})
+	select {
+	case e := <-ch:
+		if e.Fields["route"] != "/orders" || e.Fields["other"] != nil {
+			t.Fatalf("unexpected fields: %+v", e.Fields)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("entry not received")
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                         { return time.Time(c) }
+func (c fixedClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+
+func TestWithClock(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithClock(fixedClock(time.Date(2001, 2, 3, 4, 5, 6, 0, time.Local))))
+	lg.Info("at fixed time")
+	lg.Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"ts":"2001-02-03 04:05:06.000"`) {
+		t.Fatalf("clock not used: %s", data)
+	}
+}
+
+func TestWithClockRotation(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.Local)
+	// 按时钟未超过 MaxAge 的备份保留，超过的清理
+	kept := filepath.Join(dir, "app-"+at.AddDate(0, 0, -10).Format(backupTimeFormat)+".log")
+	expired := filepath.Join(dir, "app-"+at.AddDate(0, 0, -40).Format(backupTimeFormat)+".log")
+	os.WriteFile(kept, nil, 0644)
+	os.WriteFile(expired, nil, 0644)
+
+	NewLogger(WithLogFileDir(dir), WithMaxSize(1), WithClock(fixedClock(at)))
+	Info(strings.Repeat("x", 600*1024))
+	Info(strings.Repeat("y", 600*1024))
+	if err := Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	Sync()
+
+	if _, err := os.Stat(kept); err != nil {
+		t.Fatalf("backup within MaxAge removed: %v", err)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Fatal("backup past MaxAge not removed")
+	}
+	for _, name := range []string{"app-2001-02-03T04-05-06.000.log", "app-2001-02-03T04-05-06.001.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("backup not named by clock: %v", err)
+		}
+	}
+	if got := Stats().Rotations; got != 1 {
+		t.Fatalf("rotations %d, want 1", got)
+	}
+
+	NewLogger(WithLogFileDir(t.TempDir()), WithClock(nil), WithLowLatencyWriter(1))
+	Info("nil clock")
+	Sync()
+}
+
+func TestEnabledAndCheck(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"))
+	if Enabled(zapcore.DebugLevel) || !Enabled(zapcore.InfoLevel) {
+		t.Fatal("Enabled does not follow the logger level")
+	}
+	if ce := Check(zapcore.DebugLevel, "skipped"); ce != nil {
+		t.Fatal("Check returned an entry for a disabled level")
+	}
+	if ce := Check(zapcore.InfoLevel, "checked"); ce != nil {
+		ce.Write(zap.Int("n", 1))
+	}
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `log_test.go:`) || !strings.Contains(string(data), `"msg":"checked","n":1`) {
+		t.Fatalf("unexpected log: %s", data)
+	}
+
+	// 订阅者与命名 logger 的等级不影响全局 logger 的判断
+	_, cancel := Subscribe(zapcore.DebugLevel)
+	defer cancel()
+	SetLoggerLevel("db", "debug")
+	defer ResetLoggerLevel("db")
+	if Enabled(zapcore.DebugLevel) {
+		t.Fatal("Enabled widened by side channels")
+	}
+}
+
+func TestLazy(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithLevel("info"), WithConsole(true), WithConsoleEncoding("json"))
+	calls := 0
+	expensive := func() interface{} {
+		calls++
+		return map[string]int{"rows": 42}
+	}
+	lg.Debug("skipped", Lazy("stats", expensive))
+	if calls != 0 {
+		t.Fatal("lazy field evaluated for a disabled level")
+	}
+	lg.Info("written", Lazy("stats", expensive))
+	lg.Sync()
+	if calls != 1 {
+		t.Fatalf("lazy field evaluated %d times", calls)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"stats":{"rows":42}`) {
+		t.Fatalf("unexpected log: %s", data)
+	}
+}
+
+func TestErrorE(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	err := fmt.Errorf("load order: %w", &os.PathError{Op: "open", Path: "/data/o1", Err: os.ErrNotExist})
+	ErrorE("request failed", err, zap.String("order", "o1"))
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"error":"load order: open /data/o1: file does not exist"`,
+		`"error_type":"*fmt.wrapError"`,
+		`"cause":"file does not exist"`,
+		`"error_chain":["*fmt.wrapError","*fs.PathError","*errors.errorString"]`,
+		`"order":"o1"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+
+	// 组合错误沿第一个错误继续展开
+	enc := zapcore.NewMapObjectEncoder()
+	joined := fmt.Errorf("sync: %w", joinedErrors{&os.PathError{Op: "open", Path: "/a", Err: os.ErrNotExist}, errors.New("b")})
+	for _, f := range errorChainFields(joined) {
+		f.AddTo(enc)
+	}
+	if enc.Fields["cause"] != "file does not exist" ||
+		fmt.Sprint(enc.Fields["error_chain"]) != "[*fmt.wrapError log.joinedErrors *fs.PathError *errors.errorString]" {
+		t.Fatalf("joined chain %v", enc.Fields)
+	}
+}
+
+type joinedErrors []error
+
+func (e joinedErrors) Error() string   { return "joined" }
+func (e joinedErrors) Unwrap() []error { return e }
+
+func TestMultiError(t *testing.T) {
+	err := joinedErrors{errors.New("a"), joinedErrors{errors.New("b"), errors.New("c")}}
+	enc := zapcore.NewMapObjectEncoder()
+	MultiError("errors", err).AddTo(enc)
+	if got := fmt.Sprint(enc.Fields["errors"]); got != "[a b c]" {
+		t.Fatalf("errors = %s", got)
+	}
+
+	wrapped := fmt.Errorf("sync: %w", joinedErrors{errors.New("a"), fmt.Errorf("retry: %w", errors.New("b"))})
+	MultiError("wrapped", wrapped).AddTo(enc)
+	if got := fmt.Sprint(enc.Fields["wrapped"]); got != "[a retry: b]" {
+		t.Fatalf("wrapped = %s", got)
+	}
+}
+
+type stackFrames []string
+
+func (s stackFrames) Format(f fmt.State, verb rune) {
+	for _, fr := range s {
+		fmt.Fprintf(f, "\n%s", fr)
+	}
+}
+
+type stackError struct{ frames stackFrames }
+
+func (e stackError) Error() string           { return "boom" }
+func (e stackError) StackTrace() stackFrames { return e.frames }
+
+func TestErrorStack(t *testing.T) {
+	err := fmt.Errorf("wrap: %w", stackError{stackFrames{"main.a\n\t/src/a.go:1", "main.b\n\t/src/b.go:2"}})
+	f := ErrorStack(err)
+	if f.Key != "stacktrace" || f.String != "main.a\n\t/src/a.go:1\nmain.b\n\t/src/b.go:2" {
+		t.Fatalf("field = %q %q", f.Key, f.String)
+	}
+	if ErrorStack(errors.New("plain")).Type != zapcore.SkipType {
+		t.Fatal("plain error should produce no field")
+	}
+}
+
+func TestFieldBuilder(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"))
+	F().Str("user", "u1").Int("count", 3).Dur("took", 1500*time.Millisecond).Msg("done")
+	F().Str("user", "u2").Debug("skipped")
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{`"msg":"done"`, `"user":"u1"`, `"count":3`, `"took":1.5`, `log_test.go:`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "skipped") {
+		t.Fatalf("debug entry written at info level: %s", data)
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithClaims(func(r *http.Request) []zap.Field {
+		return []zap.Field{zap.String("user", r.Header.Get("X-User"))}
+	}))
+	h := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("loading order")
+	}))
+	req := httptest.NewRequest("GET", "/orders/1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-User", "alice")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{`"msg":"loading order"`, `"request_id":"req-1"`, `"route":"/orders/1"`, `"user":"alice"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestDebugOnDemand(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"), WithDebugOnDemand(DebugHeader("X-Debug", "secret"), DebugTraceSampled()))
+	h := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Debug("cache miss", zap.String("path", r.URL.Path))
+	}))
+	for path, header := range map[string][2]string{
+		"/plain":     {},
+		"/wrong":     {"X-Debug", "guess"},
+		"/token":     {"X-Debug", "secret"},
+		"/sampled":   {"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"/unsampled": {"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		if header[0] != "" {
+			req.Header.Set(header[0], header[1])
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	Debug("background debug")
+	FromContext(ForceDebug(context.Background())).Debug("job debug")
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{`"path":"/token"`, `"path":"/sampled"`, `"msg":"job debug"`, `"debug_on_demand":true`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	for _, unwanted := range []string{`"path":"/plain"`, `"path":"/wrong"`, `"path":"/unsampled"`, "background debug"} {
+		if strings.Contains(string(data), unwanted) {
+			t.Fatalf("unexpected %s in %s", unwanted, data)
+		}
+	}
+}
+
+func TestLevelGateBeforeAsync(t *testing.T) {
+	ws := newBlockingSyncer()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithLevel("info"),
+		WithDebugOnDemand(), WithAsync(4, BackpressureDropNewest))
+	Sync()
+	atomic.StoreInt32(&ws.block, 1)
+	Info("first")
+	<-ws.entered
+	for i := 0; i < 10; i++ {
+		Debug("noise")
+	}
+	Info("important")
+	close(ws.release)
+	Sync()
+
+	if out := ws.String(); !strings.Contains(out, "important") || strings.Contains(out, "noise") || Dropped() != 0 {
+		t.Fatalf("unexpected output (dropped %d): %s", Dropped(), out)
+	}
+}
+
+func TestDebugOnDemandKeepsLevel(t *testing.T) {
+	NewLogger(WithLogFileDir(t.TempDir()), WithLevel("info"), WithDebugOnDemand())
+	if Enabled(zapcore.DebugLevel) || current().Check(zapcore.DebugLevel, "noise") != nil {
+		t.Fatal("debug enabled for loggers without the debug marker")
+	}
+	if ce := FromContext(ForceDebug(context.Background())).Check(TraceLevel, "trace"); ce == nil {
+		t.Fatal("trace disabled for a debug-on-demand logger")
+	}
+}
+
+func TestDebugField(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"), WithFieldLimits(FieldLimits{MaxString: 100}))
+	defer ResetLoggerLevel("cart")
+	calls := 0
+	dump := func() interface{} {
+		calls++
+		return map[string]int{"items": 3}
+	}
+	Info("order placed", DebugField("cart_dump", dump))
+	current().With(DebugField("session", dump)).Info("with field")
+	SetLoggerLevel("cart", "debug")
+	Named("cart").Info("cart placed", DebugField("cart_dump", dump))
+	Named("cart").With(DebugField("session", dump)).Info("cart with field")
+	FromContext(ForceDebug(context.Background())).Info("forced", DebugField("cart_dump", dump))
+	Sync()
+
+	if calls != 3 {
+		t.Fatalf("debug field evaluated %d times", calls)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{`"msg":"cart placed","cart_dump":{"items":3}`, `"msg":"cart with field","session":{"items":3}`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if n := strings.Count(string(data), `{"items":3}`); n != 3 {
+		t.Fatalf("%d debug fields in %s", n, data)
+	}
+}
+
+func TestDebugFieldProcessed(t *testing.T) {
+	ws := newBlockingSyncer()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithAsync(8, BackpressureBlock),
+		WithScrubbers(ScrubEmail), WithFieldLimits(FieldLimits{MaxString: 8}))
+	Sync()
+	atomic.StoreInt32(&ws.block, 1)
+	Info("first")
+	<-ws.entered
+	user := "alice@example.com"
+	Info("who", DebugField("who", func() interface{} { return user }), DebugField("note", func() interface{} { return "abcdefghij" }))
+	user = "bob@example.com"
+	close(ws.release)
+	Sync()
+
+	if out := ws.String(); !strings.Contains(out, `"msg":"who","who":"[EMAIL]","note":"abcdefgh..."`) {
+		t.Fatalf("debug fields not processed: %s", out)
+	}
+}
+
+func TestDebugCapture(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"), WithDebugCapture("capture.log", "user_id", "tenant_id"))
+	AddDebugCapture("alice", "42")
+	defer RemoveDebugCapture("alice", "42")
+	if ids := DebugCaptureIDs(); len(ids) != 2 || ids[0] != "42" {
+		t.Fatalf("ids %v", ids)
+	}
+
+	current().With(zap.String("user_id", "alice")).Debug("alice debug")
+	Debug("tenant debug", zap.Int("tenant_id", 42))
+	Debug("bob debug", zap.String("user_id", "bob"))
+	current().With(zap.String("user_id", "alice")).Info("alice info")
+	RemoveDebugCapture("alice")
+	current().With(zap.String("user_id", "alice")).Debug("after removal")
+	Sync()
+
+	captured, _ := os.ReadFile(filepath.Join(dir, "app-capture.log"))
+	main, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{"alice debug", "tenant debug", "alice info"} {
+		if !strings.Contains(string(captured), want) {
+			t.Fatalf("missing %s in capture %s", want, captured)
+		}
+	}
+	if strings.Contains(string(captured), "bob debug") || strings.Contains(string(captured), "after removal") {
+		t.Fatalf("unexpected capture %s", captured)
+	}
+	if strings.Contains(string(main), "debug\"") || !strings.Contains(string(main), "alice info") {
+		t.Fatalf("main log %s", main)
+	}
+
+	// 采集文件的切割不计入普通日志文件的切割次数
+	NewLogger(WithLogFileDir(t.TempDir()), WithLevel("info"), WithMaxSize(1), WithDebugCapture("capture.log", "user_id"))
+	AddDebugCapture("carol")
+	defer RemoveDebugCapture("carol")
+	Debug(strings.Repeat("x", 600*1024), zap.String("user_id", "carol"))
+	Debug(strings.Repeat("y", 600*1024), zap.String("user_id", "carol"))
+	Sync()
+	if got := Stats().Rotations; got != 0 {
+		t.Fatalf("capture rotations counted: %d", got)
+	}
+}
+
+func TestStormGuard(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithStormGuard(StormGuard{Limit: 5, Interval: 20 * time.Millisecond, Level: zapcore.WarnLevel}))
+	written := 0
+	for deadline := time.Now().Add(200 * time.Millisecond); time.Now().Before(deadline); written++ {
+		Info("noisy")
+		time.Sleep(100 * time.Microsecond)
+	}
+	Warn("still important")
+	time.Sleep(100 * time.Millisecond)
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{"log storm detected", "log storm suppressed", `"suppressed_total"`, "log storm ended", "still important"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if n := strings.Count(string(data), `"msg":"noisy"`); n >= written {
+		t.Fatalf("%d of %d noisy entries written", n, written)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithBudget("ingest", Budget{Entries: 3, Sample: 5}), WithBudget("db", Budget{Bytes: 400}))
+	ingest := Named("ingest.kafka")
+	for i := 0; i < 13; i++ {
+		ingest.Info("consumed", zap.Int("n", i))
+	}
+	ingest.Error("consumer failed")
+	db := Named("db")
+	for i := 0; i < 10; i++ {
+		db.Info("query")
+	}
+	Info("unbudgeted")
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	// 3 条在预算内，其后 10 条中采样保留第 5、10 条
+	if n := strings.Count(string(data), `"msg":"consumed"`); n != 5 {
+		t.Fatalf("%d ingest entries written: %s", n, data)
+	}
+	if n := strings.Count(string(data), `"msg":"query"`); n == 0 || n == 10 {
+		t.Fatalf("%d db entries written", n)
+	}
+	for _, want := range []string{"consumer failed", "unbudgeted", `"budget":"ingest"`, `"budget":"db"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	stats := Stats().Budgets
+	if c := stats["ingest"]; c.Dropped != 8 || c.Sampled != 2 || c.Exceeded != 1 {
+		t.Fatalf("ingest counters %+v", c)
+	}
+}
+
+func TestShadow(t *testing.T) {
+	var same, renamed bytes.Buffer
+	NewLogger(WithLogFileDir(t.TempDir()), WithShadow(zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig), zapcore.AddSync(&same), nil))
+	Info("order placed", zap.Int("id", 1))
+	Sync()
+	if c := Stats().Shadow; c.Entries == 0 || c.Diffs != 0 || !strings.Contains(same.String(), `"msg":"order placed"`) {
+		t.Fatalf("same schema: %+v %s", c, same.String())
+	}
+
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.MessageKey = "message"
+	NewLogger(WithLogFileDir(t.TempDir()), WithShadow(zapcore.NewJSONEncoder(cfg), zapcore.AddSync(&renamed), nil))
+	Info("order placed", zap.Int("id", 1))
+	Sync()
+	c := Stats().Shadow
+	if c.Diffs == 0 || c.Diffs != c.Entries || !strings.Contains(c.LastDiff, `"message":"order placed"`) || !strings.Contains(c.LastSource, `"msg":"order placed"`) {
+		t.Fatalf("renamed schema: %+v", c)
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	defer ResetLoggerLevel("db")
+	srv := httptest.NewServer(http.StripPrefix("/debug/log", AdminHandler()))
+	defer srv.Close()
+	do := func(method, path string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+"/debug/log"+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := do("PUT", "/level?level=warn"); code != 200 || !strings.Contains(body, `"level": "warn"`) {
+		t.Fatalf("set level: %d %s", code, body)
+	}
+	if code, body := do("PUT", "/level?logger=db&level=debug"); code != 200 || !strings.Contains(body, `"db": "debug"`) {
+		t.Fatalf("set logger level: %d %s", code, body)
+	}
+	if code, _ := do("PUT", "/level?level=loud"); code != http.StatusBadRequest {
+		t.Fatalf("invalid level: %d", code)
+	}
+	if code, body := do("DELETE", "/level?logger=db"); code != 200 || !strings.Contains(body, `"db": "warn"`) {
+		t.Fatalf("reset logger level: %d %s", code, body)
+	}
+	Warn("before rotate")
+	if code, _ := do("POST", "/rotate"); code != http.StatusNoContent {
+		t.Fatalf("rotate: %d", code)
+	}
+	if backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log")); len(backups) != 1 {
+		t.Fatalf("backups %v", backups)
+	}
+	if code, body := do("GET", "/stats"); code != 200 || !strings.Contains(body, `"Entries"`) {
+		t.Fatalf("stats: %d %s", code, body)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	if id := NewUUID(); !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Fatalf("bad uuid %s", id)
+	}
+	clock := fixedClock(time.Unix(1700000000, 0))
+	NewLogger(WithLogFileDir(t.TempDir()), WithClock(clock), WithCorrelationID(NewULID))
+	if id := NewULID(); len(id) != 26 || !strings.HasPrefix(id, "01HF7YAT00") {
+		t.Fatalf("bad ulid %s", id)
+	}
+
+	var seen string
+	h := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Request-ID")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if seen == "" || rec.Header().Get("X-Request-ID") != seen {
+		t.Fatalf("request id %q, response header %q", seen, rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestWatchContext(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	ctx, cancel := context.WithCancel(NewContext(context.Background(), current().With(zap.String("request_id", "r-1"))))
+	WatchContext(ctx, "export aborted", zap.String("job", "j-1"))
+	cancel()
+
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelDeadline()
+	WatchContext(deadline, "query timed out")
+
+	finished, cancelFinished := context.WithCancel(context.Background())
+	stop := WatchContext(finished, "should not log")
+	stop()
+	stop()
+	cancelFinished()
+
+	time.Sleep(50 * time.Millisecond)
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"msg":"export aborted","request_id":"r-1","job":"j-1","reason":"canceled","cause":"context canceled","elapsed":`,
+		`"msg":"query timed out","reason":"deadline_exceeded","cause":"context deadline exceeded"`,
+		`log_test.go:`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "should not log") {
+		t.Fatalf("stopped watch logged: %s", data)
+	}
+}
+
+type spanKey struct{}
+
+func TestSpanEvents(t *testing.T) {
+	events := map[string][]string{}
+	NewLogger(WithLogFileDir(t.TempDir()), WithRedactKeys("card"), WithSpanEvents(zapcore.WarnLevel, func(ctx context.Context, e Entry) {
+		span := ctx.Value(spanKey{}).(string)
+		events[span] = append(events[span], fmt.Sprintf("%s %v %v", e.Message, e.Fields["card"], e.Fields["order"]))
+	}))
+	ctx := context.WithValue(context.Background(), spanKey{}, "span-1")
+	logger := FromContext(ctx).With(zap.String("order", "o-1"))
+	logger.Info("routine")
+	logger.Info("selected", SpanEvent())
+	logger.Warn("declined", zap.String("card", "4111"))
+	Warn("no span")
+
+	want := []string{"selected <nil> o-1", "declined [REDACTED] o-1"}
+	if got := events["span-1"]; len(events) != 1 || len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("span events %v", events)
+	}
+}
+
+type baggageKey string
+
+func TestFromContextBaggage(t *testing.T) {
+	NewLogger(WithLogFileDir(t.TempDir()), WithBaggage(func(ctx context.Context, key string) string {
+		v, _ := ctx.Value(baggageKey(key)).(string)
+		return v
+	}, "tenant", "region"))
+	ch, cancel := Subscribe(zapcore.InfoLevel)
+	defer cancel()
+
+	ctx := context.WithValue(context.Background(), baggageKey("tenant"), "acme")
+	FromContext(ctx).Info("handled")
+	select {
+	case e := <-ch:
+		if e.Fields["tenant"] != "acme" || e.Fields["region"] != nil {
+			t.Fatalf("unexpected fields: %+v", e.Fields)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("entry not received")
+	}
+}
+
+func TestPushField(t *testing.T) {
+	NewLogger(WithLogFileDir(t.TempDir()))
+	ch, cancel := Subscribe(zapcore.InfoLevel)
+	defer cancel()
+	next := func() Entry {
+		select {
+		case e := <-ch:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("entry not received")
+		}
+		return Entry{}
+	}
+
+	PushField(zap.String("job", "import"))
+	ScopeFields(func() {
+		Info("step")
+	}, zap.Int("batch", 7))
+	done := make(chan struct{})
+	go func() {
+		Info("other goroutine")
+		close(done)
+	}()
+	<-done
+	if e := next(); e.Fields["job"] != "import" || e.Fields["batch"] != int64(7) {
+		t.Fatalf("unexpected fields: %+v", e.Fields)
+	}
+	if e := next(); e.Fields["job"] != nil {
+		t.Fatalf("fields leaked to other goroutine: %+v", e.Fields)
+	}
+	PopField()
+	Info("after pop")
+	if e := next(); e.Fields["job"] != nil {
+		t.Fatalf("fields not popped: %+v", e.Fields)
+	}
+}
+
+func TestTenant(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithTenants("tenants", 1, 2))
+	Tenant("acme").Info("tenant entry")
+	Tenant("../evil").Info("other tenant")
+	Info("global entry")
+	Sync()
+
+	files := TenantFiles("acme")
+	if len(files) != 1 {
+		t.Fatalf("tenant files = %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), `"msg":"tenant entry","tenant":"acme"`) || strings.Contains(string(data), "global entry") {
+		t.Fatalf("unexpected tenant log: %s", data)
+	}
+	main, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(main), "tenant entry") {
+		t.Fatalf("tenant entry missing from main log: %s", main)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tenants", ".._evil.log")); err != nil {
+		t.Fatalf("tenant id not sanitized: %v", err)
+	}
+
+	if err := RemoveTenant("acme"); err != nil {
+		t.Fatal(err)
+	}
+	if files := TenantFiles("acme"); len(files) != 0 {
+		t.Fatalf("tenant files not removed: %v", files)
+	}
+}
+
+// stepClock 每次调用 Now 前进 1ms
+type stepClock struct{ n int64 }
+
+func (c *stepClock) Now() time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local).Add(time.Duration(atomic.AddInt64(&c.n, 1)) * time.Millisecond)
+}
+func (c *stepClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+
+func TestShards(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithShards(3), WithClock(&stepClock{}))
+	for i := 0; i < 9; i++ {
+		Info(fmt.Sprint("msg-", i), zap.Bool("seq", true))
+	}
+	Sync()
+
+	for i := 0; i < 3; i++ {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("app-%d.log", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	err := Search(Query{Fields: map[string]interface{}{"seq": true}}, func(e Entry) bool {
+		got = append(got, e.Message)
+		return len(got) < 5
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "msg-0,msg-1,msg-2,msg-3,msg-4" {
+		t.Fatalf("unexpected order: %v", got)
+	}
+}
+
+func BenchmarkShards(b *testing.B) {
+	for _, n := range []int{1, 4, 8} {
+		b.Run(fmt.Sprint("shards-", n), func(b *testing.B) {
+			lg := NewLogger(WithLogFileDir(b.TempDir()), WithShards(n), WithLevel("info"))
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					lg.Info("benchmark entry", zap.Int("n", 42), zap.String("user", "u1"))
+				}
+			})
+		})
+	}
+}
+
+// tickClock 中周期为 d 的 ticker 只在测试向 ticks 发送时触发
+type tickClock struct {
+	d     time.Duration
+	ticks chan time.Time
+}
+
+func newTickClock(d time.Duration) tickClock { return tickClock{d: d, ticks: make(chan time.Time)} }
+
+func (c tickClock) Now() time.Time { return time.Now() }
+func (c tickClock) NewTicker(d time.Duration) *time.Ticker {
+	if d != c.d {
+		return time.NewTicker(d)
+	}
+	return &time.Ticker{C: c.ticks}
+}
+
+func TestLowLatencyWriter(t *testing.T) {
+	dir := t.TempDir()
+	clock := newTickClock(lowLatencyFlush)
+	NewLogger(WithLogFileDir(dir), WithLowLatencyWriter(1), WithClock(clock))
+	path := filepath.Join(dir, "app.log")
+
+	Info("buffered")
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "buffered") {
+		t.Fatalf("entry written before flush: %s", data)
+	}
+	// 第二次发送在第一次 flush 完成后才会被接收
+	clock.ticks <- time.Now()
+	clock.ticks <- time.Now()
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"msg":"buffered"`) {
+		t.Fatalf("entry not flushed by ticker: %s", data)
+	}
+
+	Info("synced")
+	Sync()
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"msg":"synced"`) {
+		t.Fatalf("entry not flushed by Sync: %s", data)
+	}
+	fi, _ := os.Stat(path)
+	if fi.Size() != int64(len(data)) {
+		t.Fatalf("preallocation changed file size: %d != %d", fi.Size(), len(data))
+	}
+}
+
+func TestGroupCommit(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithGroupCommit(50*time.Millisecond), WithAudit("audit.log"))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Info("committed", zap.Int("n", i), Durable())
+		}(i)
+	}
+	wg.Wait()
+	if time.Since(start) > time.Second {
+		t.Fatal("durable entries were not committed as a group")
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if strings.Count(string(data), `"msg":"committed"`) != 10 || strings.Contains(string(data), durableKey) {
+		t.Fatalf("unexpected log: %s", data)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		Audit("login", zap.String("actor", "u1"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("audit entry not acknowledged")
+	}
+	Info("not durable")
+}
+
+// countingSyncer 记录 Sync 调用次数
+type countingSyncer struct {
+	bytes.Buffer
+	syncs int32
+}
+
+func (s *countingSyncer) Sync() error {
+	atomic.AddInt32(&s.syncs, 1)
+	return nil
+}
+
+func TestSyncPolicy(t *testing.T) {
+	ws := &countingSyncer{}
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithSyncPolicy(SyncPolicy{Entries: 3, OnError: true}))
+	base := atomic.LoadInt32(&ws.syncs) // NewLogger 初始化完成时会 Sync 一次
+	Info("one")
+	Info("two")
+	if n := atomic.LoadInt32(&ws.syncs) - base; n != 1 {
+		t.Fatalf("syncs after 3 entries = %d", n)
+	}
+	Error("failed")
+	if n := atomic.LoadInt32(&ws.syncs) - base; n != 2 {
+		t.Fatalf("syncs after error = %d", n)
+	}
+
+	ws = &countingSyncer{}
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithSyncPolicy(SyncEveryInterval(10*time.Millisecond)))
+	base = atomic.LoadInt32(&ws.syncs)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&ws.syncs) == base {
+		t.Fatal("interval policy did not sync")
+	}
+}
+
+// blockingSyncer 在 block 后的第一次写入时阻塞，直到 release 关闭
+type blockingSyncer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	block   int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingSyncer() *blockingSyncer {
+	return &blockingSyncer{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (s *blockingSyncer) Write(p []byte) (int, error) {
+	if atomic.CompareAndSwapInt32(&s.block, 1, 0) {
+		close(s.entered)
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *blockingSyncer) Sync() error { return nil }
+
+func (s *blockingSyncer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func TestAsyncBackpressure(t *testing.T) {
+	ws := newBlockingSyncer()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithAsync(2, BackpressureDropLowest))
+	Sync()
+	atomic.StoreInt32(&ws.block, 1)
+	Info("first")
+	<-ws.entered
+	Info("a")
+	Info("b")
+	Warn("c")
+	Info("d")
+	close(ws.release)
+	Sync()
+
+	out := ws.String()
+	for _, msg := range []string{"first", "b", "c"} {
+		if !strings.Contains(out, `"msg":"`+msg+`"`) {
+			t.Fatalf("missing %s in %s", msg, out)
+		}
+	}
+	for _, msg := range []string{"a", "d"} {
+		if strings.Contains(out, `"msg":"`+msg+`"`) {
+			t.Fatalf("%s not dropped: %s", msg, out)
+		}
+	}
+	if Dropped() != 2 {
+		t.Fatalf("dropped = %d", Dropped())
+	}
+
+	ws = newBlockingSyncer()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithAsync(1, BackpressureDropNewest))
+	Sync()
+	atomic.StoreInt32(&ws.block, 1)
+	Info("first")
+	<-ws.entered
+	Info("kept")
+	Error("newest")
+	close(ws.release)
+	Sync()
+	if out := ws.String(); !strings.Contains(out, "kept") || strings.Contains(out, "newest") || Dropped() != 1 {
+		t.Fatalf("unexpected output (dropped %d): %s", Dropped(), out)
+	}
+}
+
+func TestAsyncCopiesFields(t *testing.T) {
+	ws := newBlockingSyncer()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithAsync(8, BackpressureBlock))
+	Sync()
+	atomic.StoreInt32(&ws.block, 1)
+	Info("first")
+	<-ws.entered
+	F().Str("user", "u1").Int("count", 2).Msg("pooled")
+	tags := []string{"a", "b"}
+	payload := []byte("raw")
+	m := map[string]int{"n": 1}
+	Info("mutated", zap.Strings("tags", tags), zap.ByteString("payload", payload), zap.Any("m", m), zap.Duration("d", time.Second))
+	tags[0], payload[0], m["n"] = "x", 'X', 2
+	close(ws.release)
+	Sync()
+
+	out := ws.String()
+	for _, want := range []string{
+		`"msg":"pooled","user":"u1","count":2`,
+		`"msg":"mutated","tags":["a","b"],"payload":"raw","m":{"n":1},"d":1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in %s", want, out)
+		}
+	}
+}
+
+func TestSyncContext(t *testing.T) {
+	ws := newBlockingSyncer()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(ws), WithAsync(8, BackpressureBlock))
+	Sync()
+	atomic.StoreInt32(&ws.block, 1)
+	Info("stuck")
+	<-ws.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := SyncContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v", err)
+	}
+	close(ws.release)
+	if err := SyncContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ws.String(), "stuck") {
+		t.Fatal("entry not flushed")
+	}
+}
+
+func TestOnFatal(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithOnFatal(zapcore.WriteThenGoexit))
+	var flushed bool
+	OnFatal(func() {
+		data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+		flushed = strings.Contains(string(data), `"msg":"fatal error"`)
+	})
+	defer func() {
+		fatalHooksMu.Lock()
+		fatalHooks = nil
+		fatalHooksMu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lg.Fatal("fatal error")
+	}()
+	<-done
+	if !flushed {
+		t.Fatal("hook not run after the fatal entry was written")
+	}
+}
+
+func TestWithDPanic(t *testing.T) {
+	dpanics := func(lg *zap.Logger) (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		lg.DPanic("invariant violated")
+		return false
+	}
+	if !dpanics(NewLogger(WithLogFileDir(t.TempDir()), WithDPanic(true))) {
+		t.Fatal("DPanic did not panic")
+	}
+	if dpanics(NewLogger(WithLogFileDir(t.TempDir()), WithDevelopment(true), WithDPanic(false))) {
+		t.Fatal("DPanic panicked in development with WithDPanic(false)")
+	}
+	if dpanics(NewLogger(WithLogFileDir(t.TempDir()), WithDevelopment(true))) {
+		t.Fatal("DPanic panicked in development without WithDPanic")
+	}
+}
+
+func TestTraceAndNotice(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("trace"))
+	Trace("wire dump", zap.String("frame", "0a0b"))
+	Notice("quota nearly reached")
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{`"level":"trace"`, `"msg":"wire dump"`, `"msg":"quota nearly reached","notice":true`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	var levels []zapcore.Level
+	Search(Query{Level: "trace"}, func(e Entry) bool {
+		levels = append(levels, e.Level)
+		return true
+	})
+	if len(levels) != 3 || levels[1] != TraceLevel {
+		t.Fatalf("decoded levels = %v", levels)
+	}
+
+	dir = t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("debug"))
+	Trace("suppressed")
+	Sync()
+	if data, _ := os.ReadFile(filepath.Join(dir, "app.log")); strings.Contains(string(data), "suppressed") {
+		t.Fatalf("trace written at debug level: %s", data)
+	}
+}
+
+func TestLevelLabelsAndColors(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.CreateTemp(dir, "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(ws zapcore.WriteSyncer) { consoleWs = ws }(consoleWs)
+	consoleWs = out
+
+	lg := NewLogger(WithLogFileDir(dir), WithConsole(true), WithColor(ColorAlways),
+		WithLevelLabels(map[zapcore.Level]string{zapcore.WarnLevel: "警告"}),
+		WithLevelColors(map[zapcore.Level]string{zapcore.WarnLevel: "\x1b[33;40m"}))
+	lg.Warn("disk almost full")
+	lg.Info("plain")
+	lg.Sync()
+
+	data, _ := os.ReadFile(out.Name())
+	if !strings.Contains(string(data), "\x1b[33;40m警告\x1b[0m") || !strings.Contains(string(data), "\x1b[34mINFO\x1b[0m") {
+		t.Fatalf("unexpected console output: %q", data)
+	}
+}
+
+func TestWithLineEnding(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("secret")
+	NewLogger(WithLogFileDir(dir), WithLineEnding("\r\n"), WithHMACChain(key))
+	Info("first")
+	Info("second")
+	Sync()
+
+	path := filepath.Join(dir, "app.log")
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\r\n") != 3 || strings.Count(string(data), "\n") != 3 {
+		t.Fatalf("unexpected line endings: %q", data)
+	}
+	if err := Verify(key, path); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	Search(Query{}, func(e Entry) bool {
+		got = append(got, e.Message)
+		return true
+	})
+	if len(got) != 3 || got[2] != "second" {
+		t.Fatalf("search = %v", got)
+	}
+}
+
+func TestWithMessageTemplate(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(WithLogFileDir(dir), WithAppName("gw"), WithMessageTemplate("[{app}:{env}] {msg} {missing}"))
+	lg.With(zap.String("env", "prod")).Info("started", zap.Int("port", 80))
+	lg.Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "gw.log"))
+	if !strings.Contains(string(data), `"msg":"[gw:prod] started ","env":"prod","port":80`) {
+		t.Fatalf("unexpected log: %s", data)
+	}
+
+	NewLogger(WithLogFileDir(dir), WithLevel("trace"), WithMessageTemplate("{level}: {msg}"))
+	Trace("probe")
+	Sync()
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"msg":"trace: probe"`) {
+		t.Fatalf("unexpected trace level: %s", data)
+	}
+}
+
+func TestCode(t *testing.T) {
+	RegisterCode("E1001", CodeInfo{Description: "payment gateway timeout", Remediation: "retry after 30s", DocURL: "https://docs.example.com/E1001"})
+	enc := zapcore.NewMapObjectEncoder()
+	Code("E1001").AddTo(enc)
+	want := map[string]interface{}{
+		"code":             "E1001",
+		"code_description": "payment gateway timeout",
+		"code_remediation": "retry after 30s",
+		"code_doc":         "https://docs.example.com/E1001",
+	}
+	if fmt.Sprint(enc.Fields) != fmt.Sprint(want) {
+		t.Fatalf("fields = %v", enc.Fields)
+	}
+
+	enc = zapcore.NewMapObjectEncoder()
+	Code("E9999").AddTo(enc)
+	if len(enc.Fields) != 1 || enc.Fields["code"] != "E9999" {
+		t.Fatalf("unregistered code fields = %v", enc.Fields)
+	}
+}
+
+func TestWithRequiredFields(t *testing.T) {
+	dir := t.TempDir()
+	service := func() []zap.Field { return []zap.Field{zap.String("service", "gw")} }
+	lg := NewLogger(WithLogFileDir(dir), WithFieldProvider(service), WithRequiredFields(RequiredReject, "service", "request_id"))
+	lg.Info("complete", zap.String("request_id", "r1"))
+	lg.Info("incomplete")
+	lg.Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), "complete") || strings.Contains(string(data), "incomplete") {
+		t.Fatalf("unexpected log: %s", data)
+	}
+
+	dir = t.TempDir()
+	lg = NewLogger(WithLogFileDir(dir), WithDevelopment(true), WithConsole(false), WithRequiredFields(RequiredReject, "service", "request_id"))
+	lg.Info("incomplete")
+	lg.Sync()
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"msg":"incomplete","missing_fields":["service","request_id"]`) {
+		t.Fatalf("missing fields not flagged in development: %s", data)
+	}
+}
+
+func TestWithBanner(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithBanner(true), WithLevel("info"), WithVersion("1.2.3"), WithAudit("audit.log"))
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"msg":"[NewLogger] success","min_level":"info"`,
+		`"audit":"` + filepath.Join(dir, "audit.log"),
+		`"rotation":{"max_size_mb":100,"max_backups":60,"max_age_days":30,"compress":false}`,
+		`"version":"1.2.3"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+
+	NewLogger(WithLogFileDir(dir), WithBanner(true), WithLevel("trace"))
+	Sync()
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"min_level":"trace"`) {
+		t.Fatalf("unexpected trace level: %s", data)
+	}
+}
+
+func TestConfigAndValidate(t *testing.T) {
+	dir := t.TempDir()
+	merge := func(o *Options) { o.Merge = true }
+	NewLogger(WithLogFileDir(dir), WithLevel("info"), WithMaxSize(0), WithErrorFileName("error.log"), merge)
+	SetLevel("warn")
+	defer SetLevel("debug")
+
+	cfg := Config()
+	if cfg.Level != zapcore.WarnLevel || cfg.LogFileDir != dir || cfg.Console == nil || *cfg.Console {
+		t.Fatalf("config level %v, dir %s, console %v", cfg.Level, cfg.LogFileDir, cfg.Console)
+	}
+	errs := Validate()
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		"log: Merge is set, ErrorFileName is ignored",
+		"log: MaxSize is 0",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+	if len(errs) != 2 {
+		t.Fatalf("issues %q", got)
+	}
+	Error("merged")
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if _, err := os.Stat(filepath.Join(dir, "app-error.log")); !os.IsNotExist(err) {
+		t.Fatal("level file written with Merge")
+	}
+	if strings.Count(string(data), `"msg":"[NewLogger] invalid config"`) != 2 {
+		t.Fatalf("startup warnings missing in %s", data)
+	}
+
+	opts := Options{File: true, MaxSize: 100, FileName: ".log", InfoFileName: ".log"}
+	if errs := opts.Validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), `FileName and InfoFileName both write to ".log"`) {
+		t.Fatalf("errs %v", errs)
+	}
+}
+
+// failingSyncer 写入总是失败
+type failingSyncer struct{}
+
+func (failingSyncer) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+func (failingSyncer) Sync() error                 { return nil }
+
+func TestWithHeartbeat(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithSink(failingSyncer{}), WithHeartbeat(20*time.Millisecond))
+	Warn("one")
+	time.Sleep(70 * time.Millisecond)
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"msg":"[heartbeat]","entries":{"info":2,"warn":1},"dropped":0`,
+		`{"name":"file","writes":2,"errors":0,"healthy":true}`,
+		`{"name":"sink-0","writes":2,"errors":2,"healthy":false,"last_error":"disk full"}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestWithRuntimeStats(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithRuntimeStats(20*time.Millisecond))
+	runtime.GC()
+	time.Sleep(50 * time.Millisecond)
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{`"msg":"[runtime]","heap_alloc":`, `"gc_pause_max":`, `"goroutines":`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if runtime.GOOS == "linux" && !strings.Contains(string(data), `"open_fds":`) {
+		t.Fatalf("missing open_fds in %s", data)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	beat := Watch("consumer", 30*time.Millisecond)
+	defer Unwatch("consumer")
+	stopped := Watch("stopped", 30*time.Millisecond)
+	Unwatch("stopped")
+	replaced := Watch("replaced", 30*time.Millisecond)
+	Watch("replaced", time.Hour)
+	defer Unwatch("replaced")
+	for i := 0; i < 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+		beat()
+		// 取消或被替换后的心跳不再重新计时
+		stopped()
+		replaced()
+	}
+	Sync()
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if strings.Contains(string(data), "component stalled") {
+		t.Fatalf("stalled while beating: %s", data)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	Sync()
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	if n := strings.Count(string(data), `"msg":"[watchdog] component stalled","component":"consumer"`); n != 1 {
+		t.Fatalf("%d stalled entries in %s", n, data)
+	}
+	if !strings.Contains(string(data), "goroutine ") || strings.Contains(string(data), `"component":"stopped"`) ||
+		strings.Contains(string(data), `"component":"replaced"`) {
+		t.Fatalf("unexpected watchdog output %s", data)
+	}
+}
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithMaxSize(1))
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 1100; i++ {
+		Info("bulk", zap.String("payload", payload))
+	}
+	Error("failed")
+
+	c := Stats()
+	if c.Entries["info"] != 1101 || c.Entries["error"] != 1 {
+		t.Fatalf("entries = %v", c.Entries)
+	}
+	file := c.Sinks["file"]
+	if file.Writes != 1102 || file.Bytes < 1100*1024 || file.Errors != 0 {
+		t.Fatalf("file sink = %+v", file)
+	}
+	if c.Rotations != 1 {
+		t.Fatalf("rotations = %d", c.Rotations)
+	}
+	if backups := logFiles(filepath.Join(dir, "app.log")); len(backups) != 2 {
+		t.Fatalf("log files = %v", backups)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	NewLogger(WithLogFileDir(t.TempDir()))
+	Info("sized", zap.String("payload", strings.Repeat("x", 300)))
+
+	c := Stats().Sinks["file"]
+	if c.Sizes.Count != 2 || c.Sizes.Counts[0] != 1 || c.Sizes.Counts[2] != 1 {
+		t.Fatalf("size histogram = %+v", c.Sizes)
+	}
+	if c.Latency.Count != 2 || c.Latency.Sum <= 0 {
+		t.Fatalf("latency histogram = %+v", c.Latency)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`log_entries_total{level="info"} 2`,
+		`log_sink_writes_total{sink="file"} 2`,
+		`log_entry_size_bytes_bucket{sink="file",le="128"} 1`,
+		`log_entry_size_bytes_bucket{sink="file",le="512"} 2`,
+		`log_sink_write_duration_seconds_bucket{sink="file",le="+Inf"} 2`,
+		`log_sink_write_duration_seconds_count{sink="file"} 2`,
+		"log_rotations_total 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %s in\n%s", want, body)
+		}
+	}
+}
+
+// probingSyncer 实现 Prober
+type probingSyncer struct {
+	bytes.Buffer
+	err error
+}
+
+func (s *probingSyncer) Sync() error                     { return nil }
+func (s *probingSyncer) Probe(ctx context.Context) error { return s.err }
+
+func TestHealthCheck(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithSink(&probingSyncer{}), WithSink(&probingSyncer{err: errors.New("connection refused")}))
+	statuses, err := HealthCheck(context.Background())
+	if err == nil || err.Error() != "connection refused" {
+		t.Fatalf("err = %v", err)
+	}
+	got := map[string]error{}
+	for _, s := range statuses {
+		got[s.Name] = s.Err
+	}
+	if len(got) != 3 || got["file"] != nil || got["sink-0"] != nil || got["sink-1"] == nil {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+}
+
+func TestHTTPSinkRetry(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(srv.URL, SinkRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+	defer sink.Close()
+	NewLogger(WithLogFileDir(t.TempDir()), WithFile(false), WithSink(sink))
+	Info("shipped")
+	Sync()
+	if err := sink.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if attempts != 3 || len(received) != 1 || !strings.Contains(received[0], `"msg":"shipped"`) {
+		t.Fatalf("attempts %d, received %q", attempts, received)
+	}
+	mu.Unlock()
+	if _, err := HealthCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSinkCompression(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(srv.URL, SinkCompression(CompressGzip))
+	defer sink.Close()
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	sink.Write([]byte(`{"msg":"b"}` + "\n"))
+	if err := sink.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(received) != 1 || received[0] != "{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n" {
+		t.Fatalf("received %q", received)
+	}
+	mu.Unlock()
+
+	bad := NewRemoteSink(func(ctx context.Context, batch []byte) error { return nil }, SinkCompression("lz4"))
+	defer bad.Close()
+	bad.Write([]byte("{}\n"))
+	if err := bad.Sync(); err == nil || !strings.Contains(err.Error(), `unsupported sink compression "lz4"`) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 未内置的算法通过 SinkCodec 接入
+	var got []byte
+	custom := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		got = append([]byte(nil), batch...)
+		return nil
+	}, SinkCodec("upper", func(b []byte) ([]byte, error) { return bytes.ToUpper(b), nil }))
+	defer custom.Close()
+	custom.Write([]byte(`{"msg":"a"}` + "\n"))
+	if err := custom.Sync(); err != nil || string(got) != "{\"MSG\":\"A\"}\n" {
+		t.Fatalf("err %v, batch %q", err, got)
+	}
+}
+
+func TestHTTPSinkTLSAndProxy(t *testing.T) {
+	dir := t.TempDir()
+	var clientCerts int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(&clientCerts, int32(len(r.TLS.PeerCertificates)))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "app"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	sink := NewHTTPSink(srv.URL, SinkRetry(RetryPolicy{MaxAttempts: 1}), SinkTLS(TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}))
+	defer sink.Close()
+	sink.Write([]byte(`{"msg":"secure"}` + "\n"))
+	if err := sink.Sync(); err != nil || atomic.LoadInt32(&clientCerts) != 1 {
+		t.Fatalf("err %v, client certs %d", err, clientCerts)
+	}
+
+	bad := NewHTTPSink(srv.URL, SinkRetry(RetryPolicy{MaxAttempts: 1}), SinkTLS(TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}))
+	defer bad.Close()
+	bad.Write([]byte(`{"msg":"lost"}` + "\n"))
+	if err := bad.Sync(); err == nil || bad.Probe(context.Background()) == nil {
+		t.Fatal("expected tls config error")
+	}
+
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+	viaProxy := NewHTTPSink("http://collector.invalid/ingest", SinkRetry(RetryPolicy{MaxAttempts: 1}), SinkProxy(proxy.URL))
+	defer viaProxy.Close()
+	viaProxy.Write([]byte(`{"msg":"proxied"}` + "\n"))
+	if err := viaProxy.Sync(); err != nil || proxied != "http://collector.invalid/ingest" {
+		t.Fatalf("err %v, proxied %q", err, proxied)
+	}
+}
+
+func TestRemoteSinkPermanentError(t *testing.T) {
+	var attempts int32
+	sink := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		return Permanent(errors.New("bad request"))
+	}, SinkRetry(RetryPolicy{MaxAttempts: 5, Backoff: time.Millisecond}))
+	defer sink.Close()
+	sink.Write([]byte("{}\n"))
+	if err := sink.Sync(); err == nil || !strings.Contains(err.Error(), "bad request") {
+		t.Fatalf("err = %v", err)
+	}
+	if atomic.LoadInt32(&attempts) != 1 {
+		t.Fatalf("permanent error retried %d times", attempts)
+	}
+}
+
+func TestSinkDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead", "http.log")
+	var fail int32 = 1
+	var received bytes.Buffer
+	sink := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("collector down")
+		}
+		received.Write(batch)
+		return nil
+	}, SinkRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}), SinkDeadLetter(path))
+	defer sink.Close()
+
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	sink.Write([]byte(`{"msg":"b"}` + "\n"))
+	if err := sink.Sync(); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), `"error":"collector down","entry":{"msg":`) != 2 {
+		t.Fatalf("unexpected dead letter: %s", data)
+	}
+
+	// 重放失败时日志写回死信文件，移开的文件被删除
+	if err := sink.Replay(path); err == nil {
+		t.Fatal("expected replay error")
+	}
+	if again, _ := os.ReadFile(path); !bytes.Equal(again, data) {
+		t.Fatalf("dead letter after failed replay: %s", again)
+	}
+	if matches, _ := filepath.Glob(path + ".replay-*"); len(matches) != 0 {
+		t.Fatalf("replay files left: %v", matches)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	if err := sink.Replay(path); err != nil {
+		t.Fatal(err)
+	}
+	if received.String() != "{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n" {
+		t.Fatalf("replayed %q", received.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("dead letter file not removed after replay")
+	}
+}
+
+func TestSinkBatch(t *testing.T) {
+	var mu sync.Mutex
+	var batches []string
+	send := func(ctx context.Context, batch []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, string(batch))
+		return nil
+	}
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), batches...)
+	}
+
+	bySize := NewRemoteSink(send, SinkBatch(2, 0, time.Hour))
+	for _, msg := range []string{"a", "b", "c"} {
+		bySize.Write([]byte(msg + "\n"))
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := received(); len(got) != 1 || got[0] != "a\nb\n" {
+		t.Fatalf("batches %q", got)
+	}
+	bySize.Close()
+
+	mu.Lock()
+	batches = nil
+	mu.Unlock()
+	byLinger := NewRemoteSink(send, SinkBatch(100, 0, 20*time.Millisecond))
+	defer byLinger.Close()
+	byLinger.Write([]byte("d\n"))
+	time.Sleep(60 * time.Millisecond)
+	if got := received(); len(got) != 1 || got[0] != "d\n" {
+		t.Fatalf("batches %q", got)
+	}
+}
+
+func TestSinkTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.log")
+	hang := make(chan struct{})
+	defer close(hang)
+	var inflight, overlap int32
+	sink := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		if atomic.AddInt32(&inflight, 1) > 1 {
+			atomic.StoreInt32(&overlap, 1)
+		}
+		defer atomic.AddInt32(&inflight, -1)
+		select {
+		case <-hang:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, SinkRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}), SinkDeadLetter(path), SinkTimeout(10*time.Millisecond, time.Second))
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	if err := sink.Sync(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"entry":{"msg":"a"}`) {
+		t.Fatalf("dead letter %s", data)
+	}
+	if atomic.LoadInt32(&overlap) != 0 {
+		t.Fatal("retry overlapped a timed-out send")
+	}
+	sink.Close()
+
+	block := make(chan struct{})
+	defer close(block)
+	stuck := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		<-block
+		return nil
+	}, SinkTimeout(0, 20*time.Millisecond))
+	stuck.Write([]byte(`{"msg":"b"}` + "\n"))
+	start := time.Now()
+	if err := stuck.Close(); !errors.Is(err, ErrFlushTimeout) {
+		t.Fatalf("err = %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("Close blocked on a hung send")
+	}
+}
+
+func TestSinkBreaker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.log")
+	var attempts, fail int32 = 0, 1
+	sink := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("connect timeout")
+		}
+		return nil
+	}, SinkRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}), SinkBreaker(2, 50*time.Millisecond), SinkDeadLetter(path))
+	defer sink.Close()
+
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	if err := sink.Sync(); err == nil || atomic.LoadInt32(&attempts) != 2 || sink.BreakerState() != "open" {
+		t.Fatalf("err %v, attempts %d, state %s", err, attempts, sink.BreakerState())
+	}
+	sink.Write([]byte(`{"msg":"b"}` + "\n"))
+	if err := sink.Sync(); !errors.Is(err, ErrCircuitOpen) || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("err %v, attempts %d", err, attempts)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"error":"log: remote sink circuit open","entry":{"msg":"b"}`) {
+		t.Fatalf("dead letter %s", data)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if sink.BreakerState() != "half-open" {
+		t.Fatalf("state %s", sink.BreakerState())
+	}
+	atomic.StoreInt32(&fail, 0)
+	sink.Write([]byte(`{"msg":"c"}` + "\n"))
+	if err := sink.Sync(); err != nil || atomic.LoadInt32(&attempts) != 3 || sink.BreakerState() != "closed" {
+		t.Fatalf("err %v, attempts %d, state %s", err, attempts, sink.BreakerState())
+	}
+}
+
+func TestSinkWAL(t *testing.T) {
+	dir := t.TempDir()
+	down := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		return errors.New("collector down")
+	}, SinkRetry(RetryPolicy{MaxAttempts: 1}), SinkWAL(dir, 0))
+	down.Write([]byte(`{"msg":"a"}` + "\n"))
+	if err := down.Sync(); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	down.Close()
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if len(segments) != 1 {
+		t.Fatalf("expected 1 pending segment, got %v", segments)
+	}
+
+	var mu sync.Mutex
+	var received bytes.Buffer
+	up := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received.Write(batch)
+		return nil
+	}, SinkWAL(dir, 0))
+	defer up.Close()
+	up.Write([]byte(`{"msg":"b"}` + "\n"))
+	if err := up.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	got := received.String()
+	mu.Unlock()
+	if got != "{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n" {
+		t.Fatalf("received %q", got)
+	}
+	if segments, _ := filepath.Glob(filepath.Join(dir, "*.wal")); len(segments) != 0 {
+		t.Fatalf("segments not removed: %v", segments)
+	}
+}
+
+func TestShipperRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, _ := os.Create(path)
+	f.WriteString("a\nb")
+	var out bytes.Buffer
+	s := newShipper(path, &out)
+	if err := s.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a\n" {
+		t.Fatalf("shipped %q", out.String())
+	}
+
+	// 模拟 lumberjack 轮转：旧文件被重命名后补全最后一行，再创建新文件
+	os.Rename(path, strings.TrimSuffix(path, ".log")+"-2021-01-01T00-00-00.000.log")
+	f.WriteString("\n")
+	f.Close()
+	os.WriteFile(path, []byte("c\n"), 0644)
+	if err := s.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a\nb\nc\n" {
+		t.Fatalf("shipped %q", out.String())
+	}
+
+	// 重启后从保存的位置继续
+	os.WriteFile(path, []byte("c\nd\n"), 0644)
+	out.Reset()
+	if err := newShipper(path, &out).poll(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "d\n" {
+		t.Fatalf("shipped after restart %q", out.String())
+	}
+
+	// 开启 Compress 时备份被压缩为新文件，从 .gz 中读完剩余的行
+	os.WriteFile(path, []byte("c\nd\ne"), 0644)
+	s = newShipper(path, &out)
+	s.poll()
+	out.Reset()
+	// 与 lumberjack 相同的顺序：重命名、创建新文件、压缩后删除未压缩的备份
+	rotated := strings.TrimSuffix(path, ".log") + "-2021-01-02T00-00-00.000.log"
+	os.Rename(path, rotated)
+	os.WriteFile(path, []byte("f\n"), 0644)
+	backup, _ := os.Create(rotated + ".gz")
+	gz := gzip.NewWriter(backup)
+	gz.Write([]byte("c\nd\ne\n"))
+	gz.Close()
+	backup.Close()
+	os.Remove(rotated)
+	if err := s.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "e\nf\n" {
+		t.Fatalf("shipped after compressed rotation %q", out.String())
+	}
+}
+
+// sizeWriter 记录每次写入的长度
+type sizeWriter struct{ sizes []int }
+
+func (w *sizeWriter) Write(p []byte) (int, error) {
+	w.sizes = append(w.sizes, len(p))
+	return len(p), nil
+}
+
+func TestShipperChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	line := strings.Repeat("x", 1023) + "\n"
+	os.WriteFile(path, []byte(strings.Repeat(line, 1000)), 0644)
+	w := &sizeWriter{}
+	if err := newShipper(path, w).poll(); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, n := range w.sizes {
+		if n > shipChunk+len(line) {
+			t.Fatalf("shipped %d bytes in one write", n)
+		}
+		total += n
+	}
+	if len(w.sizes) < 2 || total != 1000*len(line) {
+		t.Fatalf("shipped %d bytes in %d writes", total, len(w.sizes))
+	}
+}
+
+func TestWithShipper(t *testing.T) {
+	var mu sync.Mutex
+	var received bytes.Buffer
+	sink := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received.Write(batch)
+		return nil
+	})
+	defer sink.Close()
+	lg := NewLogger(WithLogFileDir(t.TempDir()), WithShipper(sink, 10*time.Millisecond))
+	lg.Info("shipped")
+	lg.Sync()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sink.Sync()
+		mu.Lock()
+		got := received.String()
+		mu.Unlock()
+		if strings.Contains(got, `"msg":"shipped"`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entry not shipped: %q", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.stop()
+}
+
+type fakeEntryStream struct {
+	mu      *sync.Mutex
+	entries *[]string
+	fail    int // 第 fail 次 Send 时失败
+	sent    int
+	lost    int             // CloseAndRecv 少确认的条数
+	hang    context.Context // 非空时 Send 阻塞到流中止
+	closed  bool
+}
+
+func (s *fakeEntryStream) Send(entry []byte) error {
+	if s.hang != nil {
+		<-s.hang.Done()
+		return s.hang.Err()
+	}
+	s.sent++
+	if s.sent == s.fail {
+		return errors.New("stream reset")
+	}
+	s.mu.Lock()
+	*s.entries = append(*s.entries, string(entry))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *fakeEntryStream) CloseSend() error {
+	s.closed = true
+	return nil
+}
+
+func (s *fakeEntryStream) CloseAndRecv() (uint64, error) {
+	s.closed = true
+	return uint64(s.sent - s.lost), nil
+}
+
+func TestStreamSink(t *testing.T) {
+	var mu sync.Mutex
+	var entries []string
+	var streams []*fakeEntryStream
+	sink := NewStreamSink(func(ctx context.Context) (EntryStream, error) {
+		s := &fakeEntryStream{mu: &mu, entries: &entries}
+		if len(streams) == 0 {
+			s.fail = 2
+		}
+		streams = append(streams, s)
+		return s, nil
+	}, SinkRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}))
+
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	sink.Write([]byte(`{"msg":"b"}` + "\n"))
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一条流在发送 b 时断开，重建后整批重发
+	want := []string{`{"msg":"a"}`, `{"msg":"a"}`, `{"msg":"b"}`}
+	if fmt.Sprint(entries) != fmt.Sprint(want) {
+		t.Fatalf("entries %v", entries)
+	}
+	if len(streams) != 2 || !streams[0].closed || !streams[1].closed {
+		t.Fatal("streams not closed")
+	}
+}
+
+func TestStreamSinkWALAck(t *testing.T) {
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var entries []string
+	var streams []*fakeEntryStream
+	sink := NewStreamSink(func(ctx context.Context) (EntryStream, error) {
+		s := &fakeEntryStream{mu: &mu, entries: &entries}
+		if len(streams) == 0 {
+			s.lost = 1
+		}
+		streams = append(streams, s)
+		return s, nil
+	}, SinkRetry(RetryPolicy{MaxAttempts: 1}), SinkWAL(dir, 1<<20))
+	defer sink.Close()
+
+	// 服务端少确认一条时保留预写日志分段
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	if err := sink.Sync(); err == nil || !strings.Contains(err.Error(), "acknowledged 0 of 1") {
+		t.Fatalf("err = %v", err)
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*")); len(segs) == 0 {
+		t.Fatal("unacknowledged segment removed")
+	}
+	sink.Write([]byte(`{"msg":"b"}` + "\n"))
+	if err := sink.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 || !streams[0].closed || !streams[1].closed {
+		t.Fatalf("each batch should use its own stream, got %d", len(streams))
+	}
+}
+
+func TestStreamSinkTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var entries []string
+	var opened int32
+	sink := NewStreamSink(func(ctx context.Context) (EntryStream, error) {
+		s := &fakeEntryStream{mu: &mu, entries: &entries}
+		if atomic.AddInt32(&opened, 1) == 1 {
+			s.hang = ctx
+		}
+		return s, nil
+	}, SinkRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}), SinkTimeout(20*time.Millisecond, time.Second))
+	defer sink.Close()
+
+	// 卡住的流在超时后中止，重试时重新建立
+	sink.Write([]byte(`{"msg":"a"}` + "\n"))
+	if err := sink.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if atomic.LoadInt32(&opened) != 2 || fmt.Sprint(entries) != `[{"msg":"a"}]` {
+		t.Fatalf("opened %d, entries %v", opened, entries)
+	}
+}
+
+func TestLogServerRPC(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithRPCPayloadLimit(16))
+	ctx := context.Background()
+	LogServerRPC(ctx, RPCCall{Method: "/order.Orders/Get", Peer: "10.0.0.1:5000", Code: "OK",
+		Duration: time.Millisecond, Request: map[string]int{"id": 1}, Response: strings.Repeat("x", 32)})
+	LogServerRPC(ctx, RPCCall{Method: "/order.Orders/Get", Code: "Unavailable", Err: errors.New("db down")})
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"level":"info"`, `"msg":"grpc server","method":"/order.Orders/Get","peer":"10.0.0.1:5000","status":"OK"`,
+		`"request":{"id":1}`, `"response_size":34,"response_truncated":true`,
+		`"level":"error"`, `"status":"Unavailable"`, `"error":"db down"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestRPCPayloadRespectsLevel(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"), WithFlightRecorder(100))
+	defer ResetLoggerLevel("rpc")
+	ctx := context.Background()
+	LogServerRPC(ctx, RPCCall{Method: "/auth.Auth/Login", Code: "OK", Request: map[string]string{"password": "hunter2"}})
+	SetLoggerLevel("rpc", "debug")
+	LogServerRPC(NewContext(ctx, Named("rpc")), RPCCall{Method: "/auth.Auth/Login", Code: "OK", Request: map[string]string{"user": "u1"}})
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if strings.Contains(string(data), "hunter2") || !strings.Contains(string(data), `"request":{"user":"u1"}`) {
+		t.Fatalf("payload logged at the wrong level: %s", data)
+	}
+}
+
+func TestLogClientRPC(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	ctx := context.Background()
+	for attempt, code := range []string{"Unavailable", "OK"} {
+		LogClientRPC(ctx, RPCCall{Method: "/order.Orders/Get", Peer: "orders:443", Code: code, Attempt: attempt + 1})
+	}
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"level":"error"`, `"msg":"grpc client","method":"/order.Orders/Get","peer":"orders:443","status":"Unavailable","duration":0,"attempt":1`,
+		`"status":"OK","duration":0,"attempt":2`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestWrapTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "upstream down")
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithFlightRecorder(100))
+
+	client := &http.Client{Transport: WrapTransport(nil)}
+	SetLevel("info")
+	resp, err := client.Get(srv.URL + "/quiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	SetLevel("debug")
+
+	ctx := RetryContext(context.Background())
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL+"/orders", strings.NewReader(`{"id":1}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "upstream down" {
+			t.Fatalf("body %q", body)
+		}
+	}
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"level":"warn"`, `"msg":"http client","method":"POST","url":"` + srv.URL + `/orders"`,
+		`"retry":1`, `"status":502`, `"Authorization":"[REDACTED]"`,
+		`"request_body":"{\"id\":1}"`, `"msg":"http client response body","method":"POST"`, `"response_body":"upstream down"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	quiet := `"url":"` + srv.URL + `/quiet"`
+	if strings.Count(string(data), `"response_body"`) != 2 || !strings.Contains(string(data), quiet+`,"duration"`) {
+		t.Fatalf("headers or bodies logged at info level: %s", data)
+	}
+}
+
+func TestWrapTransportStreaming(t *testing.T) {
+	sent := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		<-sent
+	}))
+	defer srv.Close()
+	defer close(sent)
+	NewLogger(WithLogFileDir(t.TempDir()))
+
+	client := &http.Client{Transport: WrapTransport(nil)}
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			done <- resp
+		}
+	}()
+	select {
+	case resp := <-done:
+		resp.Body.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("RoundTrip blocked on a streaming response")
+	}
+}
+
+func TestKafkaLogger(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	k := NewKafkaLogger(zapcore.InfoLevel)
+	k.Printf("client/metadata fetching metadata for %d topics\n", 2)
+	k.KgoLog(2, "unable to open connection to broker", "addr", "kafka:9092")
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"msg":"client/metadata fetching metadata for 2 topics","component":"kafka"`,
+		`"level":"warn"`, `"msg":"unable to open connection to broker","component":"kafka","addr":"kafka:9092"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestRedisLogging(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	ctx := context.Background()
+	RedisLogger{}.Printf(ctx, "redis: discarding bad PubSub connection: %s", "EOF")
+	LogRedisCommand(ctx, "get", []interface{}{"get", "user:1"}, time.Millisecond, nil)
+	LogRedisCommand(ctx, "auth", []interface{}{"auth", "secret"}, time.Millisecond, errors.New("WRONGPASS"))
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"msg":"redis: discarding bad PubSub connection: EOF","component":"redis"`,
+		`"level":"debug"`, `"cmd":"get user:1"`,
+		`"cmd":"auth [REDACTED]"`, `"error":"WRONGPASS"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("auth password logged: %s", data)
+	}
+}
+
+func TestJob(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithEvents("events.log"))
+	if err := Job("cleanup", func(ctx context.Context) error {
+		FromContext(ctx).Info("removed", zap.Int("count", 3))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Job("report", func(ctx context.Context) error {
+		panic("nil map")
+	}); err == nil {
+		t.Fatal("expected panic error")
+	}
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"msg":"[job] start","job":"cleanup","run_id":"`, `"msg":"removed","job":"cleanup"`,
+		`"outcome":"success"`, `"level":"error"`, `"job":"report"`, `"outcome":"panic"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	events, _ := os.ReadFile(filepath.Join(dir, "events.log"))
+	if !strings.Contains(string(events), `"event":"job.failed"`) || !strings.Contains(string(events), `"job":"report"`) {
+		t.Fatalf("missing failure event in %s", events)
+	}
+}
+
+func TestCommandLogger(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	cmd := exec.Command(sh, "-c", "echo migrated; echo 'missing index' >&2; printf done")
+	flush := CommandLogger(cmd, zapcore.InfoLevel)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	flush()
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	pid := fmt.Sprintf(`"pid":%d`, cmd.Process.Pid)
+	for _, want := range []string{
+		`"msg":"migrated","cmd":"sh",` + pid + `,"stream":"stdout"`,
+		`"msg":"missing index","cmd":"sh",` + pid + `,"stream":"stderr"`,
+		`"msg":"done"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestMaxEntrySize(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithMaxEntrySize(512))
+	Info("small", zap.String("id", "1"))
+	Info(strings.Repeat("m", 300), zap.String("body", strings.Repeat("b", 2000)), zap.Any("ids", make([]int, 200)))
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	last := lines[len(lines)-1]
+	if len(last) > 512 || !strings.Contains(last, `"truncated":true`) || !strings.Contains(last, `"body":"bbb`) {
+		t.Fatalf("entry not truncated (%d bytes): %s", len(last), last)
+	}
+	if strings.Contains(lines[len(lines)-2], "truncated") {
+		t.Fatalf("small entry truncated: %s", lines[len(lines)-2])
+	}
+}
+
+type limitedOrder struct {
+	ID     string            `json:"id"`
+	Items  []int             `json:"items"`
+	Tags   map[string]string `json:"tags"`
+	Parent *limitedOrder     `json:"parent,omitempty"`
+	secret string
+}
+
+func TestFieldLimits(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithFieldLimits(FieldLimits{MaxString: 8, MaxElements: 2, MaxDepth: 2}))
+	order := &limitedOrder{
+		ID:     "order-123456789",
+		Items:  []int{1, 2, 3, 4},
+		Tags:   map[string]string{"a": "1", "b": "2", "c": "3"},
+		Parent: &limitedOrder{ID: "p", Items: []int{1}},
+		secret: "s",
+	}
+	Info("order", zap.Any("order", order), zap.String("note", "a long note"))
+	Info("batch", zap.Strings("ids", []string{"a", "bbbbbbbbbbbb", "c"}), zap.Any("counts", []int{1, 2, 3}),
+		zap.Durations("waits", []time.Duration{time.Second, time.Second, time.Second}),
+		zap.Object("user", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("name", "a very long name")
+			enc.AddInt("age", 30)
+			return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+				enc.AppendString("admin")
+				enc.AppendString("dev")
+				enc.AppendString("ops")
+				return nil
+			}))
+		})))
+	// 在限制之内的值原样输出
+	Info("small", zap.Strings("ids", []string{"a"}), zap.Any("point", struct{ X, Y int }{1, 2}))
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"id":"order-12..."`, `"items":[1,2,"+2 more"]`, `"tags":{"...":"+1 more","a":"1","b":"2"}`,
+		`"parent":{"id":"p","items":"[max depth]","tags":null}`, `"note":"a long n..."`,
+		`"ids":["a","bbbbbbbb...","+1 more"]`, `"counts":[1,2,"+1 more"]`, `"waits":[1,1,"+1 more"]`,
+		`"user":{"age":30,"name":"a very l...","roles":["admin","dev","+1 more"]}`,
+		`"msg":"small","ids":["a"],"point":{"X":1,"Y":2}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+}
+
+func TestBinaryPolicy(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithBinaryPolicy(BinaryPolicy{Encoding: BinaryHex, MaxBytes: 4}))
+	Info("frame", zap.Binary("payload", []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02}), zap.Binary("ack", []byte{0x06}))
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), `"payload":"deadbeef...(+2 bytes)","ack":"06"`) {
+		t.Fatalf("unexpected binary encoding: %s", data)
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.CreateTemp(dir, "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(ws zapcore.WriteSyncer) { consoleWs = ws }(consoleWs)
+	consoleWs = out
+
+	lg := NewLogger(WithLogFileDir(dir), WithDevelopment(true), WithPrettyJSON())
+	lg.Info("nested", zap.Any("order", map[string]interface{}{"id": 1}))
+	lg.Sync()
+
+	data, _ := os.ReadFile(out.Name())
+	if !strings.Contains(string(data), "  \"msg\": \"nested\",\n") || !strings.Contains(string(data), "  \"order\": {\n    \"id\": 1\n  }\n}\n") {
+		t.Fatalf("console not pretty printed: %s", data)
+	}
+	file, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(file), `"msg":"nested","order":{"id":1}}`) {
+		t.Fatalf("file not compact: %s", file)
+	}
+}
+
+func TestMultilineStack(t *testing.T) {
+	for _, multiline := range []bool{true, false} {
+		dir := t.TempDir()
+		out, err := os.CreateTemp(dir, "stdout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws := consoleWs
+		consoleWs = out
+		lg := NewLogger(WithLogFileDir(dir), WithConsole(true), WithMultilineStack(multiline))
+		lg.Error("failed", zap.String("stacktrace", "main.run\n\t/app/main.go:10"), zap.Int("attempt", 2))
+		lg.Sync()
+		consoleWs = ws
+
+		data, _ := os.ReadFile(out.Name())
+		rendered := strings.Contains(string(data), "{\"attempt\": 2}\nstacktrace:\n\tmain.run\n\t\t/app/main.go:10\n")
+		if rendered != multiline {
+			t.Fatalf("multiline=%v: %s", multiline, data)
+		}
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app-2021-01-01T00-00-00.000.log.gz")
+	f, _ := os.Create(path)
+	gz := gzip.NewWriter(f)
+	io.WriteString(gz, `{"level":"warn","ts":"2021-01-01 00:00:00.000","msg":"disk low","free":"1GB"}`+"\n")
+	io.WriteString(gz, "partial li\n")
+	io.WriteString(gz, `{"L":"error","T":"2021-01-01 00:00:01.000","M":"disk full","free":"0"}`+"\n")
+	gz.Close()
+	f.Close()
+
+	var got []string
+	err := DecodeFile(path, func(e Entry, raw []byte) error {
+		got = append(got, fmt.Sprintf("%s %s %v %s", e.Level, e.Message, e.Fields["free"], raw[:1]))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"warn disk low 1GB {", "error disk full 0 {"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("decoded %q", got)
+	}
+
+	// 截断的归档读取出错，关闭时 gzip reader 返回该错误
+	data, _ := os.ReadFile(path)
+	truncated := filepath.Join(dir, "app-2021-01-02T00-00-00.000.log.gz")
+	os.WriteFile(truncated, data[:len(data)-10], 0644)
+	r, err := openLogFile(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(r)
+	if err := r.Close(); err == nil {
+		t.Fatal("gzip reader not closed")
+	}
+	if err := r.(readCloser).Closer.(closers)[1].Close(); err == nil {
+		t.Fatal("file not closed")
+	}
+}
+
+func TestIPAnonymization(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithAccessLog("access.log", AccessLogJSON),
+		WithProcessor(TruncateIP(24, 48, "remote", "Client_IP"), HashIP([]byte("salt"), "peer")))
+	Info("login", zap.String("client_ip", "203.0.113.7"), zap.String("peer", "10.0.0.1:5000"), zap.String("remote", "not an ip"))
+	Info("v6", zap.String("remote", "[2001:db8:1:2::7]:443"))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.23:1234"
+	Access(req, 200, 0, time.Now())
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"client_ip":"203.0.113.0"`, `"remote":"not an ip"`, `"remote":"[2001:db8:1::]:443"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if !regexp.MustCompile(`"peer":"[0-9a-f]{16}:5000"`).Match(data) {
+		t.Fatalf("peer not hashed: %s", data)
+	}
+	access, _ := os.ReadFile(filepath.Join(dir, "access.log"))
+	if !strings.Contains(string(access), `"remote":"198.51.100.0"`) {
+		t.Fatalf("access log not anonymized: %s", access)
+	}
+
+	for _, format := range []AccessLogFormat{AccessLogCommon, AccessLogCombined} {
+		dir := t.TempDir()
+		NewLogger(WithLogFileDir(dir), WithAccessLog("access.log", format), WithProcessor(TruncateIP(24, 48, "remote")))
+		Access(req, 200, 0, time.Now())
+		access, _ := os.ReadFile(filepath.Join(dir, "access.log"))
+		if !strings.HasPrefix(string(access), "198.51.100.0 - - [") {
+			t.Fatalf("%s access log not anonymized: %s", format, access)
+		}
+	}
+
+	for _, bits := range [][2]int{{33, 48}, {24, 129}, {-1, 48}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("TruncateIP(%d, %d) did not panic", bits[0], bits[1])
+				}
+			}()
+			TruncateIP(bits[0], bits[1], "remote")
+		}()
+	}
+}
+
+func TestPurge(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	backup := filepath.Join(dir, "app-"+time.Now().Add(-time.Hour).Format(backupTimeFormat)+".log.gz")
+	f, _ := os.Create(backup)
+	gz := gzip.NewWriter(f)
+	io.WriteString(gz, `{"level":"info","msg":"signup","subject_id":"u-1"}`+"\n")
+	io.WriteString(gz, `{"level":"info","msg":"signup","subject_id":"u-2"}`+"\n")
+	gz.Close()
+	f.Close()
+
+	Info("login", Subject("u-1"))
+	Info("login", Subject("u-10"))
+	n, err := Purge("u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("purged %d entries, want 2", n)
+	}
+	Info("after purge")
+	Sync()
+
+	var left []string
+	for _, file := range logFiles(filepath.Join(dir, "app.log")) {
+		DecodeFile(file.path, func(e Entry, raw []byte) error {
+			if id, ok := e.Fields["subject_id"]; ok {
+				left = append(left, id.(string))
+			}
+			return nil
+		})
+	}
+	if fmt.Sprint(left) != "[u-2 u-10]" {
+		t.Fatalf("remaining subjects %v", left)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(data), "after purge") {
+		t.Fatalf("logging stopped after purge: %s", data)
+	}
+}
+
+func TestPurgeSkipsRotation(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir))
+	Info("login", Subject("u-2"))
+	// 当前文件不含该主体时不轮转
+	if n, err := Purge("u-1"); err != nil || n != 0 {
+		t.Fatalf("purged %d entries, err %v", n, err)
+	}
+	if files := logFiles(filepath.Join(dir, "app.log")); len(files) != 1 {
+		t.Fatalf("current file rotated: %v", files)
+	}
+}
+
+func TestPurgeCompressed(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithCompress(true))
+	Info("login", Subject("u-1"))
+	Info("login", Subject("u-2"))
+	// 轮转出的归档在后台压缩，改写须等压缩完成
+	if n, err := Purge("u-1"); err != nil || n != 1 {
+		t.Fatalf("purged %d entries, err %v", n, err)
+	}
+	var left []string
+	for _, file := range logFiles(filepath.Join(dir, "app.log")) {
+		if !file.rotated.IsZero() && !strings.HasSuffix(file.path, ".gz") {
+			t.Fatalf("uncompressed backup %s", file.path)
+		}
+		DecodeFile(file.path, func(e Entry, raw []byte) error {
+			if id, ok := e.Fields["subject_id"]; ok {
+				left = append(left, id.(string))
+			}
+			return nil
+		})
+	}
+	if fmt.Sprint(left) != "[u-2]" {
+		t.Fatalf("remaining subjects %v", left)
+	}
+}
+
+func TestPurgeLevelFiles(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithInfoFileName("info.log"))
+	Info("login", Subject("u-1"))
+	Info("login", Subject("u-2"))
+	// 等级日志文件中的副本也被删除
+	if n, err := Purge("u-1"); err != nil || n != 2 {
+		t.Fatalf("purged %d entries, err %v", n, err)
+	}
+	for _, name := range []string{"app.log", "app-info.log"} {
+		var left []string
+		for _, file := range logFiles(filepath.Join(dir, name)) {
+			DecodeFile(file.path, func(e Entry, raw []byte) error {
+				if id, ok := e.Fields["subject_id"]; ok {
+					left = append(left, id.(string))
+				}
+				return nil
+			})
+		}
+		if fmt.Sprint(left) != "[u-2]" {
+			t.Fatalf("%s: remaining subjects %v", name, left)
+		}
+	}
+}
+
+func TestLevelFiles(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithErrorFileName("error.log"), WithDebugFileName("debug.log"),
+		WithLevelRetention("error", 180, 0), WithLevelRetention("debug", 3, 2))
+	if r := l.Opts.LevelRetention[zapcore.DebugLevel]; r != (Retention{MaxAge: 3, MaxBackups: 2}) {
+		t.Fatalf("debug retention %+v", r)
+	}
+	Debug("cache miss")
+	Info("started")
+	Error("payment failed")
+	Sync()
+
+	errFile, _ := os.ReadFile(filepath.Join(dir, "app-error.log"))
+	debug, _ := os.ReadFile(filepath.Join(dir, "app-debug.log"))
+	all, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(errFile), "payment failed") || strings.Contains(string(errFile), "started") {
+		t.Fatalf("unexpected error file: %s", errFile)
+	}
+	if !strings.Contains(string(debug), "cache miss") || strings.Contains(string(debug), "payment failed") {
+		t.Fatalf("unexpected debug file: %s", debug)
+	}
+	if !strings.Contains(string(all), "cache miss") || !strings.Contains(string(all), "payment failed") {
+		t.Fatalf("main file incomplete: %s", all)
+	}
+}
+
+func TestReopenOnInodeChange(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithReopen(5*time.Millisecond))
+	path := filepath.Join(dir, "app.log")
+	Info("before rotate")
+	Sync()
+	time.Sleep(20 * time.Millisecond)
+	os.Rename(path, path+".1")
+	os.WriteFile(path, nil, 0644)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		Info("after rotate")
+		Sync()
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "after rotate") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("still writing to the moved file")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReopenAuxFiles(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithAudit("audit.log"), WithTenants("tenants", 0, 0), WithReopen(5*time.Millisecond))
+	audit, tenant := filepath.Join(dir, "audit.log"), filepath.Join(dir, "tenants", "t1.log")
+	Audit("before rotate")
+	Tenant("t1").Info("before rotate")
+	Sync()
+	time.Sleep(20 * time.Millisecond)
+	for _, path := range []string{audit, tenant} {
+		os.Rename(path, path+".1")
+		os.WriteFile(path, nil, 0644)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		Audit("after rotate")
+		Tenant("t1").Info("after rotate")
+		Sync()
+		a, _ := os.ReadFile(audit)
+		b, _ := os.ReadFile(tenant)
+		if strings.Contains(string(a), "after rotate") && strings.Contains(string(b), "after rotate") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("still writing to the moved files: audit %q, tenant %q", a, b)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPerProcessFiles(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithPerProcessFiles(true), WithErrorFileName("error.log"),
+		WithDebugCapture("capture.log", "user_id"))
+	AddDebugCapture("u1")
+	defer RemoveDebugCapture("u1")
+	Error("worker failed", zap.String("user_id", "u1"))
+	Sync()
+
+	pid := strconv.Itoa(os.Getpid())
+	for _, name := range []string{"app-" + pid + ".log", "app-error-" + pid + ".log", "app-capture-" + pid + ".log"} {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		if !strings.Contains(string(data), "worker failed") {
+			t.Fatalf("%s: %s", name, data)
+		}
+	}
+	var found int
+	Search(Query{}, func(e Entry) bool {
+		if e.Message == "worker failed" {
+			found++
+		}
+		return true
+	})
+	if found != 1 {
+		t.Fatalf("search found %d entries", found)
+	}
+}
+
+func TestLevelStateFile(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"), WithLevelStateFile("level.state"))
+	SetLevel("debug")
+	data, _ := os.ReadFile(filepath.Join(dir, "level.state"))
+	if string(data) != "debug\n" {
+		t.Fatalf("state file %q", data)
+	}
+
+	// 重启后恢复保存的等级
+	lg := NewLogger(WithLogFileDir(dir), WithLevel("info"), WithLevelStateFile("level.state"))
+	if !lg.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug level not restored")
+	}
+}
+
+func TestNamedLoggerLevels(t *testing.T) {
+	dir := t.TempDir()
+	NewLogger(WithLogFileDir(dir), WithLevel("info"))
+	defer ResetLoggerLevel("db")
+	defer ResetLoggerLevel("db.pool")
+	pool := Named("db.pool")
+	query := Named("db.query")
+
+	SetLoggerLevel("db", "debug")
+	SetLoggerLevel("db.pool", "warn")
+	pool.Info("pool info")
+	pool.Warn("pool warn")
+	query.Debug("query debug")
+	Debug("root debug")
+	Sync()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for msg, want := range map[string]bool{"pool info": false, "pool warn": true, "query debug": true, "root debug": false} {
+		if strings.Contains(string(data), msg) != want {
+			t.Fatalf("%s logged = %v: %s", msg, !want, data)
+		}
+	}
+	levels := LoggerLevels()
+	if levels["db.pool"] != "warn" || levels["db.query"] != "debug" || levels["db"] != "debug" {
+		t.Fatalf("levels %v", levels)
+	}
+}
@@ -0,0 +1,126 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDiagnosticSignal(t *testing.T) {
+	dir := t.TempDir()
+	ch, cancel := Subscribe(zapcore.InfoLevel)
+	defer cancel()
+	NewLogger(WithLogFileDir(dir), WithDumpDir(dir), WithFlightRecorder(10), WithDiagnosticSignal(syscall.SIGUSR2))
+	Debug("recent activity")
+	time.Sleep(10 * time.Millisecond)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Message != "[Diagnostic] dump written" {
+				continue
+			}
+			data, err := os.ReadFile(e.Fields["dump"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), "DIAGNOSTIC: user defined signal 2") || !strings.Contains(string(data), "recent activity") || !strings.Contains(string(data), "goroutine ") {
+				t.Fatalf("unexpected dump: %s", data)
+			}
+			return
+		case <-timeout:
+			t.Fatal("no diagnostic dump written")
+		}
+	}
+}
+
+func TestCaptureStdio(t *testing.T) {
+	// 重定向对整个进程生效，在子进程中验证
+	if dir := os.Getenv("LOG_TEST_CAPTURE_DIR"); dir != "" {
+		NewLogger(WithLogFileDir(dir), WithCaptureStdio(true))
+		fmt.Println("raw stdout")
+		syscall.Write(2, []byte("raw stderr\n"))
+		for i := 0; i < 200; i++ {
+			Sync()
+			data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+			if strings.Contains(string(data), "raw stderr") && strings.Contains(string(data), "raw stdout") {
+				// 重新初始化后恢复原来的 fd
+				NewLogger(WithLogFileDir(dir))
+				syscall.Write(2, []byte("after restore\n"))
+				os.Exit(0)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		os.Exit(1)
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCaptureStdio$")
+	cmd.Env = append(os.Environ(), "LOG_TEST_CAPTURE_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	for _, want := range []string{
+		`"level":"info"`, `"msg":"raw stdout","stream":"stdout"`,
+		`"level":"warn"`, `"msg":"raw stderr","stream":"stderr"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("missing %s in %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "after restore") {
+		t.Fatalf("stderr still captured after re-init: %s", data)
+	}
+	// 重定向期间仍原样输出到原来的 fd
+	for _, want := range []string{"raw stdout", "raw stderr", "after restore"} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("missing %s in output %s", want, out)
+		}
+	}
+}
+
+func TestReopenSignal(t *testing.T) {
+	dir := t.TempDir()
+	ch, cancel := Subscribe(zapcore.InfoLevel)
+	defer cancel()
+	NewLogger(WithLogFileDir(dir), WithReopen(0, syscall.SIGUSR1))
+	path := filepath.Join(dir, "app.log")
+	Info("before rotate")
+	Sync()
+	os.Rename(path, path+".1")
+
+	// 信号可能先于 signal.Notify 到达而被忽略，定期重发
+	resend := time.NewTicker(50 * time.Millisecond)
+	defer resend.Stop()
+	timeout := time.After(2 * time.Second)
+	for reopened := false; !reopened; {
+		syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		select {
+		case e := <-ch:
+			reopened = e.Message == "[Reopen] log files reopened"
+		case <-resend.C:
+		case <-timeout:
+			t.Fatal("log files not reopened")
+		}
+	}
+	Info("after rotate")
+	Sync()
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "after rotate") || strings.Contains(string(data), "before rotate") {
+		t.Fatalf("unexpected new file: %s", data)
+	}
+}
@@ -0,0 +1,90 @@
+// Package logtest 提供捕获日志输出并与 golden 文件比较的测试工具。
+package logtest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/gocpp/log"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var (
+	timeRe   = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?([+-]\d{2}:?\d{2}|Z)?`)
+	callerRe = regexp.MustCompile(`[\w.\-/]+\.go:\d+`)
+)
+
+// Recorder 捕获日志输出
+type Recorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *Recorder) Sync() error {
+	return nil
+}
+
+// String 返回归一化后的日志输出
+func (r *Recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Normalize(r.buf.String())
+}
+
+// Reset 清空已捕获的输出
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf.Reset()
+}
+
+// New 创建只输出到 Recorder 的全局 logger，opt 用于设置待测试的日志格式
+func New(t testing.TB, opt ...log.Option) (*zap.Logger, *Recorder) {
+	t.Helper()
+	rec := &Recorder{}
+	opts := append([]log.Option{log.WithLogFileDir(t.TempDir()), log.WithFile(false), log.WithSink(rec)}, opt...)
+	lg := log.NewLogger(opts...)
+	rec.Reset()
+	return lg, rec
+}
+
+// Normalize 将时间与调用位置替换为固定占位符，使输出可稳定比较
+func Normalize(s string) string {
+	s = timeRe.ReplaceAllString(s, "<TIME>")
+	return callerRe.ReplaceAllString(s, "<CALLER>")
+}
+
+// AssertGolden 比较捕获的输出与 golden 文件，使用 -update 参数运行测试时更新 golden 文件
+func (r *Recorder) AssertGolden(t testing.TB, path string) {
+	t.Helper()
+	got := r.String()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("log output does not match %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,14 @@
+package logtest
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGolden(t *testing.T) {
+	lg, rec := New(t)
+	lg.Info("order created", zap.String("order", "o1"), zap.Int("items", 3))
+	lg.Warn("stock low", zap.String("sku", "s1"))
+	rec.AssertGolden(t, "testdata/golden.log")
+}
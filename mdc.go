@@ -0,0 +1,71 @@
+package log
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// mdc 按 goroutine 保存 PushField 压入的字段组，只有所属 goroutine 会读写自己的字段组
+var (
+	mdc       sync.Map // goroutine ID -> *[][]zap.Field
+	mdcActive int32    // 持有字段的 goroutine 数，为 0 时写入无需解析 goroutine ID
+)
+
+func currentGoroutineID() int64 {
+	var buf [64]byte
+	return goroutineID(buf[:runtime.Stack(buf[:], false)])
+}
+
+// PushField 为当前 goroutine 压入一组字段，此后该 goroutine 记录的日志都会附带这些字段，直到对应的 PopField
+func PushField(fields ...zap.Field) {
+	id := currentGoroutineID()
+	v, ok := mdc.Load(id)
+	if !ok {
+		v = new([][]zap.Field)
+		mdc.Store(id, v)
+		atomic.AddInt32(&mdcActive, 1)
+	}
+	groups := v.(*[][]zap.Field)
+	*groups = append(*groups, fields)
+}
+
+// PopField 弹出当前 goroutine 最近一次 PushField 压入的字段
+func PopField() {
+	id := currentGoroutineID()
+	v, ok := mdc.Load(id)
+	if !ok {
+		return
+	}
+	groups := v.(*[][]zap.Field)
+	*groups = (*groups)[:len(*groups)-1]
+	if len(*groups) == 0 {
+		mdc.Delete(id)
+		atomic.AddInt32(&mdcActive, -1)
+	}
+}
+
+// ScopeFields 在 fn 执行期间为当前 goroutine 附带 fields
+func ScopeFields(fn func(), fields ...zap.Field) {
+	PushField(fields...)
+	defer PopField()
+	fn()
+}
+
+// mdcFields 作为 field provider 返回当前 goroutine 的字段
+func mdcFields() []zap.Field {
+	if atomic.LoadInt32(&mdcActive) == 0 {
+		return nil
+	}
+	v, ok := mdc.Load(currentGoroutineID())
+	if !ok {
+		return nil
+	}
+	var fields []zap.Field
+	for _, group := range *v.(*[][]zap.Field) {
+		fields = append(fields, group...)
+	}
+	return fields
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// MetricsHandler 以 Prometheus 文本格式输出 Stats 中的统计，包括各输出的写入延迟与日志大小直方图
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, Stats())
+	})
+}
+
+func writeMetrics(w io.Writer, c Counters) {
+	fmt.Fprintln(w, "# TYPE log_entries_total counter")
+	for _, level := range sortedKeys(c.Entries) {
+		fmt.Fprintf(w, "log_entries_total{level=%q} %d\n", level, c.Entries[level])
+	}
+	sinks := make([]string, 0, len(c.Sinks))
+	for name := range c.Sinks {
+		sinks = append(sinks, name)
+	}
+	sort.Strings(sinks)
+	for _, metric := range []struct {
+		name  string
+		value func(SinkCounters) uint64
+	}{
+		{"log_sink_writes_total", func(s SinkCounters) uint64 { return s.Writes }},
+		{"log_sink_bytes_total", func(s SinkCounters) uint64 { return s.Bytes }},
+		{"log_sink_errors_total", func(s SinkCounters) uint64 { return s.Errors }},
+	} {
+		fmt.Fprintf(w, "# TYPE %s counter\n", metric.name)
+		for _, name := range sinks {
+			fmt.Fprintf(w, "%s{sink=%q} %d\n", metric.name, name, metric.value(c.Sinks[name]))
+		}
+	}
+	fmt.Fprintln(w, "# TYPE log_sink_write_duration_seconds histogram")
+	for _, name := range sinks {
+		writeHistogram(w, "log_sink_write_duration_seconds", name, c.Sinks[name].Latency)
+	}
+	fmt.Fprintln(w, "# TYPE log_entry_size_bytes histogram")
+	for _, name := range sinks {
+		writeHistogram(w, "log_entry_size_bytes", name, c.Sinks[name].Sizes)
+	}
+	fmt.Fprintf(w, "# TYPE log_rotations_total counter\nlog_rotations_total %d\n", c.Rotations)
+	fmt.Fprintf(w, "# TYPE log_dropped_total counter\nlog_dropped_total %d\n", c.Dropped)
+}
+
+func writeHistogram(w io.Writer, metric, sink string, h Histogram) {
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		fmt.Fprintf(w, "%s_bucket{sink=%q,le=%q} %d\n", metric, sink, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{sink=%q,le=\"+Inf\"} %d\n", metric, sink, h.Count)
+	fmt.Fprintf(w, "%s_sum{sink=%q} %s\n", metric, sink, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{sink=%q} %d\n", metric, sink, h.Count)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,262 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelTree 命名 logger 的等级设置。名称以 "." 分隔层级，未设置等级的 logger 继承最近的上级，
+// 都未设置时使用全局等级。
+type levelTree struct {
+	mu        sync.RWMutex
+	levels    map[string]zapcore.Level
+	known     map[string]struct{}
+	min       zapcore.Level // levels 中的最低等级
+	overrides bool
+}
+
+var namedLevels = &levelTree{levels: map[string]zapcore.Level{}, known: map[string]struct{}{}}
+
+// effective 返回 name 的等级，ok 为 false 时表示使用全局等级
+func (t *levelTree) effective(name string) (zapcore.Level, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for name != "" {
+		if lvl, ok := t.levels[name]; ok {
+			return lvl, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return 0, false
+}
+
+// lowest 返回已设置的最低等级，没有设置时 ok 为 false
+func (t *levelTree) lowest() (zapcore.Level, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.min, t.overrides
+}
+
+func (t *levelTree) set(name string, lvl zapcore.Level, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ok {
+		t.levels[name] = lvl
+	} else {
+		delete(t.levels, name)
+	}
+	t.overrides = len(t.levels) > 0
+	t.min = zapcore.FatalLevel
+	for _, lvl := range t.levels {
+		if lvl < t.min {
+			t.min = lvl
+		}
+	}
+}
+
+// Named 返回名为 name 的 logger，如 "db.pool"。其等级可通过 SetLoggerLevel 单独设置，
+// 未设置时继承上级（"db"）的等级，都未设置时使用全局等级。
+func Named(name string) *zap.Logger {
+	namedLevels.mu.Lock()
+	namedLevels.known[name] = struct{}{}
+	namedLevels.mu.Unlock()
+	return current().Named(name)
+}
+
+// SetLoggerLevel 设置命名 logger name 及其未单独设置的下级的等级，可低于全局等级
+func SetLoggerLevel(name, level string) {
+	namedLevels.set(name, strToLevel(strings.ToLower(level)), true)
+}
+
+// ResetLoggerLevel 取消 name 的等级设置，恢复继承上级
+func ResetLoggerLevel(name string) {
+	namedLevels.set(name, 0, false)
+}
+
+// LoggerLevels 返回通过 Named 创建或设置过等级的 logger 的生效等级
+func LoggerLevels() map[string]string {
+	namedLevels.mu.RLock()
+	names := make([]string, 0, len(namedLevels.known)+len(namedLevels.levels))
+	for name := range namedLevels.known {
+		names = append(names, name)
+	}
+	for name := range namedLevels.levels {
+		names = append(names, name)
+	}
+	namedLevels.mu.RUnlock()
+	sort.Strings(names)
+
+	global := zapcore.InfoLevel
+	if l != nil {
+		global = l.zapConfig.Level.Level()
+	}
+	levels := make(map[string]string, len(names))
+	for _, name := range names {
+		lvl, ok := namedLevels.effective(name)
+		if !ok {
+			lvl = global
+		}
+		levels[name] = levelName(lvl)
+	}
+	return levels
+}
+
+// minLevel 返回任一 logger 可能启用的最低等级，各输出以此判断是否启用，
+// 按名称的等级判断由 levelGateCore 与 outputGateCore 完成
+func (l *Logger) minLevel() zapcore.Level {
+	lvl := l.zapConfig.Level.Level()
+	if min, ok := namedLevels.lowest(); ok && min < lvl {
+		lvl = min
+	}
+	if l.Opts.DebugOnDemand {
+		lvl = TraceLevel
+	}
+	if l.Opts.CaptureFileName != "" && lvl > zapcore.DebugLevel && !captureIDs.empty() {
+		lvl = zapcore.DebugLevel
+	}
+	return lvl
+}
+
+// sideLevel 判断 flight recorder、订阅者或采集名单是否需要 lvl 等级的日志，
+// 这些日志即使低于 logger 的生效等级也需进入 core 链
+func (l *Logger) sideLevel(lvl zapcore.Level) bool {
+	if l.recorder != nil && lvl < zapcore.WarnLevel {
+		return true
+	}
+	if subscribers.enabled(lvl) {
+		return true
+	}
+	return l.Opts.File && l.Opts.CaptureFileName != "" && lvl >= zapcore.DebugLevel && !captureIDs.empty()
+}
+
+// levelGateCore 位于 core 链最外层，在 Check 时按 logger 名称的生效等级过滤日志，
+// 被过滤的日志不进入处理器、异步队列等后续环节。按需调试的 logger 不受限制；
+// side 为 flight recorder、订阅者等旁路是否需要该等级的日志，这些日志进入 core 链后由 outputGateCore 挡在主输出之外。
+// DebugField 字段也在此按生效等级求值或去除。
+type levelGateCore struct {
+	zapcore.Core
+	global      zap.AtomicLevel
+	side        func(zapcore.Level) bool
+	debug       bool            // 是否为按需调试的 logger
+	debugFields []zapcore.Field // With 添加的 DebugField，写入时求值
+}
+
+func (c *levelGateCore) With(fields []zapcore.Field) zapcore.Core {
+	debugFields := c.debugFields
+	if hasDebugFields(fields) {
+		var plain []zapcore.Field
+		debugFields = debugFields[:len(debugFields):len(debugFields)]
+		for _, f := range fields {
+			if isDebugField(f) {
+				debugFields = append(debugFields, f)
+			} else {
+				plain = append(plain, f)
+			}
+		}
+		fields = plain
+	}
+	return &levelGateCore{Core: c.Core.With(fields), global: c.global, side: c.side, debug: c.debug || hasDebugMarker(fields), debugFields: debugFields}
+}
+
+// Enabled 按需调试的 logger 按各输出的最低等级判断；其余 logger 按全局与命名 logger 中最低的等级判断，
+// 不因 WithDebugOnDemand 放宽，使其低于生效等级的日志在 zap 的调用处即被丢弃
+func (c *levelGateCore) Enabled(lvl zapcore.Level) bool {
+	if !c.Core.Enabled(lvl) {
+		return false
+	}
+	if c.debug {
+		return true
+	}
+	floor := c.global.Level()
+	if min, ok := namedLevels.lowest(); ok && min < floor {
+		floor = min
+	}
+	return lvl >= floor || c.side != nil && c.side(lvl)
+}
+
+// level 返回名为 name 的 logger 的生效等级
+func (c *levelGateCore) level(name string) zapcore.Level {
+	return effectiveLevel(c.global, name)
+}
+
+func (c *levelGateCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if c.debug || ent.Level >= c.level(ent.LoggerName) || c.side != nil && c.side(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *levelGateCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if len(c.debugFields) > 0 || hasDebugFields(fields) {
+		all := make([]zapcore.Field, 0, len(c.debugFields)+len(fields))
+		all = append(all, c.debugFields...)
+		all = append(all, fields...)
+		fields = resolveDebugFields(all, c.debug || c.level(ent.LoggerName) <= zapcore.DebugLevel)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// verbose 判断 logger 中名为 name 的 logger 是否按 Debug 等级记录，按需调试的 logger 始终为 true。
+// 不使用 Core().Enabled，其结果会因 flight recorder、订阅者等旁路而放宽。
+func verbose(logger *zap.Logger, name string) bool {
+	if g, ok := logger.Core().(*levelGateCore); ok {
+		return g.debug || g.level(name) <= zapcore.DebugLevel
+	}
+	return logger.Core().Enabled(zapcore.DebugLevel)
+}
+
+// loggerName 返回 logger 的名称。zap 未提供读取名称的方法，从 Check 构造的 Entry 中读取，
+// Error 等级未开启时返回空字符串
+func loggerName(logger *zap.Logger) string {
+	if ce := logger.Check(zapcore.ErrorLevel, ""); ce != nil {
+		return ce.LoggerName
+	}
+	return ""
+}
+
+// effectiveLevel 返回名为 name 的 logger 的生效等级，未单独设置时为全局等级 global
+func effectiveLevel(global zap.AtomicLevel, name string) zapcore.Level {
+	lvl, ok := namedLevels.effective(name)
+	if !ok {
+		lvl = global.Level()
+	}
+	return lvl
+}
+
+// outputGateCore 位于异步队列等主输出环节之前，只放行按名称生效等级启用或来自按需调试 logger 的日志，
+// 挡住仅因旁路（flight recorder、订阅者、采集名单）需要而进入 core 链的日志。
+// 外层包装的 core 直接调用 Write，且 processorCore 不向内传递 With 的字段，因此在 Write 中按 fields 判断。
+type outputGateCore struct {
+	zapcore.Core
+	global zap.AtomicLevel
+	debug  bool
+}
+
+func (c *outputGateCore) With(fields []zapcore.Field) zapcore.Core {
+	return &outputGateCore{Core: c.Core.With(fields), global: c.global, debug: c.debug || hasDebugMarker(fields)}
+}
+
+func (c *outputGateCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *outputGateCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.debug || ent.Level >= effectiveLevel(c.global, ent.LoggerName) || hasDebugMarker(fields) {
+		return c.Core.Write(ent, fields)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var prettyPool = buffer.NewPool()
+
+// prettyEncoder 输出缩进的多行 JSON，供开发模式的控制台使用
+type prettyEncoder struct {
+	zapcore.Encoder
+}
+
+func (e prettyEncoder) Clone() zapcore.Encoder {
+	return prettyEncoder{e.Encoder.Clone()}
+}
+
+func (e prettyEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+	line := buf.Bytes()
+	body := bytes.TrimRight(line, "\r\n")
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		out := prettyPool.Get()
+		out.Write(line)
+		return out, nil
+	}
+	out := prettyPool.Get()
+	out.Write(indented.Bytes())
+	out.Write(line[len(body):])
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// subjectKey 数据主体字段名
+const subjectKey = "subject_id"
+
+// Subject 标记日志所属的数据主体（如用户 ID），带有该字段的日志可通过 Purge 删除
+func Subject(id string) zap.Field {
+	return zap.String(subjectKey, id)
+}
+
+// Purge 从普通日志文件、等级日志文件及其归档（含 gzip 压缩的归档）中删除 subject_id 为 subjectID 的日志，
+// 返回删除的条数。当前文件包含该主体时先被轮转，再与其它归档一样改写；启用 WithCompress 时等待轮转出的归档
+// 压缩完成后再改写。审计日志不受影响；启用 WithHMACChain 或 WithEncryption 时无法改写，返回错误。
+func Purge(subjectID string) (int, error) {
+	if l == nil || l.fileName == "" {
+		return 0, errors.New("log: no log file configured")
+	}
+	if len(l.Opts.HMACKey) > 0 || l.Opts.EncryptionKey != nil {
+		return 0, errors.New("log: purge is not supported with hmac chain or encryption")
+	}
+	Sync()
+	needle, _ := json.Marshal(subjectID)
+	removed := 0
+	for _, name := range l.filePaths() {
+		files, err := l.purgeFiles(name, needle, subjectID)
+		if err != nil {
+			return removed, err
+		}
+		for _, file := range files {
+			n, err := purgeFile(file.path, needle, subjectID)
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// purgeFiles 返回 name 需要改写的归档。当前文件包含该主体时先轮转，当前文件本身不在结果中，
+// 仍由 lumberjack 打开，不能原地改写
+func (l *Logger) purgeFiles(name string, needle []byte, subjectID string) ([]logFile, error) {
+	if _, n, err := readPurged(name, needle, subjectID); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if n > 0 {
+		for _, r := range l.rotators {
+			if r.lj.Filename == name {
+				if err := r.rotate(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	// lumberjack 在后台压缩归档：先写出 .gz 再删除原文件，改写尚未删除的原文件会被覆盖或删除
+	deadline := time.Now().Add(purgeCompressWait)
+	for {
+		var backups []logFile
+		compressing := false
+		for _, file := range logFiles(name) {
+			if file.rotated.IsZero() {
+				continue
+			}
+			if l.Opts.Compress && !strings.HasSuffix(file.path, ".gz") {
+				compressing = true
+			}
+			backups = append(backups, file)
+		}
+		if !compressing {
+			return backups, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("log: purge: compressing backups of %s did not finish in %v", name, purgeCompressWait)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// purgeCompressWait Purge 等待归档压缩完成的最长时间
+const purgeCompressWait = 10 * time.Second
+
+// readPurged 读取 path，返回删除 subject_id 为 subjectID 的行之后的内容与删除的条数
+func readPurged(path string, needle []byte, subjectID string) ([]byte, int, error) {
+	r, err := openLogFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	var kept bytes.Buffer
+	removed := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.Contains(line, needle) {
+			var m map[string]interface{}
+			if json.Unmarshal(line, &m) == nil && m[subjectKey] == subjectID {
+				removed++
+				continue
+			}
+		}
+		kept.Write(line)
+	}
+	return kept.Bytes(), removed, nil
+}
+
+// purgeFile 改写 path，删除 subject_id 为 subjectID 的行
+func purgeFile(path string, needle []byte, subjectID string) (int, error) {
+	kept, removed, err := readPurged(path, needle, subjectID)
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".purge-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	_, err = out.Write(kept)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(info.Mode())
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,137 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// backupTimeFormat lumberjack 归档文件名中的时间格式
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Query 日志查询条件
+type Query struct {
+	Since  time.Time              // 起始时间，为零值时不限制
+	Until  time.Time              // 结束时间，为零值时不限制
+	Level  string                 // 最低日志等级，为空时不限制
+	Fields map[string]interface{} // 字段需相等
+}
+
+func (q Query) match(e Entry, minLevel zapcore.Level) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Level != "" && e.Level < minLevel {
+		return false
+	}
+	for k, want := range q.Fields {
+		got, ok := e.Fields[k]
+		if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search 按时间顺序遍历当前日志文件及其归档（含 gzip 压缩的归档）中符合条件的日志，
+// fn 返回 false 时停止遍历。启用 WithShards 时按时间归并所有分片。
+func Search(q Query, fn func(Entry) bool) error {
+	if l == nil || l.fileName == "" {
+		return fmt.Errorf("log: no log file configured")
+	}
+	minLevel := strToLevel(strings.ToLower(q.Level))
+	if l.Opts.Shards > 1 {
+		return l.searchShards(q, minLevel, fn)
+	}
+	return l.searchFile(l.fileName, q, minLevel, fn)
+}
+
+func (l *Logger) searchFile(fileName string, q Query, minLevel zapcore.Level, fn func(Entry) bool) error {
+	for _, file := range logFiles(fileName) {
+		if !q.Since.IsZero() && !file.rotated.IsZero() && file.rotated.Before(q.Since) {
+			continue
+		}
+		stop := false
+		err := l.readFile(file.path, func(e Entry) bool {
+			if !q.Until.IsZero() && e.Time.After(q.Until) {
+				stop = true
+				return false
+			}
+			if q.match(e, minLevel) && !fn(e) {
+				stop = true
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+type logFile struct {
+	path    string
+	rotated time.Time // 归档时间，当前文件为零值
+}
+
+// logFiles 返回 fileName 的归档文件（由旧到新）及当前文件
+func logFiles(fileName string) []logFile {
+	dir := filepath.Dir(fileName)
+	ext := filepath.Ext(fileName)
+	prefix := strings.TrimSuffix(filepath.Base(fileName), ext) + "-"
+	entries, _ := os.ReadDir(dir)
+	var files []logFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		ts = strings.TrimPrefix(ts, prefix)
+		t, err := time.ParseInLocation(backupTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{path: filepath.Join(dir, name), rotated: t})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rotated.Before(files[j].rotated) })
+	if _, err := os.Stat(fileName); err == nil {
+		files = append(files, logFile{path: fileName})
+	}
+	return files
+}
+
+func (l *Logger) readFile(path string, fn func(Entry) bool) error {
+	r, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	d := NewDecoder(r)
+	d.cfg = l.zapConfig.EncoderConfig
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// flightRecorder 以环形缓冲保存最近的 Debug/Info 日志
+type flightRecorder struct {
+	mu      sync.Mutex
+	lines   [][]byte
+	written []bool // 该条日志是否已写入文件
+	next    int
+	size    int
+}
+
+func newFlightRecorder(n int) *flightRecorder {
+	return &flightRecorder{lines: make([][]byte, n), written: make([]bool, n)}
+}
+
+func (r *flightRecorder) add(line []byte, written bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = line
+	r.written[r.next] = written
+	r.next = (r.next + 1) % len(r.lines)
+	if r.size < len(r.lines) {
+		r.size++
+	}
+}
+
+// snapshot 按时间顺序返回缓冲中的日志，不清空缓冲
+func (r *flightRecorder) snapshot() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([][]byte, 0, r.size)
+	start := (r.next - r.size + len(r.lines)) % len(r.lines)
+	for i := 0; i < r.size; i++ {
+		out = append(out, r.lines[(start+i)%len(r.lines)])
+	}
+	return out
+}
+
+// drain 按时间顺序取出缓冲中的日志并清空，unwritten 为 true 时只返回未写入文件的日志
+func (r *flightRecorder) drain(unwritten bool) [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([][]byte, 0, r.size)
+	start := (r.next - r.size + len(r.lines)) % len(r.lines)
+	for i := 0; i < r.size; i++ {
+		idx := (start + i) % len(r.lines)
+		if !unwritten || !r.written[idx] {
+			out = append(out, r.lines[idx])
+		}
+		r.lines[idx] = nil
+	}
+	r.size = 0
+	return out
+}
+
+// recorderCore 在 Debug/Info 级别未开启时仍将其记录到 flightRecorder，
+// 出现 Error 及以上级别的日志时先将缓冲中的日志写入文件。
+type recorderCore struct {
+	zapcore.Core
+	rec *flightRecorder
+	enc zapcore.Encoder
+	out zapcore.WriteSyncer
+}
+
+func newRecorderCore(core zapcore.Core, rec *flightRecorder, enc zapcore.Encoder, out zapcore.WriteSyncer) *recorderCore {
+	enc = enc.Clone()
+	enc.AddBool("flight_recorder", true)
+	return &recorderCore{Core: core, rec: rec, enc: enc, out: out}
+}
+
+func (c *recorderCore) Enabled(lvl zapcore.Level) bool {
+	return lvl < zapcore.WarnLevel || c.Core.Enabled(lvl)
+}
+
+func (c *recorderCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &recorderCore{Core: c.Core.With(fields), rec: c.rec, enc: enc, out: c.out}
+}
+
+func (c *recorderCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *recorderCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	written := c.Core.Enabled(ent.Level)
+	if ent.Level < zapcore.WarnLevel {
+		if buf, err := c.enc.EncodeEntry(ent, fields); err == nil {
+			c.rec.add(append([]byte(nil), buf.Bytes()...), written)
+			buf.Free()
+		}
+	}
+	if ent.Level >= zapcore.ErrorLevel && c.out != nil {
+		for _, line := range c.rec.drain(true) {
+			c.out.Write(line)
+		}
+	}
+	if !written {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
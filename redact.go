@@ -0,0 +1,170 @@
+package log
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+// Redactor 对日志消息与字符串字段做脱敏
+type Redactor interface {
+	Redact(s string) string
+}
+
+// RedactorFunc 将普通函数适配为 Redactor
+type RedactorFunc func(s string) string
+
+func (f RedactorFunc) Redact(s string) string {
+	return f(s)
+}
+
+// Scrubber 使用正则替换日志消息与字符串字段中的敏感信息
+type Scrubber struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+var (
+	ScrubEmail       = Scrubber{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"}
+	ScrubCreditCard  = Scrubber{regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), "[CARD]"}
+	ScrubBearerToken = Scrubber{regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`), "Bearer " + redacted}
+	ScrubJWT         = Scrubber{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), "[JWT]"}
+
+	MaskPhone = MaskPartial(regexp.MustCompile(`(?:\+\d{1,3}[ -]?)?\b\d{3}[ -]?\d{3,4}[ -]?\d{4}\b`), 4)
+	MaskIBAN  = MaskPartial(regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`), 4)
+)
+
+func (s Scrubber) Redact(str string) string {
+	return s.Pattern.ReplaceAllString(str, s.Replacement)
+}
+
+// MaskPartial 将 pattern 匹配到的内容替换为 "****" 加上最后 keep 个字符，如 "****1234"
+func MaskPartial(pattern *regexp.Regexp, keep int) Redactor {
+	return RedactorFunc(func(s string) string {
+		return pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if keep <= 0 || keep >= len(m) {
+				return "****"
+			}
+			return "****" + m[len(m)-keep:]
+		})
+	})
+}
+
+// redactKeys 将字段名（不区分大小写）命中 keys 的值替换为 [REDACTED]，
+// 包括通过 zap.Any/zap.Object 添加的对象中嵌套的字段。
+func redactKeys(keys []string) Processor {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = struct{}{}
+	}
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		var out []zapcore.Field
+		for i, f := range fields {
+			nf, ok := redactField(set, f)
+			if !ok {
+				continue
+			}
+			if out == nil {
+				out = append([]zapcore.Field(nil), fields...)
+			}
+			out[i] = nf
+		}
+		if out == nil {
+			return ent, fields, true
+		}
+		return ent, out, true
+	}
+}
+
+func redactField(set map[string]struct{}, f zapcore.Field) (zapcore.Field, bool) {
+	if _, ok := set[strings.ToLower(f.Key)]; ok {
+		return zap.String(f.Key, redacted), true
+	}
+	switch f.Type {
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.ReflectType:
+		v, ok := fieldValue(f)
+		if !ok {
+			return f, false
+		}
+		if v, changed := redactValue(set, v); changed {
+			return zap.Any(f.Key, v), true
+		}
+	}
+	return f, false
+}
+
+// fieldValue 将字段转换为由 map[string]interface{}、[]interface{} 及基本类型组成的值
+func fieldValue(f zapcore.Field) (interface{}, bool) {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	b, err := json.Marshal(enc.Fields[f.Key])
+	if err != nil {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func redactValue(set map[string]struct{}, v interface{}) (interface{}, bool) {
+	changed := false
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if _, ok := set[strings.ToLower(k)]; ok {
+				val[k] = redacted
+				changed = true
+				continue
+			}
+			if item, ok := redactValue(set, item); ok {
+				val[k] = item
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			if item, ok := redactValue(set, item); ok {
+				val[i] = item
+				changed = true
+			}
+		}
+	}
+	return v, changed
+}
+
+func redact(redactors []Redactor) Processor {
+	apply := func(s string) string {
+		for _, r := range redactors {
+			s = r.Redact(s)
+		}
+		return s
+	}
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		ent.Message = apply(ent.Message)
+		var out []zapcore.Field
+		for i, f := range fields {
+			if f.Type != zapcore.StringType {
+				continue
+			}
+			s := apply(f.String)
+			if s == f.String {
+				continue
+			}
+			if out == nil {
+				out = append([]zapcore.Field(nil), fields...)
+			}
+			out[i].String = s
+		}
+		if out == nil {
+			return ent, fields, true
+		}
+		return ent, out, true
+	}
+}
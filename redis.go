@@ -0,0 +1,56 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RedisLogger 把 go-redis 自身的日志写入本包，满足 go-redis 的 internal.Logging，
+// 可通过 redis.SetLogger(log.RedisLogger{}) 使用。日志以 Warn 等级记录并带有 component=redis 字段。
+type RedisLogger struct{}
+
+func (RedisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	FromContext(ctx).WithOptions(zap.AddCallerSkip(1)).Warn(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"),
+		zap.String("component", "redis"))
+}
+
+// LogRedisCommand 记录一条 redis 命令及耗时，成功时为 Debug 等级，出错时为 Warn 等级；
+// AUTH 等命令的参数被隐藏。go-redis v9 的 ProcessHook 可以这样实现：
+//
+//	func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+//		return func(ctx context.Context, cmd redis.Cmder) error {
+//			start := time.Now()
+//			err := next(ctx, cmd)
+//			if err == redis.Nil {
+//				err = nil
+//			}
+//			log.LogRedisCommand(ctx, cmd.Name(), cmd.Args(), time.Since(start), err)
+//			return err
+//		}
+//	}
+func LogRedisCommand(ctx context.Context, name string, args []interface{}, d time.Duration, err error) {
+	logger := FromContext(ctx).WithOptions(zap.AddCallerSkip(1))
+	cmd := redisArgs(name, args)
+	if err != nil {
+		logger.Warn("redis", zap.String("cmd", cmd), zap.Duration("duration", d), zap.Error(err))
+		return
+	}
+	logger.Debug("redis", zap.String("cmd", cmd), zap.Duration("duration", d))
+}
+
+// redisArgs 拼接命令与参数，认证类命令只保留命令名
+func redisArgs(name string, args []interface{}) string {
+	switch strings.ToLower(name) {
+	case "auth", "hello", "migrate":
+		return name + " " + redacted
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = fmt.Sprint(a)
+	}
+	return strings.Join(parts, " ")
+}
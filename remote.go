@@ -0,0 +1,439 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// RetryPolicy 远端发送失败时的重试策略，按指数退避等待
+type RetryPolicy struct {
+	MaxAttempts int           // 含首次发送在内的最大尝试次数，小于等于 1 时不重试
+	Backoff     time.Duration // 首次重试前的等待时间
+	MaxBackoff  time.Duration // 等待时间上限，为 0 时不限制
+	Jitter      float64       // 等待时间的随机抖动比例，取值 0~1
+}
+
+// wait 返回第 attempt 次重试前的等待时间，attempt 从 1 开始
+func (p RetryPolicy) wait(attempt int) time.Duration {
+	d := p.Backoff << uint(attempt-1)
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d <= 0) {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// SinkOption 远端输出的配置
+type SinkOption func(*sinkOptions)
+
+type sinkOptions struct {
+	retry      RetryPolicy
+	deadLetter string        // 最终发送失败的日志写入的本地文件
+	walDir     string        // 预写日志目录，为空时不启用
+	walSegment int           // 单个预写日志分段的最大字节数
+	batchSize  int           // 每批最多条数
+	batchBytes int           // 每批最多字节数
+	linger     time.Duration // 未满一批时的最长等待
+
+	breakerFailures int           // 熔断器打开前的连续失败次数，为 0 时不启用熔断器
+	breakerCooldown time.Duration // 熔断器打开后多久发送探测批次
+
+	tls   *TLSConfig // HTTP 输出的 TLS 配置
+	proxy string     // HTTP 输出使用的代理
+
+	writeTimeout time.Duration // 单次发送的超时，为 0 时不限制
+	flushTimeout time.Duration // Sync 与 Close 等待发送完成的超时，为 0 时不限制
+
+	compression string                       // 按批压缩的算法，为空时不压缩
+	codec       func([]byte) ([]byte, error) // SinkCodec 设置的压缩函数
+}
+
+// SinkRetry 设置发送失败时的重试策略
+func SinkRetry(policy RetryPolicy) SinkOption {
+	return func(o *sinkOptions) {
+		o.retry = policy
+	}
+}
+
+// SinkDeadLetter 最终发送失败的批次写入本地文件 path，每行为 {"time","error","entry"}，可通过 Replay 重新发送
+func SinkDeadLetter(path string) SinkOption {
+	return func(o *sinkOptions) {
+		o.deadLetter = path
+	}
+}
+
+// SinkBatch 设置批次的最大条数、最大字节数与未满一批时的最长等待，参数为 0 时使用默认值
+// （500 条、1MB、1 秒）。批次越大吞吐越高，linger 越长日志送达的延迟越大。
+func SinkBatch(size, bytes int, linger time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		if size > 0 {
+			o.batchSize = size
+		}
+		if bytes > 0 {
+			o.batchBytes = bytes
+		}
+		if linger > 0 {
+			o.linger = linger
+		}
+	}
+}
+
+// SinkTimeout 设置单次发送的超时 write 与 Sync、Close 等待发送完成的超时 flush，为 0 时不限制。
+// write 超时通过 SendFunc 的 ctx 生效，发送超时按普通失败处理，进入重试与死信流程；flush 超时时 Sync 返回 ErrFlushTimeout，
+// 未完成的批次仍在后台继续发送，使卡住的连接不会无限阻塞 Sync 与退出流程。
+func SinkTimeout(write, flush time.Duration) SinkOption {
+	return func(o *sinkOptions) {
+		o.writeTimeout = write
+		o.flushTimeout = flush
+	}
+}
+
+// ErrFlushTimeout Sync 或 Close 等待发送完成超时
+var ErrFlushTimeout = errors.New("log: remote sink flush timed out")
+
+// ErrSinkClosed 远端输出已关闭
+var ErrSinkClosed = errors.New("log: remote sink closed")
+
+// SendFunc 将一批以换行分隔的日志发送到远端，ctx 结束时须尽快返回，否则 SinkTimeout 的 write 超时不起作用
+type SendFunc func(ctx context.Context, batch []byte) error
+
+// permanentError 不可重试的发送错误
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent 包装不可重试的错误，SendFunc 返回后不再重试该批日志
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// RemoteSink 将日志按批发送到远端的输出，通过 WithSink 使用。发送在后台进行，
+// 失败的批次按 SinkRetry 重试，最终失败的错误由下一次 Write 或 Sync 返回。
+type RemoteSink struct {
+	opts    sinkOptions
+	send    SendFunc
+	probe   func(ctx context.Context) error
+	closeFn func() error // 后台发送停止后调用，释放连接等资源
+
+	mu      sync.Mutex
+	buf     []byte
+	count   int
+	err     error // 尚未报告的发送错误
+	batches chan remoteBatch
+	replays chan replayRequest
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	wal     *walWriter
+	pending []string // 发送失败、等待重试的预写日志分段，仅由后台 goroutine 访问
+	breaker *breaker
+}
+
+type remoteBatch struct {
+	data    []byte
+	segment string        // 批次对应的预写日志分段，发送成功后删除
+	done    chan struct{} // 非空时在此前的批次发送完成后关闭
+}
+
+// NewRemoteSink 返回使用 send 发送日志的远端输出，可用于对象存储等自定义的远端
+func NewRemoteSink(send SendFunc, opts ...SinkOption) *RemoteSink {
+	s := &RemoteSink{
+		opts: sinkOptions{
+			retry:      RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second, Jitter: 0.2},
+			batchSize:  500,
+			batchBytes: 1 << 20,
+			linger:     time.Second,
+		},
+		send:    send,
+		batches: make(chan remoteBatch, 4),
+		replays: make(chan replayRequest),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	if s.opts.breakerFailures > 0 {
+		s.breaker = &breaker{failures: s.opts.breakerFailures, cooldown: s.opts.breakerCooldown}
+	}
+	if s.opts.walDir != "" {
+		s.wal = &walWriter{dir: s.opts.walDir, max: s.opts.walSegment}
+		s.pending = s.wal.segments()
+	}
+	go s.loop()
+	return s
+}
+
+func (s *RemoteSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	var err error
+	if s.wal != nil {
+		err = s.wal.write(p)
+	}
+	s.buf = append(s.buf, p...)
+	s.count++
+	var full remoteBatch
+	if s.count >= s.opts.batchSize || len(s.buf) >= s.opts.batchBytes || s.wal != nil && s.wal.full() {
+		full = s.takeLocked()
+	}
+	if err == nil {
+		err = s.err
+		s.err = nil
+	}
+	s.mu.Unlock()
+	if full.data != nil {
+		s.enqueue(full)
+	}
+	return len(p), err
+}
+
+// takeLocked 取出当前缓冲并封存对应的预写日志分段，调用方需持有 mu
+func (s *RemoteSink) takeLocked() remoteBatch {
+	b := remoteBatch{data: s.buf}
+	if s.wal != nil {
+		b.segment = s.wal.seal()
+	}
+	s.buf, s.count = nil, 0
+	return b
+}
+
+// enqueue 将批次交给后台发送，后台已停止时直接完成
+func (s *RemoteSink) enqueue(b remoteBatch) {
+	select {
+	case s.batches <- b:
+	case <-s.stopped:
+		if b.done != nil {
+			close(b.done)
+		}
+	}
+}
+
+// Sync 发送缓冲中的日志并等待此前的批次发送完成，返回尚未报告的发送错误
+func (s *RemoteSink) Sync() error {
+	done := make(chan struct{})
+	s.mu.Lock()
+	b := s.takeLocked()
+	s.mu.Unlock()
+	b.done = done
+	if !s.wait(func() { s.enqueue(b); <-done }) {
+		return ErrFlushTimeout
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// Close 发送剩余的日志后停止后台发送
+func (s *RemoteSink) Close() error {
+	err := s.Sync()
+	s.once.Do(func() {
+		close(s.stop)
+		if !s.wait(func() { <-s.stopped }) && err == nil {
+			err = ErrFlushTimeout
+		}
+		if s.closeFn != nil {
+			if cerr := s.closeFn(); err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// wait 执行 fn 直到返回，设置了 SinkTimeout 的 flush 超时时最多等待该时长，超时返回 false
+func (s *RemoteSink) wait(fn func()) bool {
+	if s.opts.flushTimeout <= 0 {
+		fn()
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	timer := time.NewTimer(s.opts.flushTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Probe 探测远端的连通性，供 HealthCheck 使用
+func (s *RemoteSink) Probe(ctx context.Context) error {
+	if s.probe == nil {
+		return nil
+	}
+	return s.probe(ctx)
+}
+
+func (s *RemoteSink) loop() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.opts.linger)
+	defer ticker.Stop()
+	s.retryPending()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case b := <-s.batches:
+			if len(b.data) > 0 {
+				s.deliver(b)
+			}
+			if b.done != nil {
+				close(b.done)
+			}
+		case req := <-s.replays:
+			req.errCh <- s.replay(req)
+		case <-ticker.C:
+			s.retryPending()
+			s.mu.Lock()
+			b := s.takeLocked()
+			s.mu.Unlock()
+			if len(b.data) > 0 {
+				s.deliver(b)
+			}
+		}
+	}
+}
+
+// deliver 按重试策略发送一批日志，最终失败时写入死信；未配置死信时保留预写日志分段稍后重试
+func (s *RemoteSink) deliver(b remoteBatch) {
+	err := s.sendWithRetry(b.data)
+	if err == nil {
+		if b.segment != "" {
+			os.Remove(b.segment)
+		}
+		return
+	}
+	if s.opts.deadLetter != "" {
+		if dlErr := writeDeadLetter(s.opts.deadLetter, b.data, err); dlErr != nil {
+			err = fmt.Errorf("%v; dead letter: %v", err, dlErr)
+		} else if b.segment != "" {
+			os.Remove(b.segment)
+		}
+	} else if b.segment != "" {
+		s.pending = append(s.pending, b.segment)
+	}
+	s.mu.Lock()
+	s.err = fmt.Errorf("log: remote sink: %w", err)
+	s.mu.Unlock()
+}
+
+// sendWithRetry 按重试策略发送一批日志，熔断器打开时不发送并返回 ErrCircuitOpen
+func (s *RemoteSink) sendWithRetry(batch []byte) error {
+	ok, probe := s.breaker.allow()
+	if !ok {
+		return ErrCircuitOpen
+	}
+	batch, err := s.opts.compress(batch)
+	if err != nil {
+		return Permanent(err)
+	}
+	for attempt := 1; ; attempt++ {
+		err = s.sendOnce(batch)
+		var perm permanentError
+		permanent := errors.As(err, &perm)
+		// 不可重试的错误说明远端可达，不计入熔断器的失败
+		open := s.breaker.record(err == nil || permanent)
+		if err == nil {
+			return nil
+		}
+		if permanent || attempt >= s.opts.retry.MaxAttempts || probe || open {
+			return err
+		}
+		timer := time.NewTimer(s.opts.retry.wait(attempt))
+		select {
+		case <-timer.C:
+		case <-s.stop:
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// sendOnce 发送一次，设置了 SinkTimeout 的 write 超时时 ctx 带有截止时间。
+// 发送总是等待 SendFunc 返回，不会与下一次发送并发
+func (s *RemoteSink) sendOnce(batch []byte) error {
+	d := s.opts.writeTimeout
+	if d <= 0 {
+		return s.send(context.Background(), batch)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	err := s.send(ctx, batch)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("send timed out after %v: %w", d, ctx.Err())
+	}
+	return err
+}
+
+// NewHTTPSink 返回以 POST 方式将日志按批（NDJSON）发送到 url 的远端输出。
+// 响应 5xx、408 与 429 时重试，其余非 2xx 响应不重试。可通过 SinkTLS、SinkProxy 设置 TLS 与代理，
+// 证书等配置有误时每次发送与 Probe 都返回该错误。
+func NewHTTPSink(url string, opts ...SinkOption) *RemoteSink {
+	var o sinkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	client, clientErr := o.httpClient()
+	s := NewRemoteSink(func(ctx context.Context, batch []byte) error {
+		if clientErr != nil {
+			return Permanent(clientErr)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(batch))
+		if err != nil {
+			return Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if o.compression != "" {
+			req.Header.Set("Content-Encoding", o.compression)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		err = fmt.Errorf("%s: %s", url, resp.Status)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests {
+			return err
+		}
+		return Permanent(err)
+	}, opts...)
+	// 任何响应都说明远端可达
+	s.probe = func(ctx context.Context) error {
+		if clientErr != nil {
+			return clientErr
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func (l *Logger) reopenLoop() {
+	ch := make(chan os.Signal, 1)
+	if len(l.Opts.ReopenSignals) > 0 {
+		signal.Notify(ch, l.Opts.ReopenSignals...)
+		defer signal.Stop(ch)
+	}
+	var tick <-chan time.Time
+	if l.Opts.ReopenCheck > 0 {
+		ticker := l.Opts.Clock.NewTicker(l.Opts.ReopenCheck)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	files := map[string]os.FileInfo{}
+	for {
+		select {
+		case <-l.done:
+			return
+		case sig := <-ch:
+			l.reopen(func(string) bool { return true })
+			Info("[Reopen] log files reopened", zap.String("signal", sig.String()))
+		case <-tick:
+			// 文件不存在或 inode 变化说明已被外部移走，lumberjack 自身轮转后重新打开也无害
+			l.reopen(func(name string) bool {
+				fi, err := os.Stat(name)
+				prev := files[name]
+				files[name] = fi
+				if err != nil {
+					return os.IsNotExist(err)
+				}
+				return prev != nil && !os.SameFile(prev, fi)
+			})
+		}
+	}
+}
+
+// reopenTarget 可重新打开的日志文件
+type reopenTarget struct {
+	name   string
+	reopen func() error
+}
+
+// reopenTargets 返回 logger 写入的全部日志文件：普通与等级日志、审计、访问、事件、捕获及已创建的租户日志
+func (l *Logger) reopenTargets() []reopenTarget {
+	var targets []reopenTarget
+	for _, r := range l.rotators {
+		targets = append(targets, reopenTarget{r.lj.Filename, r.reopen})
+	}
+	for _, r := range l.auxFiles {
+		targets = append(targets, reopenTarget{r.lj.Filename, r.reopen})
+	}
+	l.tenantsMu.Lock()
+	for _, t := range l.tenants {
+		targets = append(targets, reopenTarget{t.rc.lj.Filename, t.rc.reopen})
+	}
+	l.tenantsMu.Unlock()
+	return targets
+}
+
+// reopen 写出缓冲后重新打开 need 返回 true 的日志文件
+func (l *Logger) reopen(need func(name string) bool) {
+	synced := false
+	for _, t := range l.reopenTargets() {
+		if !need(t.name) {
+			continue
+		}
+		if !synced {
+			l.Logger.Sync()
+			synced = true
+		}
+		t.reopen()
+	}
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RequiredMode 日志缺少必填字段时的处理方式
+type RequiredMode int
+
+const (
+	RequiredFlag   RequiredMode = iota // 照常写入，并通过 missing_fields 标出缺失的字段
+	RequiredReject                     // 丢弃该条日志，开发模式下退化为 RequiredFlag 以便发现问题
+)
+
+// requiredFields 检查 With、field provider 及本条日志的字段中是否包含 keys
+func requiredFields(keys []string, mode RequiredMode) Processor {
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		var missing []string
+		for _, key := range keys {
+			found := false
+			for _, f := range fields {
+				if f.Key == key {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) == 0 {
+			return ent, fields, true
+		}
+		if mode == RequiredReject {
+			return ent, fields, false
+		}
+		return ent, append(fields, zap.Strings("missing_fields", missing)), true
+	}
+}
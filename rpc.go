@@ -0,0 +1,124 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RPCCall 一次 RPC 调用的信息，由 gRPC 拦截器填写后交给 LogServerRPC 或 LogClientRPC 记录
+type RPCCall struct {
+	Method   string        // 完整方法名，如 /pkg.Service/Method
+	Peer     string        // 对端地址，客户端为目标地址
+	Attempt  int           // 客户端的第几次尝试，从 1 开始，为 0 时不记录
+	Code     string        // 状态码名称，如 OK、Unavailable
+	Duration time.Duration // 调用耗时
+	Stream   bool          // 是否为流式调用
+	Request  interface{}   // 请求，仅在 Debug 等级时记录
+	Response interface{}   // 响应，仅在 Debug 等级时记录
+	Err      error
+}
+
+// LogServerRPC 通过 ctx 中的 logger 记录一次服务端 RPC 调用。Internal、Unavailable 等服务端错误
+// 记为 Error，其余非 OK 状态记为 Warn；请求与响应仅在 Debug 等级时以 JSON 记录，
+// 超过 WithRPCPayloadLimit 的部分被截断。gRPC 的一元拦截器可以这样实现：
+//
+//	func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+//		start := time.Now()
+//		resp, err := handler(ctx, req)
+//		call := log.RPCCall{Method: info.FullMethod, Code: status.Code(err).String(),
+//			Duration: time.Since(start), Request: req, Response: resp, Err: err}
+//		if p, ok := peer.FromContext(ctx); ok {
+//			call.Peer = p.Addr.String()
+//		}
+//		log.LogServerRPC(ctx, call)
+//		return resp, err
+//	}
+func LogServerRPC(ctx context.Context, call RPCCall) {
+	logRPC(FromContext(ctx), "grpc server", call)
+}
+
+// LogClientRPC 通过 ctx 中的 logger 记录一次客户端 RPC 尝试，等级与负载的处理与 LogServerRPC 一致。
+// 带重试的调用每次尝试记录一条并填写 Attempt。gRPC 的一元拦截器可以这样实现：
+//
+//	func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+//		start := time.Now()
+//		err := invoker(ctx, method, req, reply, cc, opts...)
+//		log.LogClientRPC(ctx, log.RPCCall{Method: method, Peer: cc.Target(), Code: status.Code(err).String(),
+//			Duration: time.Since(start), Attempt: 1, Request: req, Response: reply, Err: err})
+//		return err
+//	}
+func LogClientRPC(ctx context.Context, call RPCCall) {
+	logRPC(FromContext(ctx), "grpc client", call)
+}
+
+func logRPC(logger *zap.Logger, msg string, call RPCCall) {
+	level := rpcLevel(call.Code)
+	if call.Code == "" && call.Err != nil {
+		level = zapcore.ErrorLevel
+	}
+	ce := logger.WithOptions(zap.AddCallerSkip(2)).Check(level, msg)
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("method", call.Method),
+		zap.String("peer", call.Peer),
+		zap.String("status", call.Code),
+		zap.Duration("duration", call.Duration),
+	}
+	if call.Attempt > 0 {
+		fields = append(fields, zap.Int("attempt", call.Attempt))
+	}
+	if call.Stream {
+		fields = append(fields, zap.Bool("stream", true))
+	}
+	if call.Err != nil {
+		fields = append(fields, zap.Error(call.Err))
+	}
+	if verbose(logger, ce.LoggerName) {
+		fields = append(fields, rpcPayload("request", call.Request)...)
+		fields = append(fields, rpcPayload("response", call.Response)...)
+	}
+	ce.Write(fields...)
+}
+
+// rpcLevel 按 gRPC 状态码返回日志等级
+func rpcLevel(code string) zapcore.Level {
+	switch code {
+	case "", "OK":
+		return zapcore.InfoLevel
+	case "Unknown", "DeadlineExceeded", "Unimplemented", "Internal", "Unavailable", "DataLoss":
+		return zapcore.ErrorLevel
+	}
+	return zapcore.WarnLevel
+}
+
+// rpcPayload 以 JSON 记录请求或响应，超过 WithRPCPayloadLimit 时截断
+func rpcPayload(key string, v interface{}) []zap.Field {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return []zap.Field{zap.String(key+"_error", err.Error())}
+	}
+	limit := 4096
+	if l != nil && l.Opts.RPCPayloadLimit != 0 {
+		limit = l.Opts.RPCPayloadLimit
+	}
+	if limit < 0 {
+		return nil
+	}
+	if len(b) > limit {
+		return []zap.Field{
+			zap.ByteString(key, b[:limit]),
+			zap.Int(key+"_size", len(b)),
+			zap.Bool(key+"_truncated", true),
+		}
+	}
+	return []zap.Field{zap.Reflect(key, json.RawMessage(b))}
+}
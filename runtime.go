@@ -0,0 +1,54 @@
+package log
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runtimeStats 定期写入一条 [runtime] 日志，包含堆大小、GC 停顿、goroutine 数与打开的文件描述符数
+func (l *Logger) runtimeStats() {
+	ticker := l.Opts.Clock.NewTicker(l.Opts.RuntimeStats)
+	defer ticker.Stop()
+	var lastGC uint32
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.Logger.Info("[runtime]", runtimeFields(&lastGC)...)
+		}
+	}
+}
+
+// runtimeFields 返回运行时统计字段，lastGC 为上次统计时的 GC 次数，用于计算期间的 GC 次数与最大停顿
+func runtimeFields(lastGC *uint32) []zap.Field {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	delta := m.NumGC - *lastGC
+	*lastGC = m.NumGC
+	// PauseNs 只保留最近 256 次 GC 的停顿
+	var maxPause uint64
+	for i := uint32(0); i < delta && i < uint32(len(m.PauseNs)); i++ {
+		if p := m.PauseNs[(m.NumGC-i+255)%256]; p > maxPause {
+			maxPause = p
+		}
+	}
+	fields := []zap.Field{
+		zap.Uint64("heap_alloc", m.HeapAlloc),
+		zap.Uint64("heap_sys", m.HeapSys),
+		zap.Uint64("heap_objects", m.HeapObjects),
+		zap.Uint32("gc_count", m.NumGC),
+		zap.Uint32("gc_delta", delta),
+		zap.Duration("gc_pause_max", time.Duration(maxPause)),
+		zap.Duration("gc_pause_total", time.Duration(m.PauseTotalNs)),
+		zap.Int("goroutines", runtime.NumGoroutine()),
+	}
+	// 仅在提供 /proc 的系统上统计文件描述符
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		fields = append(fields, zap.Int("open_fds", len(fds)))
+	}
+	return fields
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ShadowCounters 影子输出的统计
+type ShadowCounters struct {
+	Entries    uint64 // 写入影子输出的条数
+	Diffs      uint64 // 与主输出不一致的条数
+	Errors     uint64 // 影子编码或写入失败的次数
+	LastDiff   string // 最近一条不一致的影子输出
+	LastSource string // LastDiff 对应的主输出
+}
+
+type shadowStats struct {
+	entries, diffs, errors uint64
+	mu                     sync.Mutex
+	lastDiff, lastSource   string
+}
+
+// shadowCore 将每条日志同时以影子编码器写入影子输出，并与主编码器的结果比较
+type shadowCore struct {
+	zapcore.Core
+	primary zapcore.Encoder
+	shadow  zapcore.Encoder
+	ws      zapcore.WriteSyncer
+	compare func(primary, shadow []byte) bool
+	stats   *shadowStats
+}
+
+func (c *shadowCore) With(fields []zapcore.Field) zapcore.Core {
+	primary, shadow := c.primary.Clone(), c.shadow.Clone()
+	for _, f := range fields {
+		f.AddTo(primary)
+		f.AddTo(shadow)
+	}
+	return &shadowCore{Core: c.Core.With(fields), primary: primary, shadow: shadow, ws: c.ws, compare: c.compare, stats: c.stats}
+}
+
+func (c *shadowCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write 影子输出的错误只计入统计，不影响主输出
+func (c *shadowCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.writeShadow(ent, fields)
+	return c.Core.Write(ent, fields)
+}
+
+func (c *shadowCore) writeShadow(ent zapcore.Entry, fields []zapcore.Field) {
+	s, err := c.shadow.EncodeEntry(ent, fields)
+	if err != nil {
+		atomic.AddUint64(&c.stats.errors, 1)
+		return
+	}
+	defer s.Free()
+	atomic.AddUint64(&c.stats.entries, 1)
+	if _, err := c.ws.Write(s.Bytes()); err != nil {
+		atomic.AddUint64(&c.stats.errors, 1)
+	}
+	p, err := c.primary.EncodeEntry(ent, fields)
+	if err != nil {
+		return
+	}
+	defer p.Free()
+	if c.compare(p.Bytes(), s.Bytes()) {
+		return
+	}
+	atomic.AddUint64(&c.stats.diffs, 1)
+	c.stats.mu.Lock()
+	c.stats.lastDiff = string(bytes.TrimSpace(s.Bytes()))
+	c.stats.lastSource = string(bytes.TrimSpace(p.Bytes()))
+	c.stats.mu.Unlock()
+}
+
+func (c *shadowCore) Sync() error {
+	c.ws.Sync()
+	return c.Core.Sync()
+}
+
+// JSONEqual 以 JSON 语义比较两条日志，忽略字段顺序与空白，不是 JSON 时按字节比较。
+// 是 WithShadow 的默认比较方式。
+func JSONEqual(primary, shadow []byte) bool {
+	var p, s interface{}
+	if json.Unmarshal(primary, &p) != nil || json.Unmarshal(shadow, &s) != nil {
+		return bytes.Equal(bytes.TrimSpace(primary), bytes.TrimSpace(shadow))
+	}
+	return reflect.DeepEqual(p, s)
+}
+
+// shadowCounters 返回影子输出的统计，未启用 WithShadow 时为零值
+func (l *Logger) shadowCounters() ShadowCounters {
+	if l.shadow == nil {
+		return ShadowCounters{}
+	}
+	s := l.shadow
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ShadowCounters{
+		Entries:    atomic.LoadUint64(&s.entries),
+		Diffs:      atomic.LoadUint64(&s.diffs),
+		Errors:     atomic.LoadUint64(&s.errors),
+		LastDiff:   s.lastDiff,
+		LastSource: s.lastSource,
+	}
+}
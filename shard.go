@@ -0,0 +1,106 @@
+package log
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// shardFileName 返回第 i 个分片的文件名，如 app.log 的分片为 app-0.log、app-1.log
+func shardFileName(fileName string, i int) string {
+	ext := filepath.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "-" + strconv.Itoa(i) + ext
+}
+
+// shardedWriteSyncer 将每条日志轮流写入其中一个分片，各分片独立加锁与切割
+type shardedWriteSyncer struct {
+	next   uint32
+	shards []zapcore.WriteSyncer
+}
+
+func (s *shardedWriteSyncer) Write(p []byte) (int, error) {
+	i := atomic.AddUint32(&s.next, 1) % uint32(len(s.shards))
+	return s.shards[i].Write(p)
+}
+
+func (s *shardedWriteSyncer) Sync() error {
+	var err error
+	for _, ws := range s.shards {
+		if e := ws.Sync(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (l *Logger) shardedWriter(fileName string) zapcore.WriteSyncer {
+	s := &shardedWriteSyncer{}
+	for i := 0; i < l.Opts.Shards; i++ {
+		name := shardFileName(fileName, i)
+		s.shards = append(s.shards, l.mainWriter(name))
+	}
+	return s
+}
+
+// searchShards 读取所有分片并按时间归并，保证 fn 仍按时间顺序收到日志
+func (l *Logger) searchShards(q Query, minLevel zapcore.Level, fn func(Entry) bool) error {
+	n := l.Opts.Shards
+	done := make(chan struct{})
+	chans := make([]chan Entry, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		chans[i] = make(chan Entry, 64)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(chans[i])
+			errs[i] = l.searchFile(shardFileName(l.fileName, i), q, minLevel, func(e Entry) bool {
+				select {
+				case chans[i] <- e:
+					return true
+				case <-done:
+					return false
+				}
+			})
+		}(i)
+	}
+
+	heads := make([]*Entry, n)
+	for i, ch := range chans {
+		if e, ok := <-ch; ok {
+			heads[i] = &e
+		}
+	}
+	for {
+		min := -1
+		for i, e := range heads {
+			if e != nil && (min < 0 || e.Time.Before(heads[min].Time)) {
+				min = i
+			}
+		}
+		if min < 0 {
+			break
+		}
+		if !fn(*heads[min]) {
+			break
+		}
+		if e, ok := <-chans[min]; ok {
+			heads[min] = &e
+		} else {
+			heads[min] = nil
+		}
+	}
+	close(done)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
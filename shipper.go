@@ -0,0 +1,160 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// shipper 跟踪一个日志文件的读取位置，把新写入的完整行转发到远端。
+// 文件被轮转时先读完被重命名的旧文件，再从新文件开头读取。
+type shipper struct {
+	path   string
+	state  string // 保存读取位置的文件，重启后从该位置继续
+	w      io.Writer
+	file   os.FileInfo // 正在读取的文件
+	offset int64
+}
+
+func newShipper(path string, w io.Writer) *shipper {
+	s := &shipper{path: path, state: path + ".offset", w: w}
+	if b, err := os.ReadFile(s.state); err == nil {
+		s.offset, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+	return s
+}
+
+func (s *shipper) poll() error {
+	fi, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.file != nil && !os.SameFile(s.file, fi) {
+		if old := s.rotated(); old != "" {
+			if err := s.ship(old); err != nil {
+				return err
+			}
+		}
+		s.offset = 0
+	} else if fi.Size() < s.offset {
+		// 文件被截断或在重启期间被轮转
+		s.offset = 0
+	}
+	s.file = fi
+	if err := s.ship(s.path); err != nil {
+		return err
+	}
+	return os.WriteFile(s.state, []byte(strconv.FormatInt(s.offset, 10)), 0644)
+}
+
+// rotated 在轮转后的备份文件中查找此前正在读取的文件。开启 Compress 时备份压缩为新文件，
+// 找不到同一文件时取最新的备份，该备份已压缩时返回其 .gz 文件
+func (s *shipper) rotated() string {
+	var newest string
+	for _, f := range logFiles(s.path) {
+		if f.rotated.IsZero() {
+			continue
+		}
+		if fi, err := os.Stat(f.path); err == nil && os.SameFile(s.file, fi) {
+			return f.path
+		}
+		newest = f.path
+	}
+	if strings.HasSuffix(newest, ".gz") {
+		return newest
+	}
+	return ""
+}
+
+// shipChunk 每次读取的字节数，转发以完整行为单位，单次转发不超过 shipChunk 加上一行的长度
+const shipChunk = 256 * 1024
+
+// ship 从当前位置读取文件，转发其中的完整行，.gz 文件解压后读取
+func (s *shipper) ship(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		if _, err := io.CopyN(io.Discard, gz, s.offset); err != nil {
+			if err == io.EOF {
+				// 解压后比已读位置短，不是此前读取的文件
+				return nil
+			}
+			return err
+		}
+		r = gz
+	} else if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, shipChunk)
+	var pending []byte
+	for {
+		n, err := io.ReadFull(r, chunk)
+		pending = append(pending, chunk[:n]...)
+		if i := bytes.LastIndexByte(pending, '\n') + 1; i > 0 {
+			if _, err := s.w.Write(pending[:i]); err != nil {
+				return err
+			}
+			s.offset += int64(i)
+			pending = append(pending[:0], pending[i:]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (l *Logger) shipLoop() {
+	paths := l.filePaths()
+	shippers := make([]*shipper, len(paths))
+	for i, path := range paths {
+		shippers[i] = newShipper(path, l.Opts.Shipper)
+	}
+	poll := func() {
+		for _, s := range shippers {
+			if err := s.poll(); err != nil {
+				l.Logger.Warn("[shipper] ship failed", zap.String("file", s.path), zap.Error(err))
+			}
+		}
+	}
+	interval := l.Opts.ShipInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := l.Opts.Clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			poll()
+			if ws, ok := l.Opts.Shipper.(zapcore.WriteSyncer); ok {
+				ws.Sync()
+			}
+			return
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// CompressGzip 使用 gzip 压缩发送的批次
+const CompressGzip = "gzip"
+
+// SinkCompression 设置远端输出按批压缩的算法，内置 CompressGzip，其它算法通过 SinkCodec 设置，为空时不压缩。
+// HTTP 输出同时设置 Content-Encoding 请求头；NewRemoteSink 的 SendFunc 收到压缩后的批次；
+// NewStreamSink 按条发送，不使用该设置，可改用 gRPC 自身的压缩，如 grpc.UseCompressor(gzip.Name)。
+// 死信文件中记录的是压缩前的日志。
+func SinkCompression(codec string) SinkOption {
+	return func(o *sinkOptions) {
+		o.compression = codec
+		o.codec = nil
+	}
+}
+
+// SinkCodec 使用 fn 压缩发送的批次，name 为算法名，HTTP 输出以其作为 Content-Encoding，
+// 用于接入 snappy、zstd 等未内置的算法，其余与 SinkCompression 相同：
+//
+//	log.SinkCodec("zstd", func(b []byte) ([]byte, error) { return enc.EncodeAll(b, nil), nil })
+func SinkCodec(name string, fn func([]byte) ([]byte, error)) SinkOption {
+	return func(o *sinkOptions) {
+		o.compression = name
+		o.codec = fn
+	}
+}
+
+// compress 按设置的算法压缩一批日志
+func (o *sinkOptions) compress(batch []byte) ([]byte, error) {
+	if o.codec != nil {
+		return o.codec(batch)
+	}
+	switch codec := o.compression; codec {
+	case "":
+		return batch, nil
+	case CompressGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(batch); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("log: unsupported sink compression %q", codec)
+	}
+}
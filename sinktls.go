@@ -0,0 +1,78 @@
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// TLSConfig 远端输出的 TLS 配置
+type TLSConfig struct {
+	CAFile             string // 校验服务端证书的 CA 证书（PEM），为空时使用系统 CA
+	CertFile           string // 双向 TLS 的客户端证书（PEM）
+	KeyFile            string // 双向 TLS 的客户端私钥（PEM）
+	ServerName         string // 校验证书时使用的服务端名称，为空时取自地址
+	InsecureSkipVerify bool   // 不校验服务端证书，仅用于测试
+}
+
+// Config 返回对应的 *tls.Config，也可用于 NewStreamSink 等自行建立连接的输出，
+// 如 grpc.WithTransportCredentials(credentials.NewTLS(cfg))
+func (c TLSConfig) Config() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: c.ServerName, InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("log: no certificates in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// SinkTLS 设置远端输出的 TLS 配置，支持自定义 CA 与双向 TLS
+func SinkTLS(cfg TLSConfig) SinkOption {
+	return func(o *sinkOptions) {
+		o.tls = &cfg
+	}
+}
+
+// SinkProxy 设置远端输出使用的 HTTP 代理，如 http://proxy.corp:3128，未设置时使用 HTTP_PROXY 等环境变量
+func SinkProxy(proxyURL string) SinkOption {
+	return func(o *sinkOptions) {
+		o.proxy = proxyURL
+	}
+}
+
+// httpClient 按 SinkTLS、SinkProxy 创建 HTTP 客户端
+func (o sinkOptions) httpClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.tls != nil {
+		cfg, err := o.tls.Config()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = cfg
+	}
+	if o.proxy != "" {
+		u, err := url.Parse(o.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("log: invalid proxy %q: %w", o.proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SpanLogger 将日志写入 ctx 中当前 span 的事件，由使用方对接 OpenTelemetry、OpenTracing 等追踪库
+type SpanLogger func(ctx context.Context, e Entry)
+
+// spanContext FromContext 附带的 context，用于在写入时找到日志所属的 span，不会被编码输出
+type spanContext struct{ ctx context.Context }
+
+func spanField(ctx context.Context) zap.Field {
+	return zap.Field{Key: "", Type: zapcore.SkipType, Interface: spanContext{ctx}}
+}
+
+// spanMarker SpanEvent 字段的标记
+type spanMarker struct{}
+
+// SpanEvent 返回一个不会输出的字段，带有该字段的日志无论等级都写入 span 事件
+func SpanEvent() zap.Field {
+	return zap.Field{Key: "", Type: zapcore.SkipType, Interface: spanMarker{}}
+}
+
+// spanCore 将不低于 level 或带有 SpanEvent 字段的日志交给 SpanLogger
+type spanCore struct {
+	zapcore.Core
+	fields []zapcore.Field
+	level  zapcore.Level
+	fn     SpanLogger
+}
+
+func (c *spanCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &spanCore{Core: c.Core.With(fields), fields: all, level: c.level, fn: c.fn}
+}
+
+func (c *spanCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write processorCore 不向内传递 With 的字段，因此同时在 fields 中查找 span
+func (c *spanCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var ctx context.Context
+	selected := ent.Level >= c.level
+	enc := zapcore.NewMapObjectEncoder()
+	for _, list := range [][]zapcore.Field{c.fields, fields} {
+		for _, f := range list {
+			switch v := f.Interface.(type) {
+			case spanContext:
+				if f.Type == zapcore.SkipType {
+					ctx = v.ctx
+				}
+			case spanMarker:
+				selected = selected || f.Type == zapcore.SkipType
+			}
+			f.AddTo(enc)
+		}
+	}
+	if ctx != nil && selected {
+		c.fn(ctx, Entry{Entry: ent, Fields: enc.Fields})
+	}
+	return c.Core.Write(ent, fields)
+}
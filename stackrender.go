@@ -0,0 +1,89 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// stackKeys 控制台中另起多行显示的字段
+var stackKeys = map[string]bool{"stacktrace": true, "stack": true, "errorVerbose": true}
+
+// multilineStackEncoder 将堆栈字段与带详细信息的错误链从控制台日志行中取出，缩进显示在后续行
+type multilineStackEncoder struct {
+	zapcore.Encoder
+	lineEnding string
+}
+
+func (e multilineStackEncoder) Clone() zapcore.Encoder {
+	return multilineStackEncoder{e.Encoder.Clone(), e.lineEnding}
+}
+
+func (e multilineStackEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var blocks []string
+	var rest []zapcore.Field
+	for i, f := range fields {
+		block := stackBlock(f)
+		if block == "" {
+			if rest != nil {
+				rest = append(rest, f)
+			}
+			continue
+		}
+		if rest == nil {
+			rest = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+		blocks = append(blocks, block)
+		if f.Type == zapcore.ErrorType {
+			// 保留单行的错误信息
+			rest = append(rest, zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: f.Interface.(error).Error()})
+		}
+	}
+	if rest == nil {
+		return e.Encoder.EncodeEntry(ent, fields)
+	}
+	buf, err := e.Encoder.EncodeEntry(ent, rest)
+	if err != nil {
+		return nil, err
+	}
+	line := strings.TrimSuffix(buf.String(), e.lineEnding)
+	buf.Reset()
+	buf.AppendString(line)
+	for _, block := range blocks {
+		buf.AppendString("\n")
+		buf.AppendString(block)
+	}
+	buf.AppendString(e.lineEnding)
+	return buf, nil
+}
+
+// stackBlock 返回字段的多行显示内容，不需要另起多行时返回空字符串
+func stackBlock(f zapcore.Field) string {
+	var key, text string
+	switch {
+	case f.Type == zapcore.StringType && stackKeys[f.Key]:
+		key, text = f.Key, f.String
+	case f.Type == zapcore.ErrorType:
+		err, ok := f.Interface.(error)
+		if !ok || err == nil {
+			return ""
+		}
+		if _, ok := err.(fmt.Formatter); !ok {
+			return ""
+		}
+		verbose := fmt.Sprintf("%+v", err)
+		if verbose == err.Error() {
+			return ""
+		}
+		key, text = f.Key+"Verbose", verbose
+	default:
+		return ""
+	}
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return ""
+	}
+	return key + ":\n\t" + strings.ReplaceAll(text, "\n", "\n\t")
+}
@@ -0,0 +1,311 @@
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// sinkStats 单个输出的写入统计
+type sinkStats struct {
+	name    string
+	writes  uint64
+	bytes   uint64
+	errors  uint64
+	mu      sync.Mutex
+	lastErr string
+	checked uint64 // 上次心跳时的 errors，用于判断期间是否出错
+	latency *histogram
+	sizes   *histogram
+	probe   func(context.Context) error // HealthCheck 使用的探测，为空时不探测
+}
+
+// statsWriteSyncer 统计写入次数、字节数与错误
+type statsWriteSyncer struct {
+	zapcore.WriteSyncer
+	s *sinkStats
+}
+
+func (w *statsWriteSyncer) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := w.WriteSyncer.Write(p)
+	w.s.latency.observe(uint64(time.Since(start)))
+	w.s.sizes.observe(uint64(len(p)))
+	atomic.AddUint64(&w.s.writes, 1)
+	atomic.AddUint64(&w.s.bytes, uint64(n))
+	if err != nil {
+		atomic.AddUint64(&w.s.errors, 1)
+		w.s.mu.Lock()
+		w.s.lastErr = err.Error()
+		w.s.mu.Unlock()
+	}
+	return n, err
+}
+
+// logStats 日志写入统计，自 NewLogger 起累计
+type logStats struct {
+	entries   [zapcore.FatalLevel - TraceLevel + 1]uint64
+	sinks     []*sinkStats
+	rotations uint64
+}
+
+// sink 返回统计 ws 写入情况的 WriteSyncer，probe 为 HealthCheck 使用的探测
+func (s *logStats) sink(name string, ws zapcore.WriteSyncer, probe func(context.Context) error) zapcore.WriteSyncer {
+	ss := &sinkStats{name: name, latency: newHistogram(latencyBounds), sizes: newHistogram(sizeBounds), probe: probe}
+	s.sinks = append(s.sinks, ss)
+	return &statsWriteSyncer{WriteSyncer: ws, s: ss}
+}
+
+func (s *logStats) count(lvl zapcore.Level) {
+	if lvl >= TraceLevel && lvl <= zapcore.FatalLevel {
+		atomic.AddUint64(&s.entries[lvl-TraceLevel], 1)
+	}
+}
+
+// rotationCounter 按 lumberjack 的切割规则（写入后超过 MaxSize）切割日志文件并统计切割次数。
+// lumberjack 按 time.Now 命名与清理备份，无法注入时钟，因此切割与按 MaxAge 清理由 rotationCounter
+// 按 WithClock 的时钟完成，lumberjack 只负责重新打开、压缩及按 MaxBackups 清理
+type rotationCounter struct {
+	mu     sync.Mutex
+	lj     *lumberjack.Logger
+	clock  zapcore.Clock
+	maxAge int
+	size   int64
+	init   bool
+	count  *uint64 // 为 nil 时不计数
+}
+
+// newRotationCounter 接管 lj 的 MaxAge，调用前需设置好 lj 的保留策略
+func newRotationCounter(lj *lumberjack.Logger, clock zapcore.Clock, count *uint64) *rotationCounter {
+	r := &rotationCounter{lj: lj, clock: clock, maxAge: lj.MaxAge, count: count}
+	lj.MaxAge = 0
+	return r
+}
+
+// rotate 立即轮转当前文件，不计入轮转次数
+func (r *rotationCounter) rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotateLocked()
+}
+
+// rotateLocked 把当前文件按时钟时间移为备份并重新打开，同名备份已存在时时间顺延 1ms
+func (r *rotationCounter) rotateLocked() error {
+	r.size, r.init = 0, true
+	if err := r.lj.Close(); err != nil {
+		return err
+	}
+	now := r.clock.Now()
+	if !r.lj.LocalTime {
+		now = now.UTC()
+	}
+	name := r.lj.Filename
+	ext := filepath.Ext(name)
+	for {
+		backup := strings.TrimSuffix(name, ext) + "-" + now.Format(backupTimeFormat) + ext
+		if _, err := os.Stat(backup); err == nil {
+			now = now.Add(time.Millisecond)
+			continue
+		}
+		if _, err := os.Stat(backup + ".gz"); err == nil {
+			now = now.Add(time.Millisecond)
+			continue
+		}
+		if err := os.Rename(name, backup); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		break
+	}
+	r.prune(now)
+	// 写入空内容使 lumberjack 创建新文件，并压缩、按 MaxBackups 清理备份
+	_, err := r.lj.Write(nil)
+	return err
+}
+
+// prune 删除归档时间早于 now 前 MaxAge 天的备份
+func (r *rotationCounter) prune(now time.Time) {
+	if r.maxAge <= 0 {
+		return
+	}
+	cutoff := now.Add(-time.Duration(r.maxAge) * 24 * time.Hour)
+	for _, f := range logFiles(r.lj.Filename) {
+		if !f.rotated.IsZero() && f.rotated.Before(cutoff) {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// reopen 关闭当前文件，下次写入时按文件名重新打开，用于外部 logrotate 移走文件之后
+func (r *rotationCounter) reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init = false
+	return r.lj.Close()
+}
+
+func (r *rotationCounter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.init {
+		if fi, err := os.Stat(r.lj.Filename); err == nil {
+			r.size = fi.Size()
+		}
+		r.init = true
+		r.prune(r.clock.Now())
+	}
+	max := int64(r.lj.MaxSize) * megabyte
+	if max == 0 {
+		max = 100 * megabyte
+	}
+	if r.size > 0 && r.size+int64(len(p)) > max {
+		if r.count != nil {
+			atomic.AddUint64(r.count, 1)
+		}
+		if err := r.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	r.size += int64(len(p))
+	return r.lj.Write(p)
+}
+
+// SinkCounters 单个输出的写入统计
+type SinkCounters struct {
+	Writes    uint64    // 写入次数
+	Bytes     uint64    // 写入字节数
+	Errors    uint64    // 写入失败次数
+	LastError string    // 最近一次写入错误
+	Latency   Histogram // 写入延迟（秒）
+	Sizes     Histogram // 日志大小（字节）
+}
+
+// Counters 自 NewLogger 起累计的日志统计
+type Counters struct {
+	Entries   map[string]uint64         // 各等级写入条数
+	Sinks     map[string]SinkCounters   // 各输出的写入统计，键为 file、sink-N、console、stdout、stderr
+	Rotations uint64                    // 普通日志文件的切割次数
+	Dropped   uint64                    // 异步队列已满时丢弃的条数
+	Budgets   map[string]BudgetCounters // 各 WithBudget 预算的统计
+	Shadow    ShadowCounters            // WithShadow 影子输出的统计
+}
+
+// Stats 返回日志统计的快照，可用于应用自身的健康检查接口
+func Stats() Counters {
+	c := Counters{Entries: map[string]uint64{}, Sinks: map[string]SinkCounters{}}
+	if l == nil || l.stats == nil {
+		return c
+	}
+	for i := range l.stats.entries {
+		if n := atomic.LoadUint64(&l.stats.entries[i]); n > 0 {
+			c.Entries[levelName(TraceLevel+zapcore.Level(i))] = n
+		}
+	}
+	for _, s := range l.stats.sinks {
+		s.mu.Lock()
+		lastErr := s.lastErr
+		s.mu.Unlock()
+		c.Sinks[s.name] = SinkCounters{
+			Writes:    atomic.LoadUint64(&s.writes),
+			Bytes:     atomic.LoadUint64(&s.bytes),
+			Errors:    atomic.LoadUint64(&s.errors),
+			LastError: lastErr,
+			Latency:   s.latency.snapshot(1 / float64(time.Second)),
+			Sizes:     s.sizes.snapshot(1),
+		}
+	}
+	c.Rotations = atomic.LoadUint64(&l.stats.rotations)
+	c.Dropped = Dropped()
+	c.Budgets = l.budgetCounters()
+	c.Shadow = l.shadowCounters()
+	return c
+}
+
+// statsCore 按等级统计写入的日志条数
+type statsCore struct {
+	zapcore.Core
+	stats *logStats
+}
+
+func (c *statsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &statsCore{Core: c.Core.With(fields), stats: c.stats}
+}
+
+func (c *statsCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *statsCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.stats.count(ent.Level)
+	return c.Core.Write(ent, fields)
+}
+
+// heartbeat 定期写入一条包含写入条数、丢弃条数与输出健康状况的日志，
+// 日志流中缺少心跳即说明 logger 或输出已卡住
+func (l *Logger) heartbeat() {
+	ticker := l.Opts.Clock.NewTicker(l.Opts.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.Logger.Info("[heartbeat]", l.heartbeatFields()...)
+		}
+	}
+}
+
+func (l *Logger) heartbeatFields() []zap.Field {
+	entries := zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for i := range l.stats.entries {
+			if n := atomic.LoadUint64(&l.stats.entries[i]); n > 0 {
+				enc.AddUint64(levelName(TraceLevel+zapcore.Level(i)), n)
+			}
+		}
+		return nil
+	})
+	// 健康状况在编码前计算，多个输出编码同一条心跳时结果一致
+	type sinkHealth struct {
+		s       *sinkStats
+		errors  uint64
+		healthy bool
+	}
+	health := make([]sinkHealth, len(l.stats.sinks))
+	for i, s := range l.stats.sinks {
+		errors := atomic.LoadUint64(&s.errors)
+		health[i] = sinkHealth{s: s, errors: errors, healthy: errors == atomic.SwapUint64(&s.checked, errors)}
+	}
+	sinks := zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, h := range health {
+			h := h
+			enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+				enc.AddString("name", h.s.name)
+				enc.AddUint64("writes", atomic.LoadUint64(&h.s.writes))
+				enc.AddUint64("errors", h.errors)
+				enc.AddBool("healthy", h.healthy)
+				h.s.mu.Lock()
+				if h.s.lastErr != "" {
+					enc.AddString("last_error", h.s.lastErr)
+				}
+				h.s.mu.Unlock()
+				return nil
+			}))
+		}
+		return nil
+	})
+	return []zap.Field{
+		zap.Object("entries", entries),
+		zap.Uint64("dropped", Dropped()),
+		zap.Array("sinks", sinks),
+	}
+}
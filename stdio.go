@@ -0,0 +1,128 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	stdioMu       sync.Mutex
+	stdioCaptured []*redirected // 当前生效的重定向
+)
+
+// captureStdio 将进程的 fd 1/2 重定向到管道，按行写入日志（stdout 为 Info，stderr 为 Warn），
+// 绕过 Go 日志的 cgo 或第三方库的输出因此也能进入日志文件。管道中的内容同时原样写入原来的 fd，
+// 控制台输出也改为写入原来的 fd，避免循环。已重定向时不再重复进行，restoreStdio 后可再次重定向。
+func captureStdio() {
+	stdioMu.Lock()
+	defer stdioMu.Unlock()
+	for _, r := range stdioCaptured {
+		if !r.restored {
+			return
+		}
+	}
+	stdioCaptured = nil
+	for _, s := range []struct {
+		fd     int
+		stream string
+		level  zapcore.Level
+		ws     *zapcore.WriteSyncer
+	}{
+		{1, "stdout", zapcore.InfoLevel, &consoleWs},
+		{2, "stderr", zapcore.WarnLevel, &consoleErrWs},
+	} {
+		r, err := redirectFd(s.fd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "log: capture %s: %v\n", s.stream, err)
+			continue
+		}
+		r.ws, r.orig = s.ws, *s.ws
+		*s.ws = zapcore.Lock(r.file)
+		fields := []zap.Field{zap.String("stream", s.stream)}
+		w := &lineWriter{level: s.level, fields: func() []zap.Field { return fields }}
+		go r.tee(w)
+		stdioCaptured = append(stdioCaptured, r)
+	}
+}
+
+// restoreStdio 将 fd 1/2 恢复为重定向前的 fd，使此后的输出（包括 runtime 的崩溃信息）直接到达原来的 stdout/stderr，
+// 并等待管道中已有的内容写完。release 为 true 时关闭保留的原 fd 副本，当前 logger 不再使用时才可释放。
+func restoreStdio(release bool) {
+	stdioMu.Lock()
+	defer stdioMu.Unlock()
+	for _, r := range stdioCaptured {
+		if !r.restored {
+			if err := dup2(int(r.file.Fd()), r.fd); err != nil {
+				fmt.Fprintf(r.file, "log: restore fd %d: %v\n", r.fd, err)
+				continue
+			}
+			r.restored = true
+			// fd 上的管道写端已被替换，读端读完剩余内容后结束；子进程继承的写端可能使其无法结束
+			select {
+			case <-r.done:
+			case <-time.After(time.Second):
+			}
+		}
+		if release {
+			*r.ws = r.orig
+			r.file.Close()
+		}
+	}
+	if release {
+		stdioCaptured = nil
+	}
+}
+
+// redirected 重定向后保留的原 fd 与读取重定向内容的管道
+type redirected struct {
+	fd       int
+	file     *os.File // 原 fd 的副本
+	pipe     *os.File
+	done     chan struct{} // 管道读完后关闭
+	restored bool          // fd 已恢复，原 fd 副本尚未释放
+	ws       *zapcore.WriteSyncer
+	orig     zapcore.WriteSyncer // 重定向前的控制台输出
+}
+
+// tee 将管道中的内容原样写入原 fd 并按行写入日志，原 fd 写入失败不影响写入日志
+func (r *redirected) tee(w *lineWriter) {
+	defer close(r.done)
+	defer r.pipe.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.pipe.Read(buf)
+		if n > 0 {
+			r.file.Write(buf[:n])
+			w.Write(buf[:n])
+		}
+		if err != nil {
+			w.flush()
+			return
+		}
+	}
+}
+
+func redirectFd(fd int) (*redirected, error) {
+	saved, err := dupFd(fd)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(saved), fmt.Sprintf("/dev/fd/%d", saved))
+	r, w, err := os.Pipe()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	defer w.Close()
+	if err := dup2(int(w.Fd()), fd); err != nil {
+		file.Close()
+		r.Close()
+		return nil, err
+	}
+	return &redirected{fd: fd, file: file, pipe: r, done: make(chan struct{})}, nil
+}
@@ -0,0 +1,12 @@
+package log
+
+import "syscall"
+
+func dupFd(fd int) (int, error) {
+	return syscall.Dup(fd)
+}
+
+// dup2 linux/arm64 等平台没有 dup2 系统调用，使用 dup3
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
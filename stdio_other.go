@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package log
+
+import "errors"
+
+var errStdioUnsupported = errors.New("redirecting stdio is not supported on this platform")
+
+func dupFd(fd int) (int, error) {
+	return 0, errStdioUnsupported
+}
+
+func dup2(oldfd, newfd int) error {
+	return errStdioUnsupported
+}
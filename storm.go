@@ -0,0 +1,125 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// StormGuard 日志风暴保护：连续 Sustain 个 Interval 内的日志条数都超过 Limit 时进入风暴状态，
+// 低于 Level 的日志被丢弃或按 Sample 采样，直到某个 Interval 内的条数回落到 Limit 以下
+type StormGuard struct {
+	Limit    int           // 每个 Interval 内的日志条数上限
+	Interval time.Duration // 统计周期，为 0 时为 1 秒
+	Sustain  int           // 连续超过上限多少个周期后进入风暴状态，为 0 时为 1
+	Level    zapcore.Level // 风暴期间保留的最低等级
+	Sample   int           // 风暴期间低于 Level 的日志每 Sample 条保留 1 条，为 0 时全部丢弃
+}
+
+// stormState 风暴保护的状态，由各 With 派生的 stormCore 共享
+type stormState struct {
+	guard StormGuard
+	core  zapcore.Core // 写入风暴标记的 core
+
+	mu         sync.Mutex
+	count      int    // 当前周期的日志条数，含被丢弃的
+	hot        int    // 连续超过上限的周期数
+	active     bool   // 是否处于风暴状态
+	suppressed uint64 // 当前周期丢弃的条数
+	total      uint64 // 本次风暴累计丢弃的条数
+}
+
+// allow 统计一条日志并返回是否写入
+func (s *stormState) allow(lvl zapcore.Level) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	if !s.active || lvl >= s.guard.Level {
+		return true
+	}
+	if s.guard.Sample > 0 && s.count%s.guard.Sample == 0 {
+		return true
+	}
+	s.suppressed++
+	return false
+}
+
+// tick 结束一个统计周期，按需进入或退出风暴状态并写入风暴标记
+func (s *stormState) tick() {
+	s.mu.Lock()
+	rate := s.count
+	s.count = 0
+	if rate > s.guard.Limit {
+		s.hot++
+	} else {
+		s.hot = 0
+	}
+	suppressed := s.suppressed
+	s.suppressed = 0
+	s.total += suppressed
+	var markers []string
+	switch {
+	case !s.active && s.hot >= s.guard.Sustain:
+		s.active = true
+		s.total = 0
+		markers = append(markers, "log storm detected")
+	case s.active && rate <= s.guard.Limit:
+		s.active = false
+		if suppressed > 0 {
+			markers = append(markers, "log storm suppressed")
+		}
+		markers = append(markers, "log storm ended")
+	case s.active && suppressed > 0:
+		markers = append(markers, "log storm suppressed")
+	}
+	total := s.total
+	s.mu.Unlock()
+
+	for _, msg := range markers {
+		fields := []zap.Field{zap.Int("rate", rate), zap.Duration("interval", s.guard.Interval), zap.String("min_level", levelName(s.guard.Level))}
+		if msg != "log storm detected" {
+			fields = append(fields, zap.Uint64("suppressed", suppressed), zap.Uint64("suppressed_total", total))
+		}
+		s.core.Write(zapcore.Entry{Level: zapcore.WarnLevel, Time: now(), Message: msg}, fields)
+	}
+}
+
+// stormCore 风暴期间丢弃或采样低等级的日志
+type stormCore struct {
+	zapcore.Core
+	state *stormState
+}
+
+func (c *stormCore) With(fields []zapcore.Field) zapcore.Core {
+	return &stormCore{Core: c.Core.With(fields), state: c.state}
+}
+
+func (c *stormCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *stormCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.state.allow(ent.Level) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// stormLoop 按 StormGuard.Interval 结束统计周期
+func (l *Logger) stormLoop(s *stormState) {
+	ticker := l.Opts.Clock.NewTicker(s.guard.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			s.tick()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// StreamHandler 以 Server-Sent Events 推送最近及实时的日志。
+// 查询参数 level 指定最低日志等级（默认 debug），regex 按正则过滤日志内容。
+func StreamHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		level := zapcore.DebugLevel
+		if name := r.URL.Query().Get("level"); name != "" {
+			if level, ok = parseLevel(strings.ToLower(name)); !ok {
+				http.Error(w, "invalid level "+name, http.StatusBadRequest)
+				return
+			}
+		}
+		var re *regexp.Regexp
+		if expr := r.URL.Query().Get("regex"); expr != "" {
+			var err error
+			if re, err = regexp.Compile(expr); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ch, cancel := Subscribe(level)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(e Entry) {
+			if e.Level < level {
+				return
+			}
+			data, err := json.Marshal(e.flatten())
+			if err != nil {
+				return
+			}
+			if re != nil && !re.Match(data) {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", levelName(e.Level), data)
+		}
+		for _, e := range subscribers.recent() {
+			send(e)
+		}
+		flusher.Flush()
+
+		keepalive := time.NewTicker(15 * time.Second)
+		defer keepalive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				send(e)
+				flusher.Flush()
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+// flatten 将日志转换为与文件输出相同结构的 map
+func (e Entry) flatten() map[string]interface{} {
+	m := make(map[string]interface{}, len(e.Fields)+5)
+	for k, v := range e.Fields {
+		m[k] = v
+	}
+	m["ts"] = e.Time.Format("2006-01-02 15:04:05.000")
+	m["level"] = levelName(e.Level)
+	m["msg"] = e.Message
+	if e.LoggerName != "" {
+		m["logger"] = e.LoggerName
+	}
+	if e.Caller.Defined {
+		m["caller"] = e.Caller.TrimmedPath()
+	}
+	return m
+}
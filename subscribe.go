@@ -0,0 +1,177 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry 订阅到的日志，Subscribe 的每个订阅者收到各自的 Fields，可以修改
+type Entry struct {
+	zapcore.Entry
+	Fields map[string]interface{}
+}
+
+type subscriber struct {
+	level zapcore.Level
+	ch    chan Entry
+}
+
+// hub 管理日志订阅者
+type hub struct {
+	mu   sync.RWMutex
+	subs map[*subscriber]struct{}
+	min  int32 // 订阅者中最低的日志等级
+
+	historyMu sync.Mutex
+	history   []Entry // 最近写入的日志
+	next      int
+	size      int
+}
+
+var subscribers = &hub{subs: map[*subscriber]struct{}{}, min: int32(zapcore.FatalLevel + 1)}
+
+// Subscribe 订阅 level 及以上级别的日志，订阅不受全局日志等级影响。
+// 消费过慢时新日志会被丢弃；调用 cancel 取消订阅并关闭 channel。
+func Subscribe(level zapcore.Level) (<-chan Entry, func()) {
+	s := &subscriber{level: level, ch: make(chan Entry, 256)}
+	subscribers.mu.Lock()
+	subscribers.subs[s] = struct{}{}
+	subscribers.updateMin()
+	subscribers.mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			subscribers.mu.Lock()
+			delete(subscribers.subs, s)
+			subscribers.updateMin()
+			subscribers.mu.Unlock()
+			close(s.ch)
+		})
+	}
+}
+
+func (h *hub) updateMin() {
+	min := zapcore.FatalLevel + 1
+	for s := range h.subs {
+		if s.level < min {
+			min = s.level
+		}
+	}
+	atomic.StoreInt32(&h.min, int32(min))
+}
+
+func (h *hub) setHistory(n int) {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	if n == len(h.history) {
+		return
+	}
+	h.history = make([]Entry, n)
+	h.next, h.size = 0, 0
+}
+
+func (h *hub) record(e Entry) {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	if len(h.history) == 0 {
+		return
+	}
+	h.history[h.next] = e
+	h.next = (h.next + 1) % len(h.history)
+	if h.size < len(h.history) {
+		h.size++
+	}
+}
+
+// recent 按时间顺序返回最近写入的日志
+func (h *hub) recent() []Entry {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	if h.size == 0 {
+		return nil
+	}
+	out := make([]Entry, 0, h.size)
+	start := (h.next - h.size + len(h.history)) % len(h.history)
+	for i := 0; i < h.size; i++ {
+		out = append(out, h.history[(start+i)%len(h.history)])
+	}
+	return out
+}
+
+func (h *hub) recording() bool {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	return len(h.history) > 0
+}
+
+func (h *hub) enabled(lvl zapcore.Level) bool {
+	return int32(lvl) >= atomic.LoadInt32(&h.min)
+}
+
+func (h *hub) publish(ent zapcore.Entry, fields []zapcore.Field, written bool) {
+	// 每个订阅者与历史记录各自编码一份 Fields，消费方修改 map 不影响其它订阅者
+	entry := func() Entry {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		return Entry{Entry: ent, Fields: enc.Fields}
+	}
+	if written && h.recording() {
+		h.record(entry())
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs {
+		if ent.Level < s.level {
+			continue
+		}
+		select {
+		case s.ch <- entry():
+		default:
+		}
+	}
+}
+
+// hubCore 将日志同时发布给订阅者
+type hubCore struct {
+	zapcore.Core
+	hub    *hub
+	fields []zapcore.Field
+}
+
+func (c *hubCore) Enabled(lvl zapcore.Level) bool {
+	return c.hub.enabled(lvl) || c.Core.Enabled(lvl)
+}
+
+func (c *hubCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &hubCore{Core: c.Core.With(fields), hub: c.hub, fields: all}
+}
+
+func (c *hubCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *hubCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	written := c.Core.Enabled(ent.Level)
+	if c.hub.enabled(ent.Level) || (written && c.hub.recording()) {
+		all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+		all = append(all, c.fields...)
+		all = append(all, fields...)
+		c.hub.publish(ent, all, written)
+	}
+	if !written {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
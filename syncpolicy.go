@@ -0,0 +1,80 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyncPolicy 自动 Sync 的时机，各条件可组合，零值表示从不自动 Sync
+type SyncPolicy struct {
+	Entries  int           // 每写入 Entries 条日志 Sync 一次，为 0 时不按条数
+	Interval time.Duration // 每隔 Interval Sync 一次，为 0 时不按时间
+	OnError  bool          // 写入 Error 及以上的日志后立即 Sync
+}
+
+// SyncEveryEntries 每写入 n 条日志 Sync 一次
+func SyncEveryEntries(n int) SyncPolicy {
+	return SyncPolicy{Entries: n}
+}
+
+// SyncEveryInterval 每隔 d Sync 一次
+func SyncEveryInterval(d time.Duration) SyncPolicy {
+	return SyncPolicy{Interval: d}
+}
+
+// SyncOnError 写入 Error 及以上的日志后立即 Sync
+func SyncOnError() SyncPolicy {
+	return SyncPolicy{OnError: true}
+}
+
+// SyncNever 从不自动 Sync，与默认行为一致
+func SyncNever() SyncPolicy {
+	return SyncPolicy{}
+}
+
+// syncPolicyCore 按 SyncPolicy 的条数与等级条件在写入后 Sync
+type syncPolicyCore struct {
+	zapcore.Core
+	policy SyncPolicy
+	count  *uint64
+}
+
+func (c *syncPolicyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &syncPolicyCore{Core: c.Core.With(fields), policy: c.policy, count: c.count}
+}
+
+func (c *syncPolicyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syncPolicyCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		return err
+	}
+	if c.policy.OnError && ent.Level >= zapcore.ErrorLevel {
+		return c.Core.Sync()
+	}
+	if c.policy.Entries > 0 && atomic.AddUint64(c.count, 1)%uint64(c.policy.Entries) == 0 {
+		return c.Core.Sync()
+	}
+	return nil
+}
+
+// syncLoop 按 SyncPolicy.Interval 定期 Sync
+func (l *Logger) syncLoop(core zapcore.Core) {
+	ticker := l.Opts.Clock.NewTicker(l.Opts.SyncPolicy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			core.Sync()
+		}
+	}
+}
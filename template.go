@@ -0,0 +1,69 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// messageTemplate 将消息按模板展开，支持 {msg}、{app}、{level}、{logger}，其余 {key} 取同名字段的值，
+// 字段不存在时展开为空字符串
+func messageTemplate(tmpl, app string) Processor {
+	// 预先拆分为文本与占位符交替的片段，奇数下标为占位符名
+	var parts []string
+	for {
+		i := strings.IndexByte(tmpl, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(tmpl[i+1:], '}')
+		if j < 0 {
+			break
+		}
+		parts = append(parts, tmpl[:i], tmpl[i+1:i+1+j])
+		tmpl = tmpl[i+2+j:]
+	}
+	parts = append(parts, tmpl)
+
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		var b strings.Builder
+		for i, part := range parts {
+			if i%2 == 0 {
+				b.WriteString(part)
+				continue
+			}
+			switch part {
+			case "msg":
+				b.WriteString(ent.Message)
+			case "app":
+				b.WriteString(app)
+			case "level":
+				b.WriteString(levelName(ent.Level))
+			case "logger":
+				b.WriteString(ent.LoggerName)
+			default:
+				b.WriteString(fieldString(fields, part))
+			}
+		}
+		ent.Message = b.String()
+		return ent, fields, true
+	}
+}
+
+// fieldString 返回最后一个名为 key 的字段的字符串形式
+func fieldString(fields []zapcore.Field, key string) string {
+	for i := len(fields) - 1; i >= 0; i-- {
+		f := fields[i]
+		if f.Key != key {
+			continue
+		}
+		if f.Type == zapcore.StringType {
+			return f.String
+		}
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return fmt.Sprint(enc.Fields[key])
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// tenantLogger 租户独立的日志文件
+type tenantLogger struct {
+	logger *zap.Logger
+	rc     *rotationCounter
+}
+
+// tenantFileName 返回租户日志文件路径，租户 ID 中文件名不允许的字符替换为 _
+func (l *Logger) tenantFileName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, id)
+	if name == "" || name == "." || name == ".." {
+		name = "_"
+	}
+	return filepath.Join(l.filePath(l.Opts.TenantDir), name+".log")
+}
+
+func (l *Logger) newTenant(id string) *tenantLogger {
+	lj := l.rotateLogger(l.tenantFileName(id))
+	if l.Opts.TenantMaxSize > 0 {
+		lj.MaxSize = l.Opts.TenantMaxSize
+	}
+	if l.Opts.TenantMaxBackups > 0 {
+		lj.MaxBackups = l.Opts.TenantMaxBackups
+	}
+	rc := newRotationCounter(lj, l.Opts.Clock, nil)
+	var core zapcore.Core = zapcore.NewCore(zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig), l.rotateWriter(rc), l.zapConfig.Level)
+	if processors := l.processors(); len(processors) > 0 {
+		core = &processorCore{Core: core, processors: processors}
+	}
+	core = &fieldProviderCore{Core: core, providers: append([]func() []zap.Field{mdcFields}, l.Opts.FieldProviders...)}
+	logger := l.Logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return newMultiCore(c, core)
+	})).With(zap.String("tenant", id))
+	return &tenantLogger{logger: logger, rc: rc}
+}
+
+// Tenant 返回租户的子 logger，日志同时写入普通日志与租户独立的文件，文件在首次使用时创建。
+// 未启用 WithTenants 时返回附带 tenant 字段的全局 logger。
+func Tenant(id string) *zap.Logger {
+	if l == nil || l.Opts.TenantDir == "" {
+		return current().With(zap.String("tenant", id))
+	}
+	l.tenantsMu.Lock()
+	defer l.tenantsMu.Unlock()
+	t, ok := l.tenants[id]
+	if !ok {
+		t = l.newTenant(id)
+		if l.tenants == nil {
+			l.tenants = map[string]*tenantLogger{}
+		}
+		l.tenants[id] = t
+	}
+	return t.logger
+}
+
+// TenantFiles 返回租户的日志文件（含切片文件），按时间从旧到新排列，可用于打包诊断信息
+func TenantFiles(id string) []string {
+	if l == nil || l.Opts.TenantDir == "" {
+		return nil
+	}
+	var paths []string
+	for _, f := range logFiles(l.tenantFileName(id)) {
+		paths = append(paths, f.path)
+	}
+	return paths
+}
+
+// RemoveTenant 关闭租户的日志文件并删除其所有日志
+func RemoveTenant(id string) error {
+	if l == nil || l.Opts.TenantDir == "" {
+		return nil
+	}
+	l.tenantsMu.Lock()
+	if t, ok := l.tenants[id]; ok {
+		t.rc.lj.Close()
+		delete(l.tenants, id)
+	}
+	l.tenantsMu.Unlock()
+	for _, path := range TenantFiles(id) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Logger) closeTenants() {
+	l.tenantsMu.Lock()
+	defer l.tenantsMu.Unlock()
+	for id, t := range l.tenants {
+		t.rc.lj.Close()
+		delete(l.tenants, id)
+	}
+}
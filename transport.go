@@ -0,0 +1,141 @@
+package log
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// httpBodyLimit Debug 等级下记录的请求与响应体的最大字节数
+const httpBodyLimit = 4096
+
+// sensitiveHeaders 记录请求头时隐藏的值
+var sensitiveHeaders = map[string]bool{"Authorization": true, "Cookie": true, "Set-Cookie": true, "Proxy-Authorization": true}
+
+type retryKey struct{}
+
+// RetryContext 返回统计发送次数的 ctx，使用同一 ctx 重试的请求经 WrapTransport 记录时带有 retry 次数
+func RetryContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, new(int32))
+}
+
+type loggingTransport struct {
+	rt http.RoundTripper
+}
+
+// WrapTransport 返回记录每个出站请求的 RoundTripper，包含 method、url、status、duration 与 retry，
+// logger 的生效等级为 Debug 时还记录请求头、响应头及前 4KB 的请求体；响应体不预先读取，
+// 在调用方读取时记录前 4KB，读完或关闭后另记一条 Debug 日志，不影响流式响应。rt 为空时使用 http.DefaultTransport。
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &loggingTransport{rt: rt}
+}
+
+func (t *loggingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	logger := FromContext(r.Context())
+	debug := verbose(logger, loggerName(logger))
+	fields := []zap.Field{
+		zap.String("method", r.Method),
+		zap.String("url", r.URL.Redacted()),
+	}
+	if n, ok := r.Context().Value(retryKey{}).(*int32); ok {
+		if retry := atomic.AddInt32(n, 1) - 1; retry > 0 {
+			fields = append(fields, zap.Int32("retry", retry))
+		}
+	}
+	if debug {
+		fields = append(fields, zap.Object("request_headers", headerFields(r.Header)))
+		if r.Body != nil && r.GetBody != nil {
+			if body, err := r.GetBody(); err == nil {
+				b, _ := io.ReadAll(io.LimitReader(body, httpBodyLimit))
+				body.Close()
+				fields = append(fields, zap.ByteString("request_body", b))
+			}
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(r)
+	fields = append(fields, zap.Duration("duration", time.Since(start)))
+	if err != nil {
+		logger.Error("http client", append(fields, zap.Error(err))...)
+		return resp, err
+	}
+	fields = append(fields, zap.Int("status", resp.StatusCode))
+	if debug {
+		fields = append(fields, zap.Object("response_headers", headerFields(resp.Header)))
+		// 101 响应的 Body 同时用于写入，不能包装
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			method, url, status := r.Method, r.URL.Redacted(), resp.StatusCode
+			resp.Body = &teeBody{ReadCloser: resp.Body, done: func(b []byte) {
+				logger.Debug("http client response body", zap.String("method", method), zap.String("url", url),
+					zap.Int("status", status), zap.ByteString("response_body", b))
+			}}
+		}
+	}
+	if resp.StatusCode >= 500 {
+		logger.Warn("http client", fields...)
+	} else {
+		logger.Info("http client", fields...)
+	}
+	return resp, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// teeBody 在调用方读取响应体时保留前 httpBodyLimit 字节，读到结尾或关闭时交给 done
+type teeBody struct {
+	io.ReadCloser
+	buf  []byte
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := httpBodyLimit - len(b.buf); room > 0 {
+		if room > n {
+			room = n
+		}
+		b.buf = append(b.buf, p[:room]...)
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *teeBody) finish() {
+	b.once.Do(func() { b.done(b.buf) })
+}
+
+// headerFields 以对象记录请求头，认证相关的值被隐藏
+func headerFields(h http.Header) zapcore.ObjectMarshaler {
+	return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for k, v := range h {
+			if sensitiveHeaders[k] {
+				enc.AddString(k, redacted)
+				continue
+			}
+			enc.AddString(k, strings.Join(v, ", "))
+		}
+		return nil
+	})
+}
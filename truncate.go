@@ -0,0 +1,107 @@
+package log
+
+import (
+	"encoding/json"
+	"sort"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// minTruncated 截断后每个消息或字段至少保留的字节数
+const minTruncated = 64
+
+// maxEntrySize 编码后超过 max 字节的日志从最大的消息或字段开始截断，并添加 truncated=true
+func maxEntrySize(max int, cfg zapcore.EncoderConfig) Processor {
+	enc := zapcore.NewJSONEncoder(cfg)
+	return func(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field, bool) {
+		truncated := false
+		// 转义与字段类型变化使截断后的大小无法精确预估，重新编码检查，最多截断三次
+		for i := 0; i < 3; i++ {
+			buf, err := enc.EncodeEntry(ent, fields)
+			if err != nil {
+				break
+			}
+			excess := buf.Len() - max
+			buf.Free()
+			if excess <= 0 {
+				break
+			}
+			if !truncated {
+				fields = append(append([]zapcore.Field(nil), fields...), zap.Bool("truncated", true))
+				truncated = true
+			}
+			ent = truncateEntry(ent, fields, excess)
+		}
+		return ent, fields, true
+	}
+}
+
+// truncateEntry 按大小从大到小截断消息与字段，共截去约 excess 字节
+func truncateEntry(ent zapcore.Entry, fields []zapcore.Field, excess int) zapcore.Entry {
+	type item struct {
+		index int // -1 表示消息
+		value string
+	}
+	items := []item{{-1, ent.Message}}
+	for i, f := range fields {
+		if v, ok := encodedValue(f); ok {
+			items = append(items, item{i, v})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return len(items[i].value) > len(items[j].value) })
+
+	for _, it := range items {
+		if excess <= 0 {
+			break
+		}
+		cut := len(it.value) - minTruncated
+		if cut <= 0 {
+			continue
+		}
+		if cut > excess {
+			cut = excess
+		}
+		v := truncateUTF8(it.value, len(it.value)-cut)
+		excess -= len(it.value) - len(v)
+		if it.index < 0 {
+			ent.Message = v
+		} else {
+			fields[it.index] = zap.String(fields[it.index].Key, v)
+		}
+	}
+	return ent
+}
+
+// encodedValue 返回字段的值：字符串字段为其内容，其余为 JSON 编码
+func encodedValue(f zapcore.Field) (string, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String, true
+	case zapcore.SkipType:
+		return "", false
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	v, ok := enc.Fields[f.Key]
+	if !ok {
+		return "", false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// truncateUTF8 截断 s 至最多 n 字节，不拆分多字节字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
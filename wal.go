@@ -0,0 +1,86 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SinkWAL 启用预写日志：日志先写入 dir 下的分段文件，远端确认后删除，
+// 进程崩溃或远端不可用时保留的分段会在重启后及每个发送周期重新发送，实现至少一次投递。
+// 单个分段超过 segmentBytes 时立即封存发送，为 0 时为 16MB。
+func SinkWAL(dir string, segmentBytes int) SinkOption {
+	return func(o *sinkOptions) {
+		o.walDir = dir
+		o.walSegment = segmentBytes
+	}
+}
+
+// walWriter 预写日志的当前分段，由 RemoteSink.mu 保护
+type walWriter struct {
+	dir  string
+	max  int
+	f    *os.File
+	size int
+}
+
+func (w *walWriter) write(p []byte) error {
+	if w.f == nil {
+		if err := os.MkdirAll(w.dir, 0755); err != nil {
+			return err
+		}
+		// 以纳秒时间戳命名，按文件名排序即为写入顺序
+		name := filepath.Join(w.dir, fmt.Sprintf("%020d.wal", time.Now().UnixNano()))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return err
+		}
+		w.f, w.size = f, 0
+	}
+	n, err := w.f.Write(p)
+	w.size += n
+	return err
+}
+
+func (w *walWriter) full() bool {
+	max := w.max
+	if max <= 0 {
+		max = 16 * megabyte
+	}
+	return w.size >= max
+}
+
+// seal 关闭当前分段并返回其路径，没有分段时返回空字符串
+func (w *walWriter) seal() string {
+	if w.f == nil {
+		return ""
+	}
+	name := w.f.Name()
+	w.f.Close()
+	w.f = nil
+	return name
+}
+
+// segments 返回目录中已有的分段，按写入顺序排列
+func (w *walWriter) segments() []string {
+	names, _ := filepath.Glob(filepath.Join(w.dir, "*.wal"))
+	sort.Strings(names)
+	return names
+}
+
+// retryPending 重新发送等待重试的分段，遇到失败时停止，保持发送顺序
+func (s *RemoteSink) retryPending() {
+	for len(s.pending) > 0 {
+		data, err := os.ReadFile(s.pending[0])
+		if err == nil && len(data) > 0 {
+			err = s.sendWithRetry(data)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return
+		}
+		os.Remove(s.pending[0])
+		s.pending = s.pending[1:]
+	}
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WatchContext 在 ctx 被取消或超过截止时间时以 Warn 级别写入 msg，附带原因（reason 与 context.Cause 的 cause，
+// Go 1.20 之前为 ctx.Err()）、自调用起经过的时间与截止时间，用于追查请求被中止的原因。日志使用 FromContext(ctx) 的 logger。
+// 操作正常结束后应调用返回的 stop，此后 ctx 结束不再记录。
+//
+//	stop := log.WatchContext(ctx, "export aborted", zap.String("job", id))
+//	defer stop()
+func WatchContext(ctx context.Context, msg string, fields ...zap.Field) (stop func()) {
+	done := ctx.Done()
+	if done == nil {
+		return func() {}
+	}
+	start := now()
+	stopped := make(chan struct{})
+	finished := make(chan struct{})
+	logger := FromContext(ctx)
+	// 日志在后台 goroutine 中写入，调用位置取 WatchContext 的调用方
+	caller := zapcore.NewEntryCaller(runtime.Caller(1))
+	go func() {
+		defer close(finished)
+		select {
+		case <-stopped:
+			return
+		case <-done:
+		}
+		reason := "canceled"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "deadline_exceeded"
+		}
+		all := append(fields[:len(fields):len(fields)],
+			zap.String("reason", reason),
+			zap.String("cause", contextCause(ctx).Error()),
+			zap.Duration("elapsed", now().Sub(start)))
+		if deadline, ok := ctx.Deadline(); ok {
+			all = append(all, zap.Time("deadline", deadline))
+		}
+		if ce := logger.Check(zapcore.WarnLevel, msg); ce != nil {
+			if ce.Caller.Defined {
+				ce.Caller = caller
+			}
+			ce.Write(all...)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stopped)
+			<-finished
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// watchdog 已注册的组件，键为名称
+var watchdog = struct {
+	sync.Mutex
+	components map[string]*watched
+}{components: map[string]*watched{}}
+
+// watched 一次注册，取消或被替换后心跳不再生效
+type watched struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	last    time.Time
+	stopped bool
+}
+
+func (w *watched) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
+
+// Watch 注册名为 name 的组件并返回心跳函数。组件超过 timeout 未调用心跳时写入一条 Error 日志，
+// 附带所有 goroutine 的堆栈，用于发现死锁等无声的卡死；再次心跳后重新开始计时。
+// 同名组件重复注册时替换之前的注册，组件退出时调用 Unwatch；取消或被替换后原心跳函数不再有效。
+//
+//	beat := log.Watch("consumer", 30*time.Second)
+//	defer log.Unwatch("consumer")
+//	for msg := range msgs {
+//		beat()
+//		handle(msg)
+//	}
+func Watch(name string, timeout time.Duration) (beat func()) {
+	w := &watched{last: now()}
+	w.mu.Lock()
+	w.timer = time.AfterFunc(timeout, func() {
+		w.mu.Lock()
+		stopped, since := w.stopped, now().Sub(w.last)
+		w.mu.Unlock()
+		if stopped {
+			return
+		}
+		current().Error("[watchdog] component stalled",
+			zap.String("component", name),
+			zap.Duration("timeout", timeout),
+			zap.Duration("since_heartbeat", since),
+			zap.String("stack", string(allStacks())))
+	})
+	w.mu.Unlock()
+	watchdog.Lock()
+	if old, ok := watchdog.components[name]; ok {
+		old.stop()
+	}
+	watchdog.components[name] = w
+	watchdog.Unlock()
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.stopped {
+			return
+		}
+		w.last = now()
+		w.timer.Reset(timeout)
+	}
+}
+
+// Unwatch 取消名为 name 的组件的注册
+func Unwatch(name string) {
+	watchdog.Lock()
+	defer watchdog.Unlock()
+	if w, ok := watchdog.components[name]; ok {
+		w.stop()
+		delete(watchdog.components, name)
+	}
+}